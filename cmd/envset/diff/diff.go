@@ -0,0 +1,110 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/goliatone/go-envset/cmd/envset/internal/cliopts"
+	"github.com/goliatone/go-envset/cmd/envset/internal/report"
+	"github.com/goliatone/go-envset/pkg/config"
+	"github.com/goliatone/go-envset/pkg/envset"
+	"github.com/urfave/cli/v2"
+)
+
+// GetCommand returns a new cli.Command for the
+// diff command
+func GetCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "diff",
+		Usage: "compare two environments of the env file",
+		UsageText: `envset diff [source] [target]
+
+EXAMPLE:
+   envset diff development staging
+   envset diff development production --values
+   envset diff staging production --json --ignore APP_URL`,
+		Description: "list keys missing in either environment, keys with different values and keys with the same value, " +
+			"values are shown as hashes unless --values is set and secret values are redacted unless --reveal is set. " +
+			"Exits with an error if the environments are different",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "json", Usage: "print the comparison results in JSON format"},
+			&cli.BoolFlag{Name: "values", Usage: "show values instead of hashes"},
+			&cli.BoolFlag{Name: "reveal", Usage: "show secret values instead of **** with --values"},
+			&cli.StringSliceFlag{Name: "ignore", Aliases: []string{"I"}, Usage: "list of key names that are ignored"},
+			&cli.StringSliceFlag{Name: "env-file", Value: cli.NewStringSlice(cnf.EnvFiles()...), Usage: "load environments from `FILE`, repeat to merge files in order"},
+			&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file, ini, dotenv, yaml, toml or json"},
+			&cli.StringFlag{Name: "key-file", Value: cnf.KeyFile, Usage: "`file` with the key to decrypt encrypted values"},
+			&cli.StringFlag{Name: "secret", Usage: "`password` used to encode hash values. Define env ENVSET_HASH_SECRET", EnvVars: []string{"ENVSET_HASH_SECRET"}},
+			&cli.StringFlag{
+				Name:    "hash-algo",
+				Usage:   "hash algorithm used to hash values. Define with ENVSET_HASH_ALGORITHM",
+				EnvVars: []string{"ENVSET_HASH_ALGORITHM"},
+				Value:   envset.HashSHA256,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 2 {
+				return cli.Exit("diff expects a source and a target environment", 1)
+			}
+
+			source := cnf.ResolveAlias(c.Args().Get(0))
+			target := cnf.ResolveAlias(c.Args().Get(1))
+			ignored := cnf.MergeIgnored(target, cnf.MergeIgnored(source, c.StringSlice("ignore")))
+
+			files := cliopts.EnvFiles(c)
+			keyFile, passphrase := cliopts.Key(c, cliopts.KeyFileFlag, "")
+			diff, err := envset.DiffEnvironments(source, target, envset.DiffOptions{
+				Filename:            files[0],
+				Overlays:            files[1:],
+				Format:              cliopts.String(c, cliopts.EnvFileFormatFlag),
+				LocalOverlays:       cnf.LocalOverlays,
+				CommentSectionNames: cnf.CommentSectionNames.Keys,
+				KeyFile:             keyFile,
+				Passphrase:          passphrase,
+				Algorithm:           c.String("hash-algo"),
+				Secret:              c.String("secret"),
+				Ignored:             ignored,
+				Values:              c.Bool("values"),
+				SecretKeys:          cnf.SecretPatterns(),
+				Reveal:              c.Bool("reveal"),
+			})
+			if err != nil {
+				return err
+			}
+
+			if c.Bool("json") {
+				if err := printJSON(c, diff, source, target); err != nil {
+					return err
+				}
+			} else {
+				report.PrettyPrint(diff, source, target)
+			}
+
+			if report.HasDifferences(diff) {
+				return cli.Exit("", 1)
+			}
+			return nil
+		},
+	}
+}
+
+type diffResult struct {
+	Source  string           `json:"source"`
+	Target  string           `json:"target"`
+	Keys    []*envset.EnvKey `json:"values"`
+	Ignored []*envset.EnvKey `json:"ignored,omitempty"`
+}
+
+func printJSON(c *cli.Context, diff envset.EnvSection, source, target string) error {
+	res := diffResult{Source: source, Target: target, Keys: diff.Keys, Ignored: diff.Ignored}
+	if res.Keys == nil {
+		res.Keys = []*envset.EnvKey{}
+	}
+
+	enc := json.NewEncoder(c.App.Writer)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(res); err != nil {
+		return fmt.Errorf("diff json marshall: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package edit
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goliatone/go-envset/cmd/envset/internal/cliopts"
+	"github.com/goliatone/go-envset/pkg/config"
+	"github.com/goliatone/go-envset/pkg/envset"
+	"github.com/urfave/cli/v2"
+)
+
+// GetSetCommand returns a new cli.Command for the
+// set command
+func GetSetCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "set",
+		Usage: "set values of an environment in the env file",
+		UsageText: `envset set [environment] KEY=value [KEY=value...]
+   envset set --all-envs KEY=value [KEY=value...]
+
+EXAMPLE:
+   envset set development API_URL=http://localhost:3000 DEBUG=true
+   envset set --all-envs LOG_FORMAT=json`,
+		Description: "update values in place keeping comments, order and comment sections, " +
+			"keys that are not defined are added after the last key of the section",
+		Flags: editFlags(cnf),
+		Action: func(c *cli.Context) error {
+			o, args, err := editOptions(c, cnf)
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				return cli.Exit("set expects at least one KEY=value", 1)
+			}
+
+			keys := make([]string, len(args))
+			for i, arg := range args {
+				keys[i], _, _ = strings.Cut(arg, "=")
+			}
+
+			sections, err := envset.SetValues(o, args)
+			if err != nil {
+				return err
+			}
+
+			for _, section := range sections {
+				fmt.Fprintf(c.App.Writer, "[%s] set %s\n", section, strings.Join(keys, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+// GetGetCommand returns a new cli.Command for the
+// get command
+func GetGetCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "get",
+		Usage: "print a value of an environment in the env file",
+		UsageText: `envset get [environment] KEY
+   envset get --all-envs KEY
+
+EXAMPLE:
+   envset get production DATABASE_URL
+   envset get --all-envs LOG_LEVEL`,
+		Description: "print the value as it is defined in the env file, values are not expanded or decrypted",
+		Flags:       editFlags(cnf),
+		Action: func(c *cli.Context) error {
+			o, args, err := editOptions(c, cnf)
+			if err != nil {
+				return err
+			}
+			if len(args) != 1 {
+				return cli.Exit("get expects one KEY", 1)
+			}
+
+			values, err := envset.GetValues(o, args[0])
+			if err != nil {
+				return err
+			}
+
+			if !o.AllSections {
+				fmt.Fprintln(c.App.Writer, values[0].Value)
+				return nil
+			}
+
+			w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0)
+			for _, v := range values {
+				fmt.Fprintf(w, "%s\t%s\n", v.Section, v.Value)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// GetUnsetCommand returns a new cli.Command for the
+// unset command
+func GetUnsetCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "unset",
+		Usage: "remove keys of an environment from the env file",
+		UsageText: `envset unset [environment] KEY [KEY...]
+   envset unset --all-envs KEY [KEY...]
+
+EXAMPLE:
+   envset unset development DEBUG
+   envset unset --all-envs LEGACY_API_URL`,
+		Description: "remove keys and the comments above them in place, keeping the rest of the file as it is",
+		Flags:       editFlags(cnf),
+		Action: func(c *cli.Context) error {
+			o, args, err := editOptions(c, cnf)
+			if err != nil {
+				return err
+			}
+			if len(args) == 0 {
+				return cli.Exit("unset expects at least one KEY", 1)
+			}
+
+			sections, err := envset.UnsetValues(o, args)
+			if err != nil {
+				return err
+			}
+
+			for _, section := range sections {
+				fmt.Fprintf(c.App.Writer, "[%s] unset %s\n", section, strings.Join(args, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+func editFlags(cnf *config.Config) []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{Name: "all-envs", Usage: "apply to every environment of the env file"},
+		&cli.StringFlag{Name: "env-file", Value: cnf.Filename, Usage: "update environment in `FILE`"},
+		&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file, only ini files can be updated"},
+	}
+}
+
+// editOptions returns the options and the arguments after the
+// environment name, which is not given with --all-envs.
+func editOptions(c *cli.Context, cnf *config.Config) (envset.EditOptions, []string, error) {
+	o := envset.EditOptions{
+		Filename:    cliopts.EnvFile(c),
+		Format:      cliopts.String(c, cliopts.EnvFileFormatFlag),
+		AllSections: c.Bool("all-envs"),
+		//keep the [comments] sections as they are
+		CommentSectionNames: cnf.CommentSectionNames.Keys,
+	}
+
+	args := c.Args().Slice()
+	if o.AllSections {
+		return o, args, nil
+	}
+
+	if len(args) == 0 {
+		return o, nil, cli.Exit(fmt.Sprintf("%s expects an environment name or --all-envs", c.Command.Name), 1)
+	}
+	o.Section = cnf.ResolveAlias(args[0])
+	return o, args[1:], nil
+}
@@ -0,0 +1,171 @@
+package encrypt
+
+import (
+	"fmt"
+
+	"github.com/goliatone/go-envset/cmd/envset/internal/cliopts"
+	"github.com/goliatone/go-envset/pkg/config"
+	"github.com/goliatone/go-envset/pkg/envset"
+	"github.com/urfave/cli/v2"
+)
+
+// GetCommand returns a new cli.Command for the
+// encrypt command
+func GetCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "encrypt",
+		Usage: "encrypt values of an environment in the env file",
+		UsageText: `envset encrypt --env [section] [KEY...] [KEY=value...]
+
+EXAMPLE:
+   envset encrypt --env production DB_PASSWORD
+   envset encrypt --env production API_TOKEN=secret
+   envset encrypt --env production --section`,
+		Description: "encrypt values in place as enc:v1: tokens, all values if no keys are given. " +
+			"With --section all keys of the section are encrypted into a single @encrypted key",
+		Flags: append(keyFlags(cnf),
+			&cli.BoolFlag{Name: "section", Usage: "encrypt the whole section"},
+		),
+		Action: func(c *cli.Context) error {
+			cipher, o, err := encryptOptions(c, cnf)
+			if err != nil {
+				return err
+			}
+			o.WholeSection = c.Bool("section")
+
+			count, err := envset.EncryptFile(cipher, o)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(c.App.Writer, "encrypted %d values in [%s]\n", count, o.Section)
+			return nil
+		},
+	}
+}
+
+// GetDecryptCommand returns a new cli.Command for the
+// decrypt command
+func GetDecryptCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "decrypt",
+		Usage: "decrypt values of an environment in the env file",
+		UsageText: `envset decrypt --env [section] [KEY...]
+
+EXAMPLE:
+   envset decrypt --env production DB_PASSWORD
+   envset decrypt --env production`,
+		Description: "decrypt values in place, all values and the encrypted section if no keys are given",
+		Flags:       keyFlags(cnf),
+		Action: func(c *cli.Context) error {
+			cipher, o, err := encryptOptions(c, cnf)
+			if err != nil {
+				return err
+			}
+
+			count, err := envset.DecryptFile(cipher, o)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(c.App.Writer, "decrypted %d values in [%s]\n", count, o.Section)
+			return nil
+		},
+	}
+}
+
+func keyFlags(cnf *config.Config) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: "env", Usage: "environment `name` to update", Value: envset.DefaultSection},
+		&cli.StringFlag{Name: "env-file", Value: cnf.Filename, Usage: "update environment in `FILE`"},
+		&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file, only ini files can be updated"},
+		&cli.StringFlag{
+			Name:  "key-file",
+			Usage: "`file` with the encryption key. Define with ENVSET_KEY_FILE",
+			Value: cnf.KeyFile,
+		},
+		&cli.StringFlag{
+			Name:  "passphrase",
+			Usage: "`passphrase` used instead of a key file. Define with ENVSET_PASSPHRASE",
+		},
+	}
+}
+
+func encryptOptions(c *cli.Context, cnf *config.Config) (*envset.Cipher, envset.EncryptOptions, error) {
+	cipher, err := envset.LoadCipher(cliopts.Key(c, cliopts.KeyFileFlag, "passphrase"))
+	if err != nil {
+		return nil, envset.EncryptOptions{}, err
+	}
+
+	o := envset.EncryptOptions{
+		Filename: cliopts.EnvFile(c),
+		Format:   cliopts.String(c, cliopts.EnvFileFormatFlag),
+		Section:  c.String("env"),
+		Keys:     c.Args().Slice(),
+		//keep the [comments] sections as they are
+		CommentSectionNames: cnf.CommentSectionNames.Keys,
+	}
+	return cipher, o, nil
+}
+
+// GetRekeyCommand returns a new cli.Command for the
+// rekey command
+func GetRekeyCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "rekey",
+		Usage: "encrypt all encrypted values of the env file with a new key",
+		UsageText: `envset rekey --old-key [file] --new-key [file]
+
+EXAMPLE:
+   envset rekey --old-key .envset.key --new-key .envset.new.key`,
+		Description: "decrypt every encrypted value in every section of the env file with the old key " +
+			"and encrypt it with the new key, the file is only updated if all values can be decrypted",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "env-file", Value: cnf.Filename, Usage: "update encrypted values in `FILE`"},
+			&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file, only ini files can be updated"},
+			&cli.StringFlag{
+				Name:  "old-key",
+				Usage: "`file` with the current encryption key. Defaults to ENVSET_KEY_FILE",
+				Value: cnf.KeyFile,
+			},
+			&cli.StringFlag{
+				Name:  "old-passphrase",
+				Usage: "current `passphrase` used instead of a key file. Defaults to ENVSET_PASSPHRASE",
+			},
+			&cli.StringFlag{Name: "new-key", Usage: "`file` with the new encryption key"},
+			&cli.StringFlag{Name: "new-passphrase", Usage: "new `passphrase` used instead of a key file"},
+		},
+		Action: func(c *cli.Context) error {
+			oldCipher, err := envset.LoadCipher(cliopts.Key(c, "old-key", "old-passphrase"))
+			if err != nil {
+				return fmt.Errorf("old key: %w", err)
+			}
+
+			if c.String("new-key") == "" && c.String("new-passphrase") == "" {
+				return cli.Exit("rekey expects a --new-key or --new-passphrase", 1)
+			}
+
+			newCipher, err := envset.LoadCipher(c.String("new-key"), c.String("new-passphrase"))
+			if err != nil {
+				return fmt.Errorf("new key: %w", err)
+			}
+
+			results, err := envset.RekeyFile(oldCipher, newCipher, envset.RekeyOptions{
+				Filename:            cliopts.EnvFile(c),
+				Format:              cliopts.String(c, cliopts.EnvFileFormatFlag),
+				CommentSectionNames: cnf.CommentSectionNames.Keys,
+			})
+			if err != nil {
+				return err
+			}
+
+			total := 0
+			for _, r := range results {
+				fmt.Fprintf(c.App.Writer, "[%s] rotated %d values\n", r.Section, r.Count)
+				total += r.Count
+			}
+			fmt.Fprintf(c.App.Writer, "rotated %d values in %d sections\n", total, len(results))
+			return nil
+		},
+	}
+}
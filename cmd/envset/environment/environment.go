@@ -2,6 +2,8 @@ package environment
 
 import (
 	"fmt"
+	"slices"
+	"sort"
 
 	"github.com/goliatone/go-envset/cmd/envset/internal/cliopts"
 	"github.com/goliatone/go-envset/pkg/config"
@@ -10,6 +12,60 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
+// GetCommands returns a command for every environment of the env
+// file and the environments listed in .envsetrc. The usage of each
+// command shows how many keys the environment has.
+func GetCommands(o envset.RunOptions, ecmd exec.ExecCmd, cnf *config.Config) []*cli.Command {
+	//if we can't load the env file we still add the configured
+	//environments, running them will report the error
+	found, err := envset.ListEnvironments(o)
+
+	keys := make(map[string]int)
+	names := []string{}
+	for _, env := range found {
+		keys[env.Name] = env.Keys
+		names = append(names, env.Name)
+	}
+
+	for _, name := range cnf.EnvironmentNames() {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	commands := make([]*cli.Command, 0, len(names))
+	for _, name := range names {
+		cmd := GetCommand(name, ecmd, cnf)
+		cmd.Aliases = aliases(name, names, cnf)
+
+		n, ok := keys[name]
+		switch {
+		case err != nil:
+		case !ok:
+			cmd.Usage += " (not in env file)"
+		case n == 1:
+			cmd.Usage += " (1 key)"
+		default:
+			cmd.Usage += fmt.Sprintf(" (%d keys)", n)
+		}
+		commands = append(commands, cmd)
+	}
+	return commands
+}
+
+// aliases returns the aliases of an environment, aliases that
+// match an environment name are ignored.
+func aliases(env string, names []string, cnf *config.Config) []string {
+	out := []string{}
+	for alias, name := range cnf.Aliases {
+		if name == env && !slices.Contains(names, alias) {
+			out = append(out, alias)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
 // GetCommand export command
 func GetCommand(env string, ecmd exec.ExecCmd, cnf *config.Config) *cli.Command {
 
@@ -32,10 +88,42 @@ func GetCommand(env string, ecmd exec.ExecCmd, cnf *config.Config) *cli.Command
 				Usage: "if true we use expand environment variables",
 				Value: cnf.Expand,
 			},
-			&cli.StringFlag{
+			&cli.StringSliceFlag{
 				Name:  "env-file",
-				Usage: "file name with environment definition",
-				Value: cnf.Filename,
+				Usage: "file name with environment definition, repeat to merge files in order",
+				Value: cli.NewStringSlice(cnf.EnvFiles()...),
+			},
+			&cli.BoolFlag{
+				Name:  "local-overlays",
+				Usage: "merge .envset.local and .envset.<env>.local files if found next to the env file",
+				Value: cnf.LocalOverlays,
+			},
+			&cli.BoolFlag{
+				Name:  "sources",
+				Usage: "print the file each variable was loaded from",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "output `format` when printing the environment: bash, zsh, fish, dotenv, json, docker, systemd or github",
+			},
+			&cli.BoolFlag{
+				Name:  "reveal",
+				Usage: "print secret values instead of ****",
+			},
+			&cli.BoolFlag{
+				Name:  "mask-output",
+				Usage: "replace secret values in the command output with ****",
+				Value: cnf.MaskOutput,
+			},
+			&cli.StringFlag{
+				Name:  "key-file",
+				Usage: "`file` with the key to decrypt encrypted values. Define with ENVSET_KEY_FILE",
+				Value: cnf.KeyFile,
+			},
+			&cli.StringFlag{
+				Name:  "env-file-format",
+				Usage: "`format` of the env file, ini, dotenv, yaml, toml or json. Detected from the file name if not set",
+				Value: cnf.Format,
 			},
 			&cli.StringSliceFlag{
 				Name:    "required",
@@ -69,9 +157,56 @@ func GetCommand(env string, ecmd exec.ExecCmd, cnf *config.Config) *cli.Command
 				Usage:   "times to restart failed command",
 				Value:   cnf.MaxRestarts,
 			},
+			&cli.DurationFlag{
+				Name:  "restart-delay",
+				Usage: "wait `duration` before restarting, doubles on each restart",
+				Value: cnf.RestartDelay,
+			},
+			&cli.DurationFlag{
+				Name:  "restart-max-delay",
+				Usage: "max `duration` to wait before restarting",
+				Value: cnf.RestartMaxDelay,
+			},
+			&cli.Float64Flag{
+				Name:  "restart-jitter",
+				Usage: "randomize restart delay by a `factor` between 0 and 1",
+				Value: cnf.RestartJitter,
+			},
+			&cli.DurationFlag{
+				Name:  "restart-reset-after",
+				Usage: "reset restart count if command ran for at least `duration`",
+				Value: cnf.RestartResetAfter,
+			},
+			&cli.IntSliceFlag{
+				Name:  "restart-exit-code",
+				Usage: "only restart when command exits with `code`",
+				Value: cli.NewIntSlice(cnf.RestartExitCodes...),
+			},
+			&cli.IntSliceFlag{
+				Name:  "restart-ignore-exit-code",
+				Usage: "do not restart when command exits with `code`",
+				Value: cli.NewIntSlice(cnf.RestartIgnoreCodes...),
+			},
+			&cli.BoolFlag{
+				Name:  "process-group",
+				Usage: "run command in its own process group and forward signals to the group",
+			},
+			&cli.BoolFlag{
+				Name:  "watch",
+				Usage: "restart command when the env file or .envsetrc change the environment",
+			},
+			&cli.DurationFlag{
+				Name:  "watch-interval",
+				Usage: "`duration` between checks for changes in watched files",
+				Value: cnf.WatchInterval,
+			},
+			&cli.DurationFlag{
+				Name:  "stop-timeout",
+				Usage: "`duration` to wait for the command to exit after SIGTERM before it is killed",
+				Value: cnf.StopTimeout,
+			},
 		},
 		Action: func(c *cli.Context) error {
-			//TODO: we want to support .env.local => [local]
 			env := c.Command.Name
 
 			o := cliopts.RunOptions(c, cnf, env, ecmd)
@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/goliatone/go-envset/pkg/config"
+	"github.com/goliatone/go-envset/pkg/envset"
 	"github.com/goliatone/go-envset/pkg/exec"
 	"github.com/urfave/cli/v2"
 )
@@ -114,6 +117,33 @@ func TestGlobalRestartFlagFalseOverridesEnvironmentDefault(t *testing.T) {
 	}
 }
 
+func TestGetCommandsDiscoversEnvironments(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".envset")
+	if err := os.WriteFile(filename, []byte("[development]\nA=1\nB=2\n\n[qa]\nC=3\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	cnf := testConfig()
+	cnf.Environments.Names = []string{"development", "production"}
+	cnf.Aliases = map[string]string{"dev": "development", "local": "development", "qa": "production"}
+
+	commands := GetCommands(envset.RunOptions{Filename: filename}, exec.ExecCmd{}, cnf)
+
+	got := map[string]string{}
+	for _, cmd := range commands {
+		got[strings.Join(cmd.Names(), ",")] = cmd.Usage
+	}
+
+	want := map[string]string{
+		"development,dev,local": `load "development" environment in current shell session (2 keys)`,
+		"qa":                    `load "qa" environment in current shell session (1 key)`,
+		"production":            `load "production" environment in current shell session (not in env file)`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
 func testConfig() *config.Config {
 	return &config.Config{
 		Filename:            ".envset",
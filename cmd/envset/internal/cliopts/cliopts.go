@@ -2,8 +2,12 @@
 package cliopts
 
 import (
+	"fmt"
 	"math"
+	"os"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/goliatone/go-envset/pkg/config"
 	"github.com/goliatone/go-envset/pkg/envset"
@@ -13,19 +17,36 @@ import (
 
 const (
 	// Run flag names shared by the app and environment commands.
-	EnvFileFlag        = "env-file"
-	IsolatedFlag       = "isolated"
-	ExpandFlag         = "expand"
-	RequiredFlag       = "required"
-	RequiredAlias      = "R"
-	ExportEnvNameFlag  = "export-env-name"
-	ExportEnvNameAlias = "N"
-	InheritFlag        = "inherit"
-	InheritAlias       = "I"
-	RestartFlag        = "restart"
-	ForeverFlag        = "forever"
-	MaxRestartsFlag    = "max-restarts"
-	MaxRestartAlias    = "max-restart"
+	EnvFileFlag           = "env-file"
+	EnvFileFormatFlag     = "env-file-format"
+	IsolatedFlag          = "isolated"
+	ExpandFlag            = "expand"
+	RequiredFlag          = "required"
+	RequiredAlias         = "R"
+	ExportEnvNameFlag     = "export-env-name"
+	ExportEnvNameAlias    = "N"
+	InheritFlag           = "inherit"
+	InheritAlias          = "I"
+	RestartFlag           = "restart"
+	ForeverFlag           = "forever"
+	MaxRestartsFlag       = "max-restarts"
+	MaxRestartAlias       = "max-restart"
+	ProcessGroupFlag      = "process-group"
+	RestartDelayFlag      = "restart-delay"
+	RestartMaxDelayFlag   = "restart-max-delay"
+	RestartJitterFlag     = "restart-jitter"
+	RestartResetAfterFlag = "restart-reset-after"
+	RestartExitCodeFlag   = "restart-exit-code"
+	RestartIgnoreCodeFlag = "restart-ignore-exit-code"
+	WatchFlag             = "watch"
+	WatchIntervalFlag     = "watch-interval"
+	StopTimeoutFlag       = "stop-timeout"
+	LocalOverlaysFlag     = "local-overlays"
+	SourcesFlag           = "sources"
+	FormatFlag            = "format"
+	KeyFileFlag           = "key-file"
+	RevealFlag            = "reveal"
+	MaskOutputFlag        = "mask-output"
 )
 
 // RunOptions resolves command flags across local and parent cli contexts.
@@ -35,24 +56,175 @@ const (
 // duplicate flag names from child to parent, so direct c.Bool/c.String calls on
 // environment commands hide explicit global values behind local defaults.
 func RunOptions(c *cli.Context, cnf *config.Config, env string, ecmd exec.ExecCmd) envset.RunOptions {
+	keyFile, passphrase := Key(c, KeyFileFlag, "")
 	required := StringSlice(c, RequiredFlag, RequiredAlias)
 	required = cnf.MergeRequired(env, required)
 
 	restart, maxRestarts := RestartOptions(c)
 
-	return envset.RunOptions{
+	files := EnvFiles(c)
+	if len(files) == 0 {
+		files = cnf.EnvFiles()
+	}
+
+	o := envset.RunOptions{
 		Cmd:                 ecmd.Cmd,
 		Args:                ecmd.Args,
 		Isolated:            Bool(c, IsolatedFlag),
 		Expand:              Bool(c, ExpandFlag),
-		Filename:            String(c, EnvFileFlag),
+		Filename:            files[0],
+		Overlays:            files[1:],
+		LocalOverlays:       Bool(c, LocalOverlaysFlag),
+		ShowSources:         Bool(c, SourcesFlag),
+		PrintFormat:         String(c, FormatFlag),
+		KeyFile:             keyFile,
+		Passphrase:          passphrase,
+		SecretKeys:          cnf.SecretPatterns(),
+		Reveal:              Bool(c, RevealFlag),
+		MaskOutput:          Bool(c, MaskOutputFlag),
+		Schema:              cnf.SchemaFile(files[0]),
+		Format:              String(c, EnvFileFormatFlag),
 		CommentSectionNames: cnf.CommentSectionNames.Keys,
 		Required:            required,
 		Inherit:             StringSlice(c, InheritFlag, InheritAlias),
 		ExportEnvName:       String(c, ExportEnvNameFlag, ExportEnvNameAlias),
 		Restart:             restart,
 		MaxRestarts:         maxRestarts,
+		RestartPolicy:       RestartPolicy(c),
+		ProcessGroup:        Bool(c, ProcessGroupFlag),
+		Watch:               Bool(c, WatchFlag),
+		WatchFiles:          watchFiles(cnf),
+		WatchInterval:       Duration(c, WatchIntervalFlag),
+		StopTimeout:         Duration(c, StopTimeoutFlag),
+	}
+
+	if cnf.Name != "" {
+		o.Reload = reloadOptions(c, cnf.Name, env, ecmd)
+	}
+	return o
+}
+
+// reloadOptions loads the configuration file again and builds the
+// run options, options not set with a flag use the new configuration.
+func reloadOptions(c *cli.Context, name, env string, ecmd exec.ExecCmd) func() (envset.RunOptions, error) {
+	return func() (envset.RunOptions, error) {
+		cnf, err := config.Load(name)
+		if err != nil {
+			return envset.RunOptions{}, fmt.Errorf("load %s: %w", name, err)
+		}
+
+		o := RunOptions(c, cnf, env, ecmd)
+		if !isSet(c, EnvFileFlag) {
+			files := cnf.EnvFiles()
+			o.Filename, o.Overlays = files[0], files[1:]
+			o.Schema = cnf.SchemaFile(files[0])
+		}
+		if !isSet(c, EnvFileFormatFlag) {
+			o.Format = cnf.Format
+		}
+		if !isSet(c, LocalOverlaysFlag) {
+			o.LocalOverlays = cnf.LocalOverlays
+		}
+		if !isSet(c, IsolatedFlag) {
+			o.Isolated = cnf.Isolated
+		}
+		if !isSet(c, ExpandFlag) {
+			o.Expand = cnf.Expand
+		}
+		if !isSet(c, MaskOutputFlag) {
+			o.MaskOutput = cnf.MaskOutput
+		}
+		if !isSet(c, ExportEnvNameFlag, ExportEnvNameAlias) {
+			o.ExportEnvName = cnf.ExportEnvName
+		}
+		return o, nil
+	}
+}
+
+// watchFiles returns the files that should trigger a reload
+// in watch mode besides the env file, i.e. the .envsetrc file.
+func watchFiles(cnf *config.Config) []string {
+	if cnf.Name == "" {
+		return nil
+	}
+	return []string{cnf.Name}
+}
+
+// DiscoverOptions returns the options used to find the environments
+// of the env file before the cli app parses its flags. Env file flags
+// in args take precedence over the configuration.
+func DiscoverOptions(args []string, cnf *config.Config) envset.RunOptions {
+	files := []string{}
+	format := cnf.Format
+
+	for i := 0; i < len(args); i++ {
+		name, value, ok := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || (name != EnvFileFlag && name != EnvFileFormatFlag) {
+			continue
+		}
+		if !ok {
+			if i+1 >= len(args) {
+				break
+			}
+			i++
+			value = args[i]
+		}
+
+		if name == EnvFileFlag {
+			files = append(files, value)
+		} else {
+			format = value
+		}
+	}
+
+	if len(files) == 0 {
+		files = cnf.EnvFiles()
+	}
+
+	comments := []string{}
+	if cnf.CommentSectionNames != nil {
+		comments = cnf.CommentSectionNames.Keys
+	}
+
+	return envset.RunOptions{
+		Filename:            files[0],
+		Overlays:            files[1:],
+		Format:              format,
+		LocalOverlays:       cnf.LocalOverlays,
+		CommentSectionNames: comments,
+	}
+}
+
+// EnvFiles resolves the env file flag. It can be repeated in the app and
+// environment commands, other commands take a single env file.
+func EnvFiles(c *cli.Context) []string {
+	for _, ctx := range c.Lineage() {
+		if !hasLocalFlag(ctx, EnvFileFlag) {
+			continue
+		}
+		return envFilesValue(ctx)
+	}
+	return envFilesValue(c)
+}
+
+// EnvFile resolves the first env file from the env file flag.
+func EnvFile(c *cli.Context) string {
+	if files := EnvFiles(c); len(files) > 0 {
+		return files[0]
 	}
+	return ""
+}
+
+func envFilesValue(c *cli.Context) []string {
+	switch v := c.Value(EnvFileFlag).(type) {
+	case cli.StringSlice:
+		return v.Value()
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	}
+	return nil
 }
 
 // RestartOptions resolves restart behavior from duplicated restart flags.
@@ -83,6 +255,18 @@ func RestartOptions(c *cli.Context) (bool, int) {
 	return restart, math.MaxInt
 }
 
+// RestartPolicy resolves the restart policy from the restart flags.
+func RestartPolicy(c *cli.Context) envset.RestartPolicy {
+	return envset.RestartPolicy{
+		Delay:           Duration(c, RestartDelayFlag),
+		MaxDelay:        Duration(c, RestartMaxDelayFlag),
+		Jitter:          Float64(c, RestartJitterFlag),
+		ResetAfter:      Duration(c, RestartResetAfterFlag),
+		ExitCodes:       IntSlice(c, RestartExitCodeFlag),
+		IgnoreExitCodes: IntSlice(c, RestartIgnoreCodeFlag),
+	}
+}
+
 // Bool resolves a bool flag, preferring explicit flags from child to parent.
 func Bool(c *cli.Context, name string, aliases ...string) bool {
 	if ok, value := explicitBool(c, name, aliases...); ok {
@@ -91,6 +275,27 @@ func Bool(c *cli.Context, name string, aliases ...string) bool {
 	return c.Bool(name)
 }
 
+// Key returns the key file or the passphrase used to encrypt and
+// decrypt values, only one of them is set. Flags set in the command
+// line take precedence over ENVSET_PASSPHRASE and ENVSET_KEY_FILE,
+// and those over the key_file of the rc file. The passphrase flag
+// is optional, pass an empty name for commands without one.
+func Key(c *cli.Context, keyFlag, passphraseFlag string) (string, string) {
+	if passphraseFlag != "" && isSet(c, passphraseFlag) {
+		return "", String(c, passphraseFlag)
+	}
+	if isSet(c, keyFlag) {
+		return String(c, keyFlag), ""
+	}
+	if passphrase := os.Getenv(envset.PassphraseEnv); passphrase != "" {
+		return "", passphrase
+	}
+	if keyFile := os.Getenv(envset.KeyFileEnv); keyFile != "" {
+		return keyFile, ""
+	}
+	return String(c, keyFlag), ""
+}
+
 // String resolves a string flag, preferring explicit flags from child to parent.
 func String(c *cli.Context, name string, aliases ...string) string {
 	names := append([]string{name}, aliases...)
@@ -127,6 +332,42 @@ func Int(c *cli.Context, name string, aliases ...string) int {
 	return c.Int(name)
 }
 
+// Duration resolves a duration flag, preferring explicit flags from child to parent.
+func Duration(c *cli.Context, name string, aliases ...string) time.Duration {
+	names := append([]string{name}, aliases...)
+	for _, ctx := range c.Lineage() {
+		if !hasLocalFlag(ctx, names...) {
+			continue
+		}
+		return ctx.Duration(name)
+	}
+	return c.Duration(name)
+}
+
+// Float64 resolves a float flag, preferring explicit flags from child to parent.
+func Float64(c *cli.Context, name string, aliases ...string) float64 {
+	names := append([]string{name}, aliases...)
+	for _, ctx := range c.Lineage() {
+		if !hasLocalFlag(ctx, names...) {
+			continue
+		}
+		return ctx.Float64(name)
+	}
+	return c.Float64(name)
+}
+
+// IntSlice resolves an int-slice flag, preferring explicit flags from child to parent.
+func IntSlice(c *cli.Context, name string, aliases ...string) []int {
+	names := append([]string{name}, aliases...)
+	for _, ctx := range c.Lineage() {
+		if !hasLocalFlag(ctx, names...) {
+			continue
+		}
+		return ctx.IntSlice(name)
+	}
+	return c.IntSlice(name)
+}
+
 func explicitBool(c *cli.Context, name string, aliases ...string) (bool, bool) {
 	names := append([]string{name}, aliases...)
 	for _, ctx := range c.Lineage() {
@@ -138,6 +379,17 @@ func explicitBool(c *cli.Context, name string, aliases ...string) (bool, bool) {
 	return false, false
 }
 
+// isSet returns true if the flag was set in the command
+// line of the command or any of its parents
+func isSet(c *cli.Context, names ...string) bool {
+	for _, ctx := range c.Lineage() {
+		if hasLocalFlag(ctx, names...) {
+			return true
+		}
+	}
+	return false
+}
+
 func hasLocalFlag(c *cli.Context, names ...string) bool {
 	if c == nil {
 		return false
@@ -2,8 +2,11 @@ package cliopts
 
 import (
 	"math"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/goliatone/go-envset/pkg/config"
 	"github.com/goliatone/go-envset/pkg/envset"
@@ -109,6 +112,61 @@ func TestRunOptionsGlobalFlagsOverrideCommandDefaults(t *testing.T) {
 				assertEqual(t, got.run.MaxRestarts, 2)
 			},
 		},
+		{
+			name: "restart policy",
+			args: []string{
+				"--restart-delay=1s",
+				"--restart-max-delay=1m",
+				"--restart-jitter=0.5",
+				"--restart-reset-after=10m",
+				"--restart-exit-code=1",
+				"--restart-ignore-exit-code=2",
+				"development",
+			},
+			validate: func(t *testing.T, got resolvedOptions) {
+				assertDeepEqual(t, got.run.RestartPolicy, envset.RestartPolicy{
+					Delay:           time.Second,
+					MaxDelay:        time.Minute,
+					Jitter:          0.5,
+					ResetAfter:      10 * time.Minute,
+					ExitCodes:       []int{1},
+					IgnoreExitCodes: []int{2},
+				})
+			},
+		},
+		{
+			name: "watch",
+			args: []string{"--watch", "--watch-interval=1s", "development"},
+			validate: func(t *testing.T, got resolvedOptions) {
+				assertEqual(t, got.run.Watch, true)
+				assertEqual(t, got.run.WatchInterval, time.Second)
+			},
+		},
+		{
+			name: "stop timeout",
+			args: []string{"--stop-timeout=3s", "development"},
+			validate: func(t *testing.T, got resolvedOptions) {
+				assertEqual(t, got.run.StopTimeout, 3*time.Second)
+			},
+		},
+		{
+			name: "layered env files",
+			args: []string{"--env-file=.envset", "--env-file=.envset.shared", "--local-overlays=false", "--sources", "development"},
+			validate: func(t *testing.T, got resolvedOptions) {
+				assertEqual(t, got.run.Filename, ".envset")
+				assertDeepEqual(t, got.run.Overlays, []string{".envset.shared"})
+				assertEqual(t, got.run.LocalOverlays, false)
+				assertEqual(t, got.run.ShowSources, true)
+			},
+		},
+		{
+			name: "redaction",
+			args: []string{"--reveal", "--mask-output", "development"},
+			validate: func(t *testing.T, got resolvedOptions) {
+				assertEqual(t, got.run.Reveal, true)
+				assertEqual(t, got.run.MaskOutput, true)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -203,6 +261,8 @@ func TestRunOptionsDefaults(t *testing.T) {
 	got := runResolverApp(t, []string{"development"})
 
 	assertEqual(t, got.run.Filename, ".envset")
+	assertDeepEqual(t, got.run.Overlays, []string{})
+	assertEqual(t, got.run.LocalOverlays, true)
 	assertEqual(t, got.run.Isolated, true)
 	assertEqual(t, got.run.Expand, true)
 	assertDeepEqual(t, got.run.Required, []string(nil))
@@ -212,6 +272,46 @@ func TestRunOptionsDefaults(t *testing.T) {
 	assertEqual(t, got.run.MaxRestarts, 3)
 }
 
+func TestRunOptionsReload(t *testing.T) {
+	rc := filepath.Join(t.TempDir(), ".envsetrc")
+	if err := os.WriteFile(rc, []byte("expand=true\n"), 0644); err != nil {
+		t.Fatalf("write rc: %v", err)
+	}
+
+	cnf, err := config.Load(rc)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+
+	var got envset.RunOptions
+	app := cli.NewApp()
+	app.Flags = testRunFlags(cnf.Filename, cnf.Isolated, cnf.Expand, cnf.ExportEnvName, true, false, 3)
+	app.Action = func(c *cli.Context) error {
+		o := RunOptions(c, cnf, "development", exec.ExecCmd{Cmd: "sh"})
+		if o.Reload == nil {
+			t.Fatal("expected Reload to be set")
+		}
+
+		contents := "filename=.env\nexpand=false\nisolated=false\nexport_environment=ENVSET_ENV\n[required]\ndevelopment=DB_URL\n"
+		if err := os.WriteFile(rc, []byte(contents), 0644); err != nil {
+			t.Fatalf("write rc: %v", err)
+		}
+
+		got, err = o.Reload()
+		return err
+	}
+
+	if err := app.Run([]string{"envset", "--isolated=true"}); err != nil {
+		t.Fatalf("run app: %v", err)
+	}
+
+	assertEqual(t, got.Filename, ".env")
+	assertEqual(t, got.Expand, false)
+	assertEqual(t, got.Isolated, true)
+	assertEqual(t, got.ExportEnvName, "ENVSET_ENV")
+	assertDeepEqual(t, got.Required, []string{"DB_URL"})
+}
+
 type resolvedOptions struct {
 	run envset.RunOptions
 }
@@ -253,9 +353,29 @@ func runResolverApp(t *testing.T, args []string) resolvedOptions {
 	return got
 }
 
+func TestDiscoverOptions(t *testing.T) {
+	cnf := &config.Config{
+		Filename:            ".envset",
+		Overlays:            []string{".envset.shared"},
+		LocalOverlays:       true,
+		CommentSectionNames: &config.CommentSectionNames{Keys: []string{"COMMENTS"}},
+	}
+
+	got := DiscoverOptions([]string{"envset", "development"}, cnf)
+	assertEqual(t, got.Filename, ".envset")
+	assertDeepEqual(t, got.Overlays, []string{".envset.shared"})
+	assertEqual(t, got.LocalOverlays, true)
+	assertDeepEqual(t, got.CommentSectionNames, []string{"COMMENTS"})
+
+	got = DiscoverOptions([]string{"envset", "--env-file=base.yaml", "qa", "--env-file", "qa.yaml", "--env-file-format", "yaml"}, cnf)
+	assertEqual(t, got.Filename, "base.yaml")
+	assertDeepEqual(t, got.Overlays, []string{"qa.yaml"})
+	assertEqual(t, got.Format, "yaml")
+}
+
 func testRunFlags(filename string, isolated, expand bool, exportEnvName string, restart, forever bool, maxRestarts int) []cli.Flag {
 	return []cli.Flag{
-		&cli.StringFlag{Name: EnvFileFlag, Value: filename},
+		&cli.StringSliceFlag{Name: EnvFileFlag, Value: cli.NewStringSlice(filename)},
 		&cli.BoolFlag{Name: IsolatedFlag, Value: isolated},
 		&cli.BoolFlag{Name: ExpandFlag, Value: expand},
 		&cli.StringSliceFlag{Name: RequiredFlag, Aliases: []string{RequiredAlias}},
@@ -264,6 +384,19 @@ func testRunFlags(filename string, isolated, expand bool, exportEnvName string,
 		&cli.BoolFlag{Name: RestartFlag, Value: restart},
 		&cli.BoolFlag{Name: ForeverFlag, Value: forever},
 		&cli.IntFlag{Name: MaxRestartsFlag, Aliases: []string{MaxRestartAlias}, Value: maxRestarts},
+		&cli.DurationFlag{Name: RestartDelayFlag},
+		&cli.DurationFlag{Name: RestartMaxDelayFlag},
+		&cli.Float64Flag{Name: RestartJitterFlag},
+		&cli.DurationFlag{Name: RestartResetAfterFlag},
+		&cli.IntSliceFlag{Name: RestartExitCodeFlag},
+		&cli.IntSliceFlag{Name: RestartIgnoreCodeFlag},
+		&cli.BoolFlag{Name: WatchFlag},
+		&cli.DurationFlag{Name: WatchIntervalFlag},
+		&cli.DurationFlag{Name: StopTimeoutFlag},
+		&cli.BoolFlag{Name: LocalOverlaysFlag, Value: true},
+		&cli.BoolFlag{Name: SourcesFlag},
+		&cli.BoolFlag{Name: RevealFlag},
+		&cli.BoolFlag{Name: MaskOutputFlag},
 	}
 }
 
@@ -0,0 +1,493 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/goliatone/go-envset/pkg/envset"
+)
+
+const (
+	//FormatJUnit is JUnit XML, one test case per check
+	FormatJUnit = "junit"
+	//FormatSARIF is SARIF 2.1.0, one result per failed check
+	FormatSARIF = "sarif"
+	//FormatMarkdown is a Markdown table to post as a PR comment
+	FormatMarkdown = "markdown"
+)
+
+// Formats are the formats Write supports
+var Formats = []string{FormatJUnit, FormatSARIF, FormatMarkdown}
+
+const (
+	//RuleMissingInSource a key or section is only in the target
+	RuleMissingInSource = "missing-in-source"
+	//RuleMissingInTarget a key or section is only in the source
+	RuleMissingInTarget = "missing-in-target"
+	//RuleDifferentValue a key has different values
+	RuleDifferentValue = "different-value"
+	//RuleDifferentComment a key or section has different comments
+	RuleDifferentComment = "different-comment"
+	//RuleInvalidValue a key does not match its schema
+	RuleInvalidValue = "invalid-value"
+)
+
+var ruleDescriptions = map[string]string{
+	RuleMissingInSource:  "Key or section missing in source",
+	RuleMissingInTarget:  "Key or section missing in target",
+	RuleDifferentValue:   "Key with different values",
+	RuleDifferentComment: "Key or section with different comments",
+	RuleInvalidValue:     "Key with a value that does not match the schema",
+}
+
+// Check is a single check of a report, e.g. a key of a section.
+// Validation commands can turn their results into checks to
+// use the same formats.
+type Check struct {
+	//Suite groups checks, e.g. the section name
+	Suite string
+	//Name of the check, e.g. the key name. Empty
+	//if the check is about the whole suite.
+	Name string
+	//File the check refers to
+	File    string
+	Rule    string
+	Message string
+	Failed  bool
+	Skipped bool
+}
+
+func (c Check) name() string {
+	if c.Name == "" {
+		return c.Suite
+	}
+	return c.Name
+}
+
+func (c Check) qualifiedName() string {
+	if c.Name == "" {
+		return c.Suite
+	}
+	return c.Suite + "." + c.Name
+}
+
+// message returns the message prefixed with the suite
+// if the check is not about the whole suite
+func (c Check) message() string {
+	if c.Name == "" {
+		return c.Message
+	}
+	return fmt.Sprintf("[%s] %s", c.Suite, c.Message)
+}
+
+// IsFormat returns true if Write supports format
+func IsFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed returns the number of failed checks
+func Failed(checks []Check) int {
+	count := 0
+	for _, c := range checks {
+		if c.Failed {
+			count++
+		}
+	}
+	return count
+}
+
+// Write writes checks to w in the given format, title names
+// the report, e.g. the command that made the checks.
+func Write(w io.Writer, format, title string, checks []Check) error {
+	switch format {
+	case FormatJUnit:
+		return writeJUnit(w, title, checks)
+	case FormatSARIF:
+		return writeSARIF(w, checks)
+	case FormatMarkdown:
+		return writeMarkdown(w, title, checks)
+	}
+	return fmt.Errorf("unknown format %q, expected %s", format, strings.Join(Formats, ", "))
+}
+
+// SectionChecks returns a check for every key of a diff made
+// by CompareSections. Ignored keys are skipped checks.
+func SectionChecks(diff envset.EnvSection, source, target string) []Check {
+	checks := make([]Check, 0, len(diff.Keys)+len(diff.Ignored))
+	for _, k := range diff.Keys {
+		checks = append(checks, keyCheck(diff.Name, k, source, target))
+	}
+
+	for _, k := range diff.Ignored {
+		c := keyCheck(diff.Name, k, source, target)
+		c.Failed = false
+		c.Skipped = true
+		c.Message = fmt.Sprintf("ignored, %s", k.Comment)
+		checks = append(checks, c)
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, Check{Suite: diff.Name, File: target, Message: "same keys and values"})
+	}
+	return checks
+}
+
+// ComparisonChecks returns the checks of every section of a
+// comparison made by CompareAllSections, sections that are
+// missing in one file are a failed check.
+func ComparisonChecks(res envset.MetadataComparison, source, target string) []Check {
+	checks := make([]Check, 0, len(res.Sections))
+	for _, s := range res.Sections {
+		switch s.Status {
+		case envset.SectionMissingInSource:
+			checks = append(checks, Check{
+				Suite:   s.Name,
+				File:    target,
+				Rule:    RuleMissingInSource,
+				Message: fmt.Sprintf("section [%s] is missing in %s", s.Name, source),
+				Failed:  true,
+			})
+		case envset.SectionMissingInTarget:
+			checks = append(checks, Check{
+				Suite:   s.Name,
+				File:    source,
+				Rule:    RuleMissingInTarget,
+				Message: fmt.Sprintf("section [%s] is missing in %s", s.Name, target),
+				Failed:  true,
+			})
+		default:
+			checks = append(checks, SectionChecks(envset.EnvSection{
+				Name:    s.Name,
+				Keys:    s.Keys,
+				Ignored: s.IgnoredKeys,
+			}, source, target)...)
+		}
+	}
+	return checks
+}
+
+// MetadataDiffChecks returns the checks of a diff made by
+// VerifyMetadataFile, source is the env file and target the
+// metadata file.
+func MetadataDiffChecks(diff envset.MetadataDiff, source, target string) []Check {
+	checks := []Check{}
+	for _, name := range diff.SectionsAdded {
+		checks = append(checks, Check{
+			Suite:   name,
+			File:    target,
+			Rule:    RuleMissingInTarget,
+			Message: fmt.Sprintf("section [%s] is missing in %s", name, target),
+			Failed:  true,
+		})
+	}
+	for _, name := range diff.SectionsRemoved {
+		checks = append(checks, Check{
+			Suite:   name,
+			File:    source,
+			Rule:    RuleMissingInSource,
+			Message: fmt.Sprintf("section [%s] is missing in %s", name, source),
+			Failed:  true,
+		})
+	}
+
+	for _, s := range diff.Sections {
+		if s.Comment != nil {
+			checks = append(checks, Check{
+				Suite:   s.Name,
+				File:    target,
+				Rule:    RuleDifferentComment,
+				Message: fmt.Sprintf("section [%s] has different comments in %s and %s", s.Name, source, target),
+				Failed:  true,
+			})
+		}
+
+		add := func(keys []string, file, rule, format string, args ...any) {
+			for _, k := range keys {
+				checks = append(checks, Check{
+					Suite:   s.Name,
+					Name:    k,
+					File:    file,
+					Rule:    rule,
+					Message: fmt.Sprintf("%s "+format, append([]any{k}, args...)...),
+					Failed:  true,
+				})
+			}
+		}
+		add(s.KeysAdded, target, RuleMissingInTarget, "is missing in %s", target)
+		add(s.KeysRemoved, source, RuleMissingInSource, "is missing in %s", source)
+		add(s.KeysChanged, target, RuleDifferentValue, "has different values in %s and %s", source, target)
+		add(s.KeyComments, target, RuleDifferentComment, "has different comments in %s and %s", source, target)
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, Check{Suite: source, File: target, Message: fmt.Sprintf("%s matches %s", source, target)})
+	}
+	return checks
+}
+
+// ValidationChecks returns a check for every key of a validation
+// report made by ValidateEnvironment, file is the env file.
+func ValidationChecks(r envset.ValidationReport, file string) []Check {
+	checks := make([]Check, 0, len(r.Results))
+	for _, res := range r.Results {
+		c := Check{Suite: r.Environment, Name: res.Key.Name, File: file, Message: "ok"}
+		if res.Error != "" {
+			c.Rule = RuleInvalidValue
+			c.Message = fmt.Sprintf("%s: %s", res.Key.Name, res.Error)
+			c.Failed = true
+		}
+		checks = append(checks, c)
+	}
+
+	if len(checks) == 0 {
+		checks = append(checks, Check{Suite: r.Environment, File: file, Message: "no keys in schema"})
+	}
+	return checks
+}
+
+func keyCheck(section string, k *envset.EnvKey, source, target string) Check {
+	c := Check{Suite: section, Name: k.Name, Failed: true}
+	switch {
+	case strings.Contains(k.Comment, "missing"):
+		c.File = target
+		c.Rule = RuleMissingInSource
+		c.Message = fmt.Sprintf("%s is missing in %s", k.Name, source)
+	case strings.Contains(k.Comment, "extra"):
+		c.File = source
+		c.Rule = RuleMissingInTarget
+		c.Message = fmt.Sprintf("%s is missing in %s", k.Name, target)
+	case strings.Contains(k.Comment, "different"):
+		c.File = target
+		c.Rule = RuleDifferentValue
+		c.Message = fmt.Sprintf("%s has different values in %s and %s", k.Name, source, target)
+	default:
+		c.File = target
+		c.Failed = false
+		c.Message = k.Comment
+	}
+	return c
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func writeJUnit(w io.Writer, title string, checks []Check) error {
+	res := junitTestSuites{Name: title}
+	index := make(map[string]int)
+
+	for _, c := range checks {
+		i, ok := index[c.Suite]
+		if !ok {
+			i = len(res.Suites)
+			index[c.Suite] = i
+			res.Suites = append(res.Suites, junitTestSuite{Name: c.Suite})
+		}
+
+		tc := junitTestCase{ClassName: c.Suite, Name: c.name(), File: c.File}
+		suite := &res.Suites[i]
+		suite.Tests++
+		res.Tests++
+
+		if c.Failed {
+			tc.Failure = &junitFailure{Type: c.Rule, Message: c.Message}
+			suite.Failures++
+			res.Failures++
+		} else if c.Skipped {
+			tc.Skipped = &junitSkipped{Message: c.Message}
+			suite.Skipped++
+			res.Skipped++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	b, err := xml.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return fmt.Errorf("junit xml marshall: %w", err)
+	}
+
+	_, err = fmt.Fprintf(w, "%s%s\n", xml.Header, b)
+	return err
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+func writeSARIF(w io.Writer, checks []Check) error {
+	driver := sarifDriver{
+		Name:           "envset",
+		InformationURI: "https://github.com/goliatone/go-envset",
+		Rules:          make([]sarifRule, 0),
+	}
+	results := make([]sarifResult, 0)
+	rules := make(map[string]bool)
+
+	for _, c := range checks {
+		if !c.Failed {
+			continue
+		}
+
+		if !rules[c.Rule] {
+			rules[c.Rule] = true
+			driver.Rules = append(driver.Rules, sarifRule{
+				ID:               c.Rule,
+				ShortDescription: sarifMessage{Text: ruleDescriptions[c.Rule]},
+			})
+		}
+
+		results = append(results, sarifResult{
+			RuleID:  c.Rule,
+			Level:   "error",
+			Message: sarifMessage{Text: c.message()},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: c.File}},
+				LogicalLocations: []sarifLogicalLocation{{Name: c.name(), FullyQualifiedName: c.qualifiedName()}},
+			}},
+		})
+	}
+
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(log); err != nil {
+		return fmt.Errorf("sarif json marshall: %w", err)
+	}
+	return nil
+}
+
+func writeMarkdown(w io.Writer, title string, checks []Check) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### %s\n\n", title)
+
+	failed := Failed(checks)
+	if failed == 0 {
+		b.WriteString("✅ All checks passed\n")
+	} else if failed == 1 {
+		b.WriteString("❌ 1 check failed\n")
+	} else {
+		fmt.Fprintf(&b, "❌ %d checks failed\n", failed)
+	}
+
+	rows := 0
+	for _, c := range checks {
+		if !c.Failed && !c.Skipped {
+			continue
+		}
+		if rows == 0 {
+			b.WriteString("\n| Status | Section | Key | Details |\n| --- | --- | --- | --- |\n")
+		}
+		rows++
+
+		status := "❌ " + c.Rule
+		if c.Skipped {
+			status = "🤷 ignored"
+		}
+		key := ""
+		if c.Name != "" {
+			key = "`" + c.Name + "`"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", status, markdownEscape(c.Suite), key, markdownEscape(c.Message))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,150 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/goliatone/go-envset/pkg/envset"
+)
+
+func TestComparisonChecks(t *testing.T) {
+	res := envset.MetadataComparison{Sections: []*envset.SectionComparison{
+		{
+			Name:   "development",
+			Status: envset.SectionDifferent,
+			Keys: []*envset.EnvKey{
+				{Name: "A", Comment: "different hash value"},
+				{Name: "B", Comment: "missing in source"},
+				{Name: "C", Comment: "extra in source"},
+			},
+			IgnoredKeys: []*envset.EnvKey{{Name: "D", Comment: "different hash value"}},
+		},
+		{Name: "qa", Status: envset.SectionOK},
+		{Name: "production", Status: envset.SectionMissingInTarget},
+	}}
+
+	checks := ComparisonChecks(res, "source.json", "target.json")
+
+	got := []string{}
+	for _, c := range checks {
+		got = append(got, strings.Join([]string{c.Suite, c.Name, c.Rule, c.File}, ":"))
+	}
+	want := []string{
+		"development:A:different-value:target.json",
+		"development:B:missing-in-source:target.json",
+		"development:C:missing-in-target:source.json",
+		"development:D:different-value:target.json",
+		"qa:::target.json",
+		"production::missing-in-target:source.json",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if Failed(checks) != 4 || !checks[3].Skipped {
+		t.Fatalf("expected 4 failed checks and D skipped, got %+v", checks)
+	}
+}
+
+func TestMetadataDiffChecks(t *testing.T) {
+	diff := envset.MetadataDiff{
+		SectionsAdded:   []string{"qa"},
+		SectionsRemoved: []string{"staging"},
+		Sections: []*envset.SectionDiff{{
+			Name:        "development",
+			KeysAdded:   []string{"A"},
+			KeysRemoved: []string{"B"},
+			KeysChanged: []string{"C"},
+			KeyComments: []string{"D"},
+		}},
+	}
+
+	got := []string{}
+	for _, c := range MetadataDiffChecks(diff, ".envset", "data.json") {
+		got = append(got, strings.Join([]string{c.Suite, c.Name, c.Rule, c.File}, ":"))
+	}
+	want := []string{
+		"qa::missing-in-target:data.json",
+		"staging::missing-in-source:.envset",
+		"development:A:missing-in-target:data.json",
+		"development:B:missing-in-source:.envset",
+		"development:C:different-value:data.json",
+		"development:D:different-comment:data.json",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	checks := MetadataDiffChecks(envset.MetadataDiff{}, ".envset", "data.json")
+	if len(checks) != 1 || Failed(checks) != 0 {
+		t.Fatalf("expected a passing check, got %+v", checks)
+	}
+}
+
+func TestValidationChecks(t *testing.T) {
+	res := envset.ValidationReport{Environment: "production", Results: []envset.KeyValidation{
+		{Key: &envset.SchemaKey{Name: "PORT"}},
+		{Key: &envset.SchemaKey{Name: "LOG_LEVEL"}, Error: "expected one of debug, info"},
+	}}
+
+	checks := ValidationChecks(res, ".envset")
+	if len(checks) != 2 || Failed(checks) != 1 {
+		t.Fatalf("expected one failed check, got %+v", checks)
+	}
+	if c := checks[1]; c.Suite != "production" || c.Rule != RuleInvalidValue || c.Message != "LOG_LEVEL: expected one of debug, info" {
+		t.Fatalf("unexpected check %+v", c)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	checks := []Check{
+		{Suite: "development", Name: "A", File: "target.json", Rule: RuleDifferentValue, Message: "A has different values", Failed: true},
+		{Suite: "development", Name: "B", File: "target.json", Rule: RuleMissingInSource, Message: "ignored, missing in source", Skipped: true},
+		{Suite: "qa", File: "target.json", Message: "same keys and values"},
+	}
+
+	var out bytes.Buffer
+	if err := Write(&out, FormatJUnit, "compare", checks); err != nil {
+		t.Fatalf("write junit: %v", err)
+	}
+	suites := junitTestSuites{}
+	if err := xml.Unmarshal(out.Bytes(), &suites); err != nil {
+		t.Fatalf("unmarshal junit: %v", err)
+	}
+	if suites.Tests != 3 || suites.Failures != 1 || suites.Skipped != 1 || len(suites.Suites) != 2 {
+		t.Fatalf("unexpected junit report %+v", suites)
+	}
+
+	out.Reset()
+	if err := Write(&out, FormatSARIF, "compare", checks); err != nil {
+		t.Fatalf("write sarif: %v", err)
+	}
+	log := sarifLog{}
+	if err := json.Unmarshal(out.Bytes(), &log); err != nil {
+		t.Fatalf("unmarshal sarif: %v", err)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 1 || results[0].RuleID != RuleDifferentValue || results[0].Locations[0].LogicalLocations[0].FullyQualifiedName != "development.A" {
+		t.Fatalf("unexpected sarif results %+v", results)
+	}
+
+	out.Reset()
+	if err := Write(&out, FormatMarkdown, "compare", checks); err != nil {
+		t.Fatalf("write markdown: %v", err)
+	}
+	for _, want := range []string{"### compare", "1 check failed", "| ❌ different-value | development | `A` | A has different values |", "| 🤷 ignored | development | `B` |"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q to contain %q", out.String(), want)
+		}
+	}
+	if strings.Contains(out.String(), "same keys and values") {
+		t.Fatalf("expected passing checks to be omitted, got %q", out.String())
+	}
+
+	if err := Write(&out, "xml", "compare", checks); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
@@ -0,0 +1,302 @@
+// Package report prints the result of comparing env sections.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/goliatone/go-envset/pkg/envset"
+	"github.com/gosuri/uitable"
+	colors "github.com/logrusorgru/aurora/v3"
+)
+
+// PrettyPrint prints the keys of a diff as tables, keys missing in
+// source, keys missing in target, keys with different values and,
+// if the diff has them, keys with the same value and ignored keys.
+func PrettyPrint(diff envset.EnvSection, source, target string) {
+
+	sort.SliceStable(diff.Keys, func(p, q int) bool {
+		return diff.Keys[p].Comment > diff.Keys[q].Comment
+	})
+
+	fmt.Println("")
+
+	column := "HASH"
+	if hasValues(diff) {
+		column = "VALUE"
+	}
+
+	mit := newTable()
+	mrt := newTable()
+	dvt := newTable()
+	svt := newTable()
+
+	mi := 0
+	mr := 0
+	dv := 0
+	sv := 0
+
+	for _, k := range diff.Keys {
+		if strings.Contains(k.Comment, "missing") {
+			if mi == 0 {
+				addHeader(mit, column)
+			}
+			mi++
+			mit.AddRow("👻 Missing", k.Name, display(k))
+		} else if strings.Contains(k.Comment, "extra") {
+			if mr == 0 {
+				addHeader(mrt, column)
+			}
+			mr++
+			mrt.AddRow("🌱 Missing", k.Name, display(k))
+		} else if strings.Contains(k.Comment, "different") {
+			if dv == 0 {
+				addHeader(dvt, column)
+			}
+			dv++
+			dvt.AddRow("❓ Different", k.Name, display(k))
+		} else if k.Comment == envset.SameValueComment {
+			if sv == 0 {
+				addHeader(svt, column)
+			}
+			sv++
+			svt.AddRow("✅ Same", k.Name, display(k))
+		}
+	}
+
+	fmt.Printf("•  %s: %s\n", colors.Bold("source"), source)
+	fmt.Println(tableOrMessage(mit.String(), colors.Green("👍 source is not missing environment variables").String()))
+
+	fmt.Printf("\n\n•  %s: %s\n", colors.Bold("target"), target)
+	fmt.Println(tableOrMessage(mrt.String(), colors.Green("👍 target has no extra environment variables").String()))
+
+	fmt.Printf("\n\n•  %s\n", colors.Bold("different values"))
+	fmt.Println(tableOrMessage(dvt.String(), colors.Green("👍 All variables have same values").String()))
+
+	if sv > 0 {
+		fmt.Printf("\n\n•  %s\n", colors.Bold("same values"))
+		fmt.Println(svt.String())
+	}
+
+	if len(diff.Ignored) > 0 {
+		fmt.Printf("\n\n•  %s\n", colors.Bold("ignored keys"))
+		fmt.Println(ignoredTable(diff.Ignored, column).String())
+	}
+
+	fmt.Println("")
+
+	//TODO: add dynamic padding
+	fmt.Printf(
+		"\n👻 Missing in %s (%d) | 🌱 Missing in %s (%d) \n\n❓ Different values  (%d) | 🤷 Ignored Keys (%d)\n\n",
+		colors.Bold("source"),
+		greenOrRed(mi).Bold(),
+		colors.Bold("target"),
+		greenOrRed(mr).Bold(),
+		greenOrYellow(dv).Bold(),
+		greenOrYellow(len(diff.Ignored)).Bold(),
+	)
+}
+
+// PrettyOk prints the result of a comparison without differences
+func PrettyOk(source, target string) {
+	fmt.Printf("\n•  %s: %s\n", colors.Bold("source"), source)
+	fmt.Printf("•  %s: %s\n", colors.Bold("target"), target)
+	fmt.Printf("\n🚀 %s\n\n", colors.Bold("All good!").Green())
+}
+
+// PrettyPrintAll prints the sections of a comparison of all sections
+// with the count of keys missing, extra or different in each section,
+// followed by the keys of the sections that are different.
+func PrettyPrintAll(res envset.MetadataComparison, source, target string) {
+	fmt.Printf("\n•  %s: %s\n", colors.Bold("source"), source)
+	fmt.Printf("•  %s: %s\n\n", colors.Bold("target"), target)
+
+	st := newTable()
+	st.AddRow(
+		"   "+colors.Bold("STATUS").Underline().String(),
+		colors.Bold("SECTION").Underline(),
+		colors.Bold("👻 MISSING IN SOURCE").Underline(),
+		colors.Bold("🌱 MISSING IN TARGET").Underline(),
+		colors.Bold("❓ DIFFERENT").Underline(),
+		colors.Bold("🤷 IGNORED").Underline(),
+	)
+
+	kt := newTable()
+	kt.AddRow(
+		"   "+colors.Bold("STATUS").Underline().String(),
+		colors.Bold("SECTION").Underline(),
+		colors.Bold("ENV KEY").Underline(),
+		colors.Bold("HASH").Underline(),
+	)
+
+	it := newTable()
+	it.AddRow(
+		"   "+colors.Bold("STATUS").Underline().String(),
+		colors.Bold("SECTION").Underline(),
+		colors.Bold("ENV KEY").Underline(),
+		colors.Bold("IGNORED").Underline(),
+		colors.Bold("HASH").Underline(),
+	)
+
+	keys := 0
+	ignored := 0
+	for _, s := range res.Sections {
+		st.AddRow(
+			sectionStatus(s.Status),
+			s.Name,
+			greenOrRed(s.MissingInSource),
+			greenOrRed(s.MissingInTarget),
+			greenOrYellow(s.Different),
+			greenOrYellow(s.Ignored),
+		)
+
+		for _, k := range s.Keys {
+			keys++
+			kt.AddRow(keyStatus(k.Comment), s.Name, k.Name, display(k))
+		}
+
+		for _, k := range s.IgnoredKeys {
+			ignored++
+			it.AddRow("🤷 Ignored", s.Name, k.Name, k.Comment, display(k))
+		}
+	}
+
+	fmt.Println(st.String())
+
+	if keys > 0 {
+		fmt.Printf("\n\n•  %s\n", colors.Bold("keys"))
+		fmt.Println(kt.String())
+	}
+
+	if ignored > 0 {
+		fmt.Printf("\n\n•  %s\n", colors.Bold("ignored keys"))
+		fmt.Println(it.String())
+	}
+
+	if res.IsEmpty() {
+		fmt.Printf("\n🚀 %s\n\n", colors.Bold("All good!").Green())
+		return
+	}
+
+	fmt.Printf(
+		"\n%d of %d sections are different\n\n",
+		greenOrRed(res.Different()).Bold(),
+		len(res.Sections),
+	)
+}
+
+// HasDifferences returns true if the diff has keys that are
+// missing, extra or have different values
+func HasDifferences(diff envset.EnvSection) bool {
+	for _, k := range diff.Keys {
+		if k.Comment != envset.SameValueComment {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredTable lists ignored keys with the comment that says
+// how they would have been reported
+func ignoredTable(keys []*envset.EnvKey, column string) *uitable.Table {
+	t := newTable()
+	t.AddRow(
+		"   "+colors.Bold("STATUS").Underline().String(),
+		colors.Bold("ENV KEY").Underline(),
+		colors.Bold("IGNORED").Underline(),
+		colors.Bold(column).Underline(),
+	)
+	for _, k := range keys {
+		t.AddRow("🤷 Ignored", k.Name, k.Comment, display(k))
+	}
+	return t
+}
+
+func sectionStatus(status string) string {
+	switch status {
+	case envset.SectionOK:
+		return "🚀 OK"
+	case envset.SectionMissingInSource:
+		return "👻 Missing in source"
+	case envset.SectionMissingInTarget:
+		return "🌱 Missing in target"
+	}
+	return "❓ Different"
+}
+
+func keyStatus(comment string) string {
+	if strings.Contains(comment, "missing") {
+		return "👻 Missing"
+	} else if strings.Contains(comment, "extra") {
+		return "🌱 Missing"
+	}
+	return "❓ Different"
+}
+
+func newTable() *uitable.Table {
+	t := uitable.New()
+	t.MaxColWidth = 50
+	return t
+}
+
+func addHeader(t *uitable.Table, column string) {
+	t.AddRow(
+		"   "+colors.Bold("STATUS").Underline().String(),
+		colors.Bold("ENV KEY").Underline(),
+		colors.Bold(column).Underline(),
+	)
+}
+
+func hasValues(diff envset.EnvSection) bool {
+	for _, k := range diff.Keys {
+		if k.Value != "" || k.TargetValue != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// display returns the value or hash of a key, and the
+// target value or hash if it is different
+func display(k *envset.EnvKey) string {
+	if k.Value != "" || k.TargetValue != "" {
+		if k.TargetHash == "" {
+			return strmax(k.Value, 24, "...")
+		}
+		return strmax(k.Value, 24, "...") + " → " + strmax(k.TargetValue, 24, "...")
+	}
+
+	if k.TargetHash == "" {
+		return strmax(k.Hash, 12, "...")
+	}
+	return strmax(k.Hash, 12, "...") + " → " + strmax(k.TargetHash, 12, "...")
+}
+
+func greenOrRed(val int) colors.Value {
+	if val == 0 {
+		return colors.Green(val)
+	}
+	return colors.Red(val)
+}
+
+func greenOrYellow(val int) colors.Value {
+	if val == 0 {
+		return colors.Green(val)
+	}
+	return colors.Yellow(val)
+}
+
+func tableOrMessage(tbl, message string) string {
+	if tbl == "" {
+		return message
+	}
+	return tbl
+}
+
+func strmax(str string, l int, suffix string) string {
+	if len(str) <= l {
+		return str
+	}
+	return str[:l] + suffix
+}
@@ -0,0 +1,113 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+
+	"github.com/goliatone/go-envset/cmd/envset/internal/cliopts"
+	"github.com/goliatone/go-envset/pkg/config"
+	"github.com/goliatone/go-envset/pkg/envset"
+	"github.com/urfave/cli/v2"
+)
+
+// GetCommand returns a new cli.Command for the
+// lint command
+func GetCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "lint",
+		Usage: "check env files for common mistakes",
+		UsageText: `envset lint [options]
+
+EXAMPLE:
+   envset lint
+   envset lint --format json
+   envset lint --disable unknown-section --disable trailing-whitespace`,
+		Description: "report duplicate keys, invalid names, trailing whitespace, unquoted values with spaces, " +
+			"undefined or cyclic references, command substitutions, keys missing in some environments " +
+			"and sections not listed in .envsetrc, exits with an error if any issue is found",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "format", Value: "text", Usage: "output `format`, text or json"},
+			&cli.StringSliceFlag{Name: "enable", Usage: "enable `rule`, repeat to enable more rules"},
+			&cli.StringSliceFlag{Name: "disable", Usage: "disable `rule`, repeat to disable more rules"},
+			&cli.StringSliceFlag{Name: "env-file", Value: cli.NewStringSlice(cnf.EnvFiles()...), Usage: "lint `FILE`, repeat to lint overlays merged in order"},
+			&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file, ini, dotenv, yaml, toml or json"},
+		},
+		Action: func(c *cli.Context) error {
+			format := c.String("format")
+			if format != "text" && format != "json" {
+				return cli.Exit(fmt.Sprintf("unknown format %q, expected text or json", format), 1)
+			}
+
+			rules := maps.Clone(cnf.LintRules)
+			if rules == nil {
+				rules = make(map[string]bool)
+			}
+			for _, rule := range c.StringSlice("enable") {
+				rules[rule] = true
+			}
+			for _, rule := range c.StringSlice("disable") {
+				rules[rule] = false
+			}
+
+			files := cliopts.EnvFiles(c)
+			issues, err := envset.Lint(envset.LintOptions{
+				Filename:            files[0],
+				Overlays:            files[1:],
+				Format:              cliopts.String(c, cliopts.EnvFileFormatFlag),
+				LocalOverlays:       cnf.LocalOverlays,
+				CommentSectionNames: cnf.CommentSectionNames.Keys,
+				Environments:        cnf.EnvironmentNames(),
+				Expand:              cnf.Expand,
+				Rules:               rules,
+			})
+			if err != nil {
+				return err
+			}
+
+			for i := range issues {
+				issues[i].File = relativePath(issues[i].File)
+			}
+
+			if format == "json" {
+				if issues == nil {
+					issues = []envset.LintIssue{}
+				}
+				enc := json.NewEncoder(c.App.Writer)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(issues); err != nil {
+					return err
+				}
+			} else {
+				for _, issue := range issues {
+					fmt.Fprintln(c.App.Writer, issue)
+				}
+			}
+
+			switch len(issues) {
+			case 0:
+				return nil
+			case 1:
+				return cli.Exit("1 issue found", 1)
+			default:
+				return cli.Exit(fmt.Sprintf("%d issues found", len(issues)), 1)
+			}
+		},
+	}
+}
+
+// relativePath returns filename relative to the current
+// directory if it is inside it.
+func relativePath(filename string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return filename
+	}
+	rel, err := filepath.Rel(wd, filename)
+	if err != nil || !filepath.IsLocal(rel) {
+		return filename
+	}
+	return rel
+}
@@ -4,13 +4,20 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/goliatone/go-envset/cmd/envset/diff"
+	"github.com/goliatone/go-envset/cmd/envset/edit"
+	"github.com/goliatone/go-envset/cmd/envset/encrypt"
 	"github.com/goliatone/go-envset/cmd/envset/environment"
 	"github.com/goliatone/go-envset/cmd/envset/internal/cliopts"
+	"github.com/goliatone/go-envset/cmd/envset/lint"
 	"github.com/goliatone/go-envset/cmd/envset/metadata"
 	"github.com/goliatone/go-envset/cmd/envset/rc"
+	"github.com/goliatone/go-envset/cmd/envset/secrets"
 	"github.com/goliatone/go-envset/cmd/envset/template"
+	"github.com/goliatone/go-envset/cmd/envset/validate"
 	"github.com/goliatone/go-envset/cmd/envset/version"
 	"github.com/goliatone/go-envset/pkg/config"
 	"github.com/goliatone/go-envset/pkg/exec"
@@ -61,21 +68,37 @@ func run(args []string, ecmd exec.ExecCmd) {
 		log.Panic("Ensure you have a valid .envsetrc")
 	}
 
-	subcommands := []*cli.Command{}
-
-	for _, env := range cnf.Environments.Names {
-		subcommands = append(subcommands, environment.GetCommand(env, ecmd, cnf))
-	}
-
 	app.Commands = append(app.Commands, rc.GetCommand(cnf))
 
 	app.Commands = append(app.Commands, metadata.GetCommand(cnf))
 
 	app.Commands = append(app.Commands, template.GetCommand(cnf))
 
+	app.Commands = append(app.Commands, diff.GetCommand(cnf))
+
+	app.Commands = append(app.Commands, edit.GetSetCommand(cnf), edit.GetGetCommand(cnf), edit.GetUnsetCommand(cnf))
+
+	app.Commands = append(app.Commands, encrypt.GetCommand(cnf), encrypt.GetDecryptCommand(cnf), encrypt.GetRekeyCommand(cnf))
+	app.Commands = append(app.Commands, secrets.GetCommand(cnf))
+
+	app.Commands = append(app.Commands, validate.GetCommand(cnf))
+
+	app.Commands = append(app.Commands, lint.GetCommand(cnf))
+
 	app.Commands = append(app.Commands, version.GetCommand(cnf))
 
-	app.Commands = append(app.Commands, subcommands...)
+	//we only need to parse the env file to find environments
+	//if we are not calling a command, e.g. envset version
+	if !isCommand(args) {
+		for _, cmd := range environment.GetCommands(cliopts.DiscoverOptions(args, cnf), ecmd, cnf) {
+			//environments named like a command can only be used with --env
+			if app.Command(cmd.Name) != nil {
+				fmt.Fprintf(os.Stderr, "envset: environment %q has the same name as a command, use --env=%s\n", cmd.Name, cmd.Name)
+				continue
+			}
+			app.Commands = append(app.Commands, cmd)
+		}
+	}
 
 	app.Flags = []cli.Flag{
 		&cli.StringFlag{
@@ -83,11 +106,43 @@ func run(args []string, ecmd exec.ExecCmd) {
 			Usage: "env name matching a section. If not set matches env vars in global scope",
 			Value: envset.DefaultSection,
 		},
-		&cli.StringFlag{
+		&cli.StringSliceFlag{
 			//This can be an absolute path. If a file name then we recursively look up
 			Name:  "env-file",
-			Usage: "`file` with environment definition",
-			Value: cnf.Filename,
+			Usage: "`file` with environment definition, repeat to merge files in order",
+			Value: cli.NewStringSlice(cnf.EnvFiles()...),
+		},
+		&cli.StringFlag{
+			Name:  "env-file-format",
+			Usage: "`format` of the env file, ini, dotenv, yaml, toml or json. Detected from the file name if not set",
+			Value: cnf.Format,
+		},
+		&cli.BoolFlag{
+			Name:  "local-overlays",
+			Usage: "merge .envset.local and .envset.<env>.local files if found next to the env file",
+			Value: cnf.LocalOverlays,
+		},
+		&cli.BoolFlag{
+			Name:  "sources",
+			Usage: "print the file each variable was loaded from",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "output `format` when printing the environment: bash, zsh, fish, dotenv, json, docker, systemd or github",
+		},
+		&cli.BoolFlag{
+			Name:  "reveal",
+			Usage: "print secret values instead of ****",
+		},
+		&cli.BoolFlag{
+			Name:  "mask-output",
+			Usage: "replace secret values in the command output with ****",
+			Value: cnf.MaskOutput,
+		},
+		&cli.StringFlag{
+			Name:  "key-file",
+			Usage: "`file` with the key to decrypt encrypted values. Define with ENVSET_KEY_FILE",
+			Value: cnf.KeyFile,
 		},
 		&cli.BoolFlag{
 			Name:  "isolated",
@@ -131,6 +186,54 @@ func run(args []string, ecmd exec.ExecCmd) {
 			Usage:   "times to restart failed command",
 			Value:   cnf.MaxRestarts,
 		},
+		&cli.DurationFlag{
+			Name:  "restart-delay",
+			Usage: "wait `duration` before restarting, doubles on each restart",
+			Value: cnf.RestartDelay,
+		},
+		&cli.DurationFlag{
+			Name:  "restart-max-delay",
+			Usage: "max `duration` to wait before restarting",
+			Value: cnf.RestartMaxDelay,
+		},
+		&cli.Float64Flag{
+			Name:  "restart-jitter",
+			Usage: "randomize restart delay by a `factor` between 0 and 1",
+			Value: cnf.RestartJitter,
+		},
+		&cli.DurationFlag{
+			Name:  "restart-reset-after",
+			Usage: "reset restart count if command ran for at least `duration`",
+			Value: cnf.RestartResetAfter,
+		},
+		&cli.IntSliceFlag{
+			Name:  "restart-exit-code",
+			Usage: "only restart when command exits with `code`",
+			Value: cli.NewIntSlice(cnf.RestartExitCodes...),
+		},
+		&cli.IntSliceFlag{
+			Name:  "restart-ignore-exit-code",
+			Usage: "do not restart when command exits with `code`",
+			Value: cli.NewIntSlice(cnf.RestartIgnoreCodes...),
+		},
+		&cli.BoolFlag{
+			Name:  "process-group",
+			Usage: "run command in its own process group and forward signals to the group",
+		},
+		&cli.BoolFlag{
+			Name:  "watch",
+			Usage: "restart command when the env file or .envsetrc change the environment",
+		},
+		&cli.DurationFlag{
+			Name:  "watch-interval",
+			Usage: "`duration` between checks for changes in watched files",
+			Value: cnf.WatchInterval,
+		},
+		&cli.DurationFlag{
+			Name:  "stop-timeout",
+			Usage: "`duration` to wait for the command to exit after SIGTERM before it is killed",
+			Value: cnf.StopTimeout,
+		},
 	}
 
 	app.Action = func(c *cli.Context) error {
@@ -143,7 +246,7 @@ func run(args []string, ecmd exec.ExecCmd) {
 			cli.ShowAppHelpAndExit(c, 0)
 		}
 
-		env := c.String("env")
+		env := cnf.ResolveAlias(c.String("env"))
 
 		o := cliopts.RunOptions(c, cnf, env, ecmd)
 
@@ -171,7 +274,23 @@ func run(args []string, ecmd exec.ExecCmd) {
 	//and return the arguments that are only for envset
 	err = app.Run(args)
 	if err != nil {
+		//Exit with the same code as the executed command, the
+		//command already reported why it failed
+		if code, ok := envset.ExitCode(err); ok {
+			os.Exit(code)
+		}
 		fmt.Printf("%s\n", err.Error())
 		os.Exit(1)
 	}
 }
+
+// isCommand returns true if the first argument is a built-in command
+func isCommand(args []string) bool {
+	for _, arg := range args[1:] {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		return app.Command(arg) != nil
+	}
+	return false
+}
@@ -5,11 +5,14 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	osexec "os/exec"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rendon/testcli"
 	"github.com/stretchr/testify/assert"
@@ -80,6 +83,21 @@ func Test_Print(t *testing.T) {
 	}
 }
 
+func Test_PrintFormat(t *testing.T) {
+	testcli.Run(bin, "development", "--env-file=testdata/.envset", "--format=bash")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, but failed: %q with message: %q", testcli.Error(), testcli.Stderr())
+	}
+	if !testcli.StdoutContains("export APP_ENV='development'") {
+		t.Fatalf("Expected %q to contain %q", testcli.Stdout(), "export APP_ENV='development'")
+	}
+
+	testcli.Run(bin, "development", "--env-file=testdata/.envset", "--format=yaml")
+	if testcli.Success() {
+		t.Fatal("Expected to fail with unknown format")
+	}
+}
+
 func Test_ExecCmd(t *testing.T) {
 	dir := cd("testdata", t)
 
@@ -305,6 +323,82 @@ func Test_MetadataCompareInvalidJSONReportsLoadError(t *testing.T) {
 	}
 }
 
+func Test_MetadataCompareAll(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.json")
+	target := filepath.Join(dir, "target.json")
+
+	src := `{"algorithm":"sha256","sections":[{"name":"development","values":[{"key":"A","hash":"1"}]},{"name":"qa","values":[{"key":"A","hash":"1"}]}]}`
+	if err := os.WriteFile(source, []byte(src), 0644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	tgt := `{"algorithm":"sha256","sections":[{"name":"development","values":[{"key":"A","hash":"2"}]},{"name":"production","values":[]}]}`
+	if err := os.WriteFile(target, []byte(tgt), 0644); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+
+	testcli.Run(bin, "metadata", "compare", "--print", source, target)
+	if testcli.Success() {
+		t.Fatal("Expected metadata compare to fail")
+	}
+	if !testcli.StdoutContains("Missing in target") || !testcli.StdoutContains("Missing in source") || !testcli.StdoutContains(" of 3 sections are different") {
+		t.Fatalf("Expected aggregate report, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "metadata", "compare", "--all", "--print", "--json", source, target)
+	if !testcli.StderrContains(`"status": "missing in source"`) {
+		t.Fatalf("Expected json report, stderr: %q", testcli.Stderr())
+	}
+
+	testcli.Run(bin, "metadata", "compare", "--all", source, source)
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	testcli.Run(bin, "metadata", "compare", "--format", "junit", source, target)
+	if testcli.Success() || !testcli.StdoutContains(`<testsuites name="envset metadata compare" tests="3" failures="3" skipped="0">`) {
+		t.Fatalf("Expected junit report, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "metadata", "compare", "--format", "markdown", "--section=development", source, target)
+	if testcli.Success() || !testcli.StdoutContains("| ❌ different-value | development | `A` |") {
+		t.Fatalf("Expected markdown report, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "metadata", "compare", "--format", "xml", source, target)
+	if testcli.Success() || !testcli.StderrContains(`unknown format "xml"`) {
+		t.Fatalf("Expected unknown format error, stderr: %q", testcli.Stderr())
+	}
+}
+
+func Test_MetadataCompareIgnored(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.json")
+	target := filepath.Join(dir, "target.json")
+
+	src := `{"algorithm":"sha256","sections":[{"name":"development","values":[{"key":"A","hash":"1"},{"key":"B","hash":"1"}]}]}`
+	if err := os.WriteFile(source, []byte(src), 0644); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	tgt := `{"algorithm":"sha256","sections":[{"name":"development","values":[{"key":"A","hash":"2"},{"key":"B","hash":"1"}]}]}`
+	if err := os.WriteFile(target, []byte(tgt), 0644); err != nil {
+		t.Fatalf("write target: %v", err)
+	}
+
+	testcli.Run(bin, "metadata", "compare", "--print", "--section=development", "-I", "A", source, target)
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+	if !testcli.StdoutContains("ignored keys") || !testcli.StdoutContains("different hash value") {
+		t.Fatalf("Expected ignored keys table, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "metadata", "compare", "--print", "--json", "--section=development", "-I", "A", source, target)
+	if !testcli.StdoutContains(`"ignored": [`) {
+		t.Fatalf("Expected ignored keys in json, stdout: %q", testcli.Stdout())
+	}
+}
+
 func Test_MetadataOverwriteTightensFilePermissions(t *testing.T) {
 	dir := t.TempDir()
 	envFile := filepath.Join(dir, ".envset")
@@ -338,6 +432,82 @@ func Test_MetadataOverwriteTightensFilePermissions(t *testing.T) {
 	assertRegularFileMode(t, metaFile)
 }
 
+func Test_MetadataPrintsChanges(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".envset")
+	metaDir := filepath.Join(dir, "meta")
+
+	if err := os.WriteFile(envFile, []byte("[development]\nA=1\nB=2\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	args := []string{"metadata", "--env-file=" + envFile, "--filepath=" + metaDir, "--filename=data.json"}
+	testcli.Run(bin, args...)
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, but failed: %q with message: %q", testcli.Error(), testcli.Stderr())
+	}
+
+	if err := os.WriteFile(envFile, []byte("[development]\nA=2\nC=3\n\n[qa]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	testcli.Run(bin, args...)
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, but failed: %q with message: %q", testcli.Error(), testcli.Stderr())
+	}
+
+	for _, want := range []string{"data.json:", "+ [qa]", "~ [development]", "+ C", "- B", "~ A value changed"} {
+		if !testcli.StdoutContains(want) {
+			t.Fatalf("Expected %q to contain %q", testcli.Stdout(), want)
+		}
+	}
+
+	testcli.Run(bin, args...)
+	if !testcli.Success() || testcli.Stdout() != "" {
+		t.Fatalf("Expected no changes, stdout: %q", testcli.Stdout())
+	}
+}
+
+func Test_MetadataVerify(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".envset")
+	metaDir := filepath.Join(dir, "meta")
+
+	if err := os.WriteFile(envFile, []byte("[development]\nA=1\n\n[qa]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	flags := []string{"--env-file=" + envFile, "--filepath=" + metaDir, "--filename=data.json"}
+	testcli.Run(bin, append([]string{"metadata", "verify"}, flags...)...)
+	if testcli.Success() || !testcli.StderrContains("not found") {
+		t.Fatalf("Expected to fail without metadata file, stderr: %q", testcli.Stderr())
+	}
+
+	testcli.Run(bin, append([]string{"metadata", "--values"}, flags...)...)
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, but failed: %q with message: %q", testcli.Error(), testcli.Stderr())
+	}
+
+	testcli.Run(bin, append([]string{"metadata", "verify"}, flags...)...)
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+
+	if err := os.WriteFile(envFile, []byte("[development]\nA=2\n\n[qa]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	testcli.Run(bin, append([]string{"metadata", "verify"}, flags...)...)
+	if testcli.Success() || !testcli.StdoutContains("~ A value changed") {
+		t.Fatalf("Expected drift, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, append([]string{"metadata", "verify", "--section=qa"}, flags...)...)
+	if !testcli.Success() {
+		t.Fatalf("Expected qa to match, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+}
+
 func Test_DotEnvFile(t *testing.T) {
 	testcli.Run(bin, "--env-file=testdata/.env")
 
@@ -350,6 +520,655 @@ func Test_DotEnvFile(t *testing.T) {
 	}
 }
 
+func Test_DotEnvFileSyntax(t *testing.T) {
+	dir := t.TempDir()
+	contents := "# greeting\nexport GREETING=\"hello world\" # inline\nLITERAL='${GREETING}'\n"
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	cmd := osexec.Command("git", "init")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init %s: %v\n%s", dir, err, out)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "--env-file=.env", "--", "sh", "-c", "test \"$GREETING\" = 'hello world'")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	testcli.Run(bin, "--env-file=.env", "metadata", "--print", "--globals", "--values")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("\"value\": \"hello world\"") {
+		t.Fatalf("Expected stdout %q to contain dotenv value", testcli.Stdout())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("A=1\nB=\"unterminated\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	testcli.Run(bin, "--env-file=.env")
+	if testcli.Success() {
+		t.Fatal("Expected to fail on malformed dotenv file")
+	}
+	if !testcli.StdoutContains(".env:2:3: unterminated double quoted value") {
+		t.Fatalf("Expected line and column in error, stdout: %q", testcli.Stdout())
+	}
+}
+
+func Test_SectionInheritance(t *testing.T) {
+	dir := t.TempDir()
+	contents := "[production]\nAPP_URL=https://envset.sh\nDB_HOST=db.envset.sh\n\n[staging : production]\nAPP_URL=https://staging.envset.sh\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	cmd := osexec.Command("git", "init")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init %s: %v\n%s", dir, err, out)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "staging", "--", "sh", "-c", "printf \"$APP_URL $DB_HOST\"")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("https://staging.envset.sh db.envset.sh") {
+		t.Fatalf("Expected %q to contain inherited values", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "metadata", "--print", "--values")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if strings.Count(testcli.Stdout(), "\"value\": \"db.envset.sh\"") != 2 {
+		t.Fatalf("Expected merged metadata, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "metadata", "--print", "--values", "--raw")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if strings.Count(testcli.Stdout(), "\"value\": \"db.envset.sh\"") != 1 {
+		t.Fatalf("Expected raw metadata, stdout: %q", testcli.Stdout())
+	}
+}
+
+func Test_LayeredEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".envset":                   "[development]\nAPP_URL=http://localhost\nLOG_LEVEL=warn\nPORT=80\n",
+		"shared.envset":             "[development]\nPORT=8080\n",
+		".envset.development.local": "LOG_LEVEL=debug\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	//local overlays can be skipped
+	testcli.Run(bin, "development", "--env-file=.envset", "--env-file=shared.envset", "--local-overlays=false", "--", "sh", "-c", "printf \"$LOG_LEVEL $PORT\"")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("warn 8080") {
+		t.Fatalf("Expected %q to contain %q", testcli.Stdout(), "warn 8080")
+	}
+
+	testcli.Run(bin, "development", "--env-file=.envset", "--env-file=shared.envset", "--", "sh", "-c", "printf \"$LOG_LEVEL $PORT\"")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("debug 8080") {
+		t.Fatalf("Expected %q to contain %q", testcli.Stdout(), "debug 8080")
+	}
+
+	testcli.Run(bin, "development", "--env-file=.envset", "--env-file=shared.envset", "--sources")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	for _, want := range []string{"APP_URL    .envset\n", "LOG_LEVEL  .envset.development.local\n", "PORT       shared.envset\n"} {
+		if !testcli.StdoutContains(want) {
+			t.Fatalf("Expected sources %q to contain %q", testcli.Stdout(), want)
+		}
+	}
+}
+
+func Test_TemplateRender(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte("[production]\nHOST=envset.sh\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "nginx.tpl"), []byte("server_name {{HOST}};\n{{MISSING}}\n"), 0644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "template", "render", "--env=production", "nginx.tpl")
+	if testcli.Success() {
+		t.Fatal("Expected to fail with unresolved placeholder")
+	}
+	if !testcli.StdoutContains("MISSING") && !testcli.StderrContains("MISSING") {
+		t.Fatalf("Expected error to name MISSING, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+
+	testcli.Run(bin, "template", "render", "--env=production", "--allow-missing", "--output=nginx.conf", "nginx.tpl")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "nginx.conf"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(b) != "server_name envset.sh;\n{{MISSING}}\n" {
+		t.Fatalf("unexpected output %q", b)
+	}
+}
+
+func Test_EncryptDecrypt(t *testing.T) {
+	dir := t.TempDir()
+	contents := "[production]\n# database\nDB_PASSWORD=s3cr3t\nDB_URL=postgres://app:${DB_PASSWORD}@db\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "key"), []byte("key\n"), 0600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "encrypt", "--env=production", "--key-file=key", "DB_PASSWORD")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, ".envset"))
+	if err != nil {
+		t.Fatalf("read env file: %v", err)
+	}
+	if strings.Contains(string(b), "s3cr3t") || !strings.Contains(string(b), "# database\nDB_PASSWORD=enc:v1:") {
+		t.Fatalf("Expected encrypted value in %q", b)
+	}
+
+	testcli.Run(bin, "production", "--", "sh", "-c", "printf \"$DB_URL\"")
+	if testcli.Success() {
+		t.Fatal("Expected to fail without a key")
+	}
+
+	testcli.Run(bin, "production", "--key-file=key", "--", "sh", "-c", "printf \"$DB_URL\"")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("postgres://app:s3cr3t@db") {
+		t.Fatalf("Expected %q to contain decrypted value", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "decrypt", "--env=production", "--key-file=key")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, ".envset"))
+	if err != nil {
+		t.Fatalf("read env file: %v", err)
+	}
+	if string(b) != contents {
+		t.Fatalf("Expected decrypted file %q, got %q", contents, b)
+	}
+}
+
+func Test_Rekey(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte("[staging]\nTOKEN=a\n\n[production]\nTOKEN=b\nURL=c\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	for name, key := range map[string]string{"old.key": "old", "new.key": "new"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(key), 0600); err != nil {
+			t.Fatalf("write key file: %v", err)
+		}
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	for _, env := range []string{"staging", "production"} {
+		testcli.Run(bin, "encrypt", "--env="+env, "--key-file=old.key")
+		if !testcli.Success() {
+			t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+		}
+	}
+
+	testcli.Run(bin, "rekey", "--old-key=old.key", "--new-key=new.key")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	for _, want := range []string{"[staging] rotated 1 values", "[production] rotated 2 values", "rotated 3 values in 2 sections"} {
+		if !testcli.StdoutContains(want) {
+			t.Fatalf("Expected %q to contain %q", testcli.Stdout(), want)
+		}
+	}
+
+	testcli.Run(bin, "production", "--key-file=new.key", "--", "sh", "-c", "printf \"$TOKEN$URL\"")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("bc") {
+		t.Fatalf("Expected %q to contain %q", testcli.Stdout(), "bc")
+	}
+}
+
+func Test_SecretRefs(t *testing.T) {
+	dir := t.TempDir()
+	contents := "[production]\nDB_PASS=ref+file://db.secret\nTOKEN=ref+env://ENVSET_TEST_TOKEN\nDB_URL=postgres://app:${DB_PASS}@db\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "db.secret"), []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	t.Setenv("ENVSET_TEST_TOKEN", "t0k3n")
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "production", "--", "sh", "-c", "printf \"$DB_URL $TOKEN\"")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("postgres://app:s3cr3t@db t0k3n") {
+		t.Fatalf("Expected %q to contain resolved secrets", testcli.Stdout())
+	}
+}
+
+func Test_SecretsServe(t *testing.T) {
+	dir := t.TempDir()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	secrets := "[production]\nDB_PASS=s3cr3t\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset.secrets"), []byte(secrets), 0600); err != nil {
+		t.Fatalf("write secrets file: %v", err)
+	}
+	contents := "[production]\nDB_PASS=ref+http://" + addr + "/production/DB_PASS\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	server := osexec.Command(bin, "secrets", "serve", "--env-file=.envset.secrets", "--addr="+addr, "--token=dev")
+	if err := server.Start(); err != nil {
+		t.Fatalf("start server: %v", err)
+	}
+	defer func() {
+		_ = server.Process.Signal(os.Interrupt)
+		_ = server.Wait()
+	}()
+
+	for i := 0; i < 50; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Setenv("ENVSET_SECRETS_TOKEN", "dev")
+	testcli.Run(bin, "production", "--", "sh", "-c", "printf \"$DB_PASS\"")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("s3cr3t") {
+		t.Fatalf("Expected %q to contain resolved secret", testcli.Stdout())
+	}
+
+	t.Setenv("ENVSET_SECRETS_TOKEN", "wrong")
+	testcli.Run(bin, "production", "--", "sh", "-c", "printf \"$DB_PASS\"")
+	if testcli.Success() {
+		t.Fatalf("Expected to fail with a wrong token, stdout: %q", testcli.Stdout())
+	}
+}
+
+func Test_RedactSecrets(t *testing.T) {
+	dir := t.TempDir()
+	contents := "[production]\nAPP_NAME=envset\nAPI_TOKEN=t0k3n-value\n# @secret\nLICENSE=license-key\nURL=https://${LICENSE}@api\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".envsetrc"), []byte("[secrets]\nkey=*_TOKEN\n"), 0644); err != nil {
+		t.Fatalf("write rc file: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "production")
+	if !testcli.Failure() || !testcli.StdoutContains("--reveal") {
+		t.Fatalf("Expected to fail instead of exporting redacted values, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "production", "--format", "json")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if testcli.StdoutContains("t0k3n-value") || testcli.StdoutContains("license-key") || !testcli.StdoutContains("https://****@api") {
+		t.Fatalf("Expected %q to redact secrets", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "production", "--reveal")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("t0k3n-value") || !testcli.StdoutContains("license-key") {
+		t.Fatalf("Expected %q to reveal secrets", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "production", "--mask-output", "--", "sh", "-c", "printf \"token $API_TOKEN\"; printf \"license $LICENSE\" >&2")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if testcli.Stdout() != "token ****" || testcli.Stderr() != "license ****" {
+		t.Fatalf("Expected masked output, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	dir := t.TempDir()
+	contents := "[development]\nLOG_LEVEL=debug\n\n[production]\nLOG_LEVEL=verbose\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	schema := "# @type=port @default=8080\nPORT=\n# @values=debug,info @required\nLOG_LEVEL=\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset.schema"), []byte(schema), 0644); err != nil {
+		t.Fatalf("write schema file: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "validate", "development")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("LOG_LEVEL  enum  ok") {
+		t.Fatalf("Expected %q to report valid keys", testcli.Stdout())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".envsetrc"), []byte("[aliases]\ndev=development\n"), 0644); err != nil {
+		t.Fatalf("write rc file: %v", err)
+	}
+	testcli.Run(bin, "validate", "dev")
+	if !testcli.Success() || !testcli.StdoutContains("LOG_LEVEL  enum  ok") {
+		t.Fatalf("Expected alias to validate development, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+
+	testcli.Run(bin, "validate", "--all")
+	if !testcli.Failure() {
+		t.Fatalf("Expected to fail, stdout: %q", testcli.Stdout())
+	}
+	if !testcli.StdoutContains("error: expected one of debug, info") || !testcli.StderrContains("1 of 2 environments are not valid") {
+		t.Fatalf("Expected per key report, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+
+	testcli.Run(bin, "validate", "--all", "--format", "junit")
+	if !testcli.Failure() || !testcli.StdoutContains(`<testsuites name="envset validate" tests="4" failures="1"`) {
+		t.Fatalf("Expected junit report, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+
+	testcli.Run(bin, "development", "--", "sh", "-c", "printf \"$PORT\"")
+	if !testcli.Success() || testcli.Stdout() != "8080" {
+		t.Fatalf("Expected schema default, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+
+	testcli.Run(bin, "production", "--", "echo", "hi")
+	if !testcli.Failure() || !testcli.StdoutContains("LOG_LEVEL: expected one of debug, info") {
+		t.Fatalf("Expected run to fail validation, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+
+	//schemas in parent directories of the env file are not used
+	app := filepath.Join(dir, "app")
+	if err := os.Mkdir(app, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(app, ".envset"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	cd(app, t)
+
+	testcli.Run(bin, "production", "--", "echo", "hi")
+	if !testcli.Success() {
+		t.Fatalf("Expected to ignore the parent schema, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+
+	testcli.Run(bin, "validate", "production")
+	if !testcli.Failure() || !testcli.StderrContains("no schema found") {
+		t.Fatalf("Expected no schema, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+}
+
+func Test_Lint(t *testing.T) {
+	dir := t.TempDir()
+	contents := "[development]\nAPP_NAME=envset\nAPP_NAME=dup\n\n[production]\nAPP_NAME=envset\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "lint")
+	if !testcli.Failure() {
+		t.Fatalf("Expected to fail, stdout: %q", testcli.Stdout())
+	}
+	if !testcli.StdoutContains(".envset:3: [development] APP_NAME: defined more than once") || !testcli.StderrContains("1 issue found") {
+		t.Fatalf("Expected lint report, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+
+	testcli.Run(bin, "lint", "--format", "json")
+	if !testcli.StdoutContains(`"rule": "duplicate-key"`) {
+		t.Fatalf("Expected json report, stdout: %q", testcli.Stdout())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".envsetrc"), []byte("[lint]\nduplicate-key=false\n"), 0644); err != nil {
+		t.Fatalf("write rc file: %v", err)
+	}
+
+	testcli.Run(bin, "lint")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	testcli.Run(bin, "lint", "--enable", "duplicate-key")
+	if !testcli.Failure() {
+		t.Fatalf("Expected to fail, stdout: %q", testcli.Stdout())
+	}
+}
+
+func Test_DiscoverEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte("[development]\nAPP_NAME=dev\n\n[qa]\nAPP_NAME=qa\nDEBUG=true\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".envset.other"), []byte("[sandbox]\nAPP_NAME=sandbox\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".envsetrc"), []byte("[aliases]\ndev=development\n"), 0644); err != nil {
+		t.Fatalf("write rc file: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "-h")
+	if !testcli.StdoutContains(`development, dev  load "development" environment in current shell session (1 key)`) ||
+		!testcli.StdoutContains(`load "qa" environment in current shell session (2 keys)`) {
+		t.Fatalf("Expected help to list environments, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "qa", "--", "sh", "-c", "printf \"$APP_NAME\"")
+	if !testcli.Success() || testcli.Stdout() != "qa" {
+		t.Fatalf("Expected to run qa, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	testcli.Run(bin, "dev", "--", "sh", "-c", "printf \"$APP_ENV\"")
+	if !testcli.Success() || testcli.Stdout() != "development" {
+		t.Fatalf("Expected alias to run development, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	testcli.Run(bin, "--env-file=.envset.other", "sandbox", "--", "sh", "-c", "printf \"$APP_NAME\"")
+	if !testcli.Success() || testcli.Stdout() != "sandbox" {
+		t.Fatalf("Expected to run sandbox, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	//environments named like a command are only reported when we look for environments
+	if err := os.WriteFile(filepath.Join(dir, ".envset.diff"), []byte("[diff]\nAPP_NAME=diff\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	testcli.Run(bin, "--env-file=.envset.diff", "-h")
+	if !testcli.StderrContains(`environment "diff" has the same name as a command, use --env=diff`) {
+		t.Fatalf("Expected a warning for the diff environment, stderr: %q", testcli.Stderr())
+	}
+
+	testcli.Run(bin, "--env-file=.envset.diff", "version")
+	if !testcli.Success() || testcli.Stderr() != "" {
+		t.Fatalf("Expected version to skip the env file, stderr: %q error: %q", testcli.Stderr(), testcli.Error())
+	}
+}
+
+func Test_SetGetUnset(t *testing.T) {
+	dir := t.TempDir()
+	contents := "[development]\n# the api\nAPI_URL=http://localhost\n\n[production]\nAPI_URL=https://api\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "set", "development", "API_URL=http://127.0.0.1", "DEBUG=true")
+	if !testcli.Success() || !testcli.StdoutContains("[development] set API_URL, DEBUG") {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	testcli.Run(bin, "get", "development", "API_URL")
+	if !testcli.Success() || testcli.Stdout() != "http://127.0.0.1\n" {
+		t.Fatalf("Expected value, stdout: %q stderr: %q", testcli.Stdout(), testcli.Stderr())
+	}
+
+	testcli.Run(bin, "set", "--all-envs", "LOG_LEVEL=info")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	testcli.Run(bin, "get", "--all-envs", "LOG_LEVEL")
+	if !testcli.StdoutContains("development  info") || !testcli.StdoutContains("production   info") {
+		t.Fatalf("Expected values of all environments, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "unset", "development", "API_URL", "DEBUG")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, ".envset"))
+	if err != nil {
+		t.Fatalf("read env file: %v", err)
+	}
+	want := "[development]\nLOG_LEVEL=info\n\n[production]\nAPI_URL=https://api\nLOG_LEVEL=info\n"
+	if string(b) != want {
+		t.Fatalf("Expected %q, got %q", want, b)
+	}
+
+	testcli.Run(bin, "get", "development", "API_URL")
+	if !testcli.Failure() {
+		t.Fatalf("Expected to fail, stdout: %q", testcli.Stdout())
+	}
+}
+
+func Test_Diff(t *testing.T) {
+	dir := t.TempDir()
+	contents := "[development]\nAPP_NAME=envset\nDEBUG=true\n\n[staging]\nAPP_NAME=envset\nDEBUG=false\n\n[qa]\nAPP_NAME=envset\nDEBUG=true\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "diff", "--values", "development", "staging")
+	if !testcli.Failure() {
+		t.Fatalf("Expected to fail, stdout: %q", testcli.Stdout())
+	}
+	if !testcli.StdoutContains("true → false") || !testcli.StdoutContains("same values") {
+		t.Fatalf("Expected diff report, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "diff", "--json", "development", "staging")
+	if !testcli.StdoutContains(`"comment": "different hash value"`) || testcli.StdoutContains(`"value": "true"`) {
+		t.Fatalf("Expected json report with hashes, stdout: %q", testcli.Stdout())
+	}
+
+	testcli.Run(bin, "diff", "development", "qa")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+}
+
+func Test_YAMLEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	contents := "globals:\n  APP_NAME: envset\ndevelopment:\n  # local url\n  APP_URL: http://localhost\n"
+	if err := os.WriteFile(filepath.Join(dir, ".envset.yaml"), []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "development", "--env-file=.envset.yaml", "--", "sh", "-c", "printf \"$APP_URL\"")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("http://localhost") {
+		t.Fatalf("Expected %q to contain %q", testcli.Stdout(), "http://localhost")
+	}
+
+	testcli.Run(bin, "--env-file=.envset.yaml", "--", "sh", "-c", "printf \"$APP_NAME\"")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("envset") {
+		t.Fatalf("Expected %q to contain %q", testcli.Stdout(), "envset")
+	}
+
+	testcli.Run(bin, "--env-file=.envset.yaml", "template", "--print")
+	if !testcli.Success() {
+		t.Fatalf("Expected to succeed, stdout: %q stderr: %q error: %q", testcli.Stdout(), testcli.Stderr(), testcli.Error())
+	}
+	if !testcli.StdoutContains("# local url") {
+		t.Fatalf("Expected template %q to contain yaml comment", testcli.Stdout())
+	}
+}
+
 func Test_DefaultEnvCommandRunsAfterSeparator(t *testing.T) {
 	dir := t.TempDir()
 	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte("A=default_value\n"), 0644); err != nil {
@@ -369,6 +1188,25 @@ func Test_DefaultEnvCommandRunsAfterSeparator(t *testing.T) {
 	}
 }
 
+func Test_ExitCodeIsPropagated(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte("A=default_value\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	previousDir := cd(dir, t)
+	defer cd(previousDir, t)
+
+	testcli.Run(bin, "--restart=false", "--", "sh", "-c", "exit 3")
+	if testcli.Success() {
+		t.Fatal("Expected command to fail")
+	}
+
+	if got := testcli.Error().Error(); got != "exit status 3" {
+		t.Fatalf("Expected exit status 3, got %q", got)
+	}
+}
+
 func Test_TrailingSeparatorDoesNotPanic(t *testing.T) {
 	testcli.Run(bin, "--")
 
@@ -5,14 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/goliatone/go-envset/cmd/envset/internal/cliopts"
+	"github.com/goliatone/go-envset/cmd/envset/internal/report"
 	"github.com/goliatone/go-envset/pkg/config"
 	"github.com/goliatone/go-envset/pkg/envset"
-	"github.com/gosuri/uitable"
-	colors "github.com/logrusorgru/aurora/v3"
 	"github.com/tcnksm/go-gitconfig"
 	"github.com/urfave/cli/v2"
 )
@@ -30,8 +28,11 @@ func GetCommand(cnf *config.Config) *cli.Command {
 			&cli.StringFlag{Name: "filename", Usage: "metadata file `name`", Value: cnf.Meta.File},
 			&cli.StringFlag{Name: "filepath", Usage: "metadata file `path`", Value: cnf.Meta.Dir},
 			&cli.StringFlag{Name: "env-file", Value: cnf.Filename, Usage: "load environment from `FILE`"},
+			&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file, ini, dotenv, yaml, toml or json"},
 			&cli.BoolFlag{Name: "overwrite", Usage: "set to false to prevent overwrite metadata file", Value: true},
 			&cli.BoolFlag{Name: "values", Usage: "add flag to show values in the output"},
+			&cli.BoolFlag{Name: "reveal", Usage: "show secret values instead of **** with --values"},
+			&cli.BoolFlag{Name: "raw", Usage: "use sections as defined, without keys inherited from parent sections"},
 			&cli.BoolFlag{Name: "globals", Usage: "include global section", Value: false},
 			&cli.StringFlag{Name: "secret", Usage: "`password` used to encode hash values. Define env ENVSET_HASH_SECRET", EnvVars: []string{"ENVSET_HASH_SECRET"}},
 			&cli.StringFlag{
@@ -41,25 +42,32 @@ func GetCommand(cnf *config.Config) *cli.Command {
 				Value:   envset.HashSHA256,
 			},
 		},
-		Action: runMetadataCommand,
+		Action: func(c *cli.Context) error {
+			return runMetadataCommand(cnf, c)
+		},
 		Subcommands: []*cli.Command{
 			{
 				Name:  "compare",
 				Usage: "compare two metadata files",
-				UsageText: `envset metadata compare --section=[section] [target]
-   envset metadata compare --section=[section] [source] [target]
+				UsageText: `envset metadata compare [--section=[section]|--all] [target]
+   envset metadata compare [--section=[section]|--all] [source] [target]
 
 EXAMPLE:
-   envset metadata compare --section=development .meta/data.json .meta/prod.data.json`,
-				Description: `compares the provided [section] of two metadata files
+   envset metadata compare --section=development .meta/data.json .meta/prod.data.json
+   envset metadata compare --all .meta/prod.data.json`,
+				Description: `compares the provided [section] of two metadata files,
+   or every section in either file with --all or if --section is not set.
    [source] by default is .meta/data.json`,
 				Category: "METADATA",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "section",
-						Aliases:  []string{"s"},
-						Usage:    "env file section",
-						Required: true,
+						Name:    "section",
+						Aliases: []string{"s"},
+						Usage:   "env file section",
+					},
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "compare every section, default if --section is not set",
 					},
 					&cli.BoolFlag{
 						Name:  "print",
@@ -76,17 +84,61 @@ EXAMPLE:
 						Aliases: []string{"I"},
 						Usage:   "list of key names that are ignored",
 					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "print the comparison results to stdout in `format`: junit, sarif or markdown",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					return runMetadataCompare(cnf, c)
 				},
 			},
+			{
+				Name:  "verify",
+				Usage: "check the env file matches the metadata file",
+				UsageText: `envset metadata verify [--section=[section]]
+
+EXAMPLE:
+   envset metadata verify
+   envset metadata verify --section=development --secret=$ENVSET_HASH_SECRET
+   envset metadata verify --format=junit`,
+				Description: `creates the metadata of the env file in memory and compares it with
+   the metadata file without writing it, exits with an error if they are different`,
+				Category: "METADATA",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "section",
+						Aliases: []string{"s"},
+						Usage:   "only verify env file `section`",
+					},
+					&cli.StringFlag{Name: "filename", Usage: "metadata file `name`", Value: cnf.Meta.File},
+					&cli.StringFlag{Name: "filepath", Usage: "metadata file `path`", Value: cnf.Meta.Dir},
+					&cli.StringFlag{Name: "env-file", Value: cnf.Filename, Usage: "load environment from `FILE`"},
+					&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file, ini, dotenv, yaml, toml or json"},
+					&cli.BoolFlag{Name: "raw", Usage: "use sections as defined, without keys inherited from parent sections"},
+					&cli.BoolFlag{Name: "globals", Usage: "include global section", Value: false},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "print the verification results to stdout in `format`: junit, sarif or markdown",
+					},
+					&cli.StringFlag{Name: "secret", Usage: "`password` used to encode hash values. Define env ENVSET_HASH_SECRET", EnvVars: []string{"ENVSET_HASH_SECRET"}},
+					&cli.StringFlag{
+						Name:    "hash-algo",
+						Usage:   "hash algorithm used to hash values. Define with ENVSET_HASH_ALGORITHM",
+						EnvVars: []string{"ENVSET_HASH_ALGORITHM"},
+						Value:   envset.HashSHA256,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return runMetadataVerify(cnf, c)
+				},
+			},
 		},
 	}
 }
 
-func runMetadataCommand(c *cli.Context) error {
-	options, dir, shouldClean, err := metadataOptions(c)
+func runMetadataCommand(cnf *config.Config, c *cli.Context) error {
+	options, dir, shouldClean, err := metadataOptions(cnf, c)
 	if err != nil {
 		return err
 	}
@@ -96,10 +148,11 @@ func runMetadataCommand(c *cli.Context) error {
 		return err
 	}
 
+	diff := envset.MetadataDiff{}
 	envExists := exists(options.Filepath)
 	if envExists {
-		changed, err := metadataChanged(options.Filepath, &newEnv)
-		if err != nil || !changed && !options.Print {
+		diff, err = metadataDiff(options.Filepath, &newEnv)
+		if err != nil || diff.IsEmpty() && !options.Print {
 			return err
 		}
 	}
@@ -114,16 +167,18 @@ func runMetadataCommand(c *cli.Context) error {
 		return printMetadata(contents, dir, shouldClean)
 	}
 
-	return saveMetadata(options, contents, envExists)
-}
+	if err := saveMetadata(options, contents, envExists); err != nil {
+		return err
+	}
 
-func metadataOptions(c *cli.Context) (envset.MetadataOptions, string, bool, error) {
-	projectURL, err := gitconfig.OriginURL()
-	if err != nil && !isMissingRemoteURL(err) {
-		return envset.MetadataOptions{}, "", false, err
+	if envExists {
+		printMetadataDiff(c, options, diff)
 	}
+	return nil
+}
 
-	dir, err := filepath.Abs(c.String("filepath"))
+func metadataOptions(cnf *config.Config, c *cli.Context) (envset.MetadataOptions, string, bool, error) {
+	options, dir, err := newMetadataOptions(cnf, c)
 	if err != nil {
 		return envset.MetadataOptions{}, "", false, err
 	}
@@ -136,6 +191,22 @@ func metadataOptions(c *cli.Context) (envset.MetadataOptions, string, bool, erro
 		}
 	}
 
+	return options, dir, shouldClean, nil
+}
+
+// newMetadataOptions returns the options of the metadata command
+// and the absolute path of the metadata directory
+func newMetadataOptions(cnf *config.Config, c *cli.Context) (envset.MetadataOptions, string, error) {
+	projectURL, err := gitconfig.OriginURL()
+	if err != nil && !isMissingRemoteURL(err) {
+		return envset.MetadataOptions{}, "", err
+	}
+
+	dir, err := filepath.Abs(c.String("filepath"))
+	if err != nil {
+		return envset.MetadataOptions{}, "", err
+	}
+
 	algorithm := c.String("hash-algo")
 	secret := c.String("secret")
 	if secret != "" {
@@ -143,7 +214,8 @@ func metadataOptions(c *cli.Context) (envset.MetadataOptions, string, bool, erro
 	}
 
 	return envset.MetadataOptions{
-		Name:          cliopts.String(c, cliopts.EnvFileFlag),
+		Name:          cliopts.EnvFile(c),
+		Format:        cliopts.String(c, cliopts.EnvFileFormatFlag),
 		Filepath:      filepath.Join(dir, c.String("filename")),
 		Algorithm:     algorithm,
 		Project:       projectURL,
@@ -153,18 +225,31 @@ func metadataOptions(c *cli.Context) (envset.MetadataOptions, string, bool, erro
 		Print:         c.Bool("print"),
 		Values:        c.Bool("values"),
 		Secret:        secret,
-	}, dir, shouldClean, nil
+		Raw:           c.Bool("raw"),
+		SecretKeys:    cnf.SecretPatterns(),
+		Reveal:        c.Bool("reveal"),
+	}, dir, nil
 }
 
-func metadataChanged(path string, newEnv *envset.EnvFile) (bool, error) {
+func metadataDiff(path string, newEnv *envset.EnvFile) (envset.MetadataDiff, error) {
 	oldEnv, err := envset.LoadMetadataFile(path)
 	if err != nil {
-		return false, err
+		return envset.MetadataDiff{}, err
 	}
 
 	return envset.CompareMetadataFiles(newEnv, oldEnv)
 }
 
+func printMetadataDiff(c *cli.Context, options envset.MetadataOptions, diff envset.MetadataDiff) {
+	path := makeRelative(options.Filepath)
+	if options.Overwrite {
+		fmt.Fprintf(c.App.Writer, "updated %s:\n", path)
+	} else {
+		fmt.Fprintf(c.App.Writer, "%s is out of date, not updated with --overwrite=false:\n", path)
+	}
+	fmt.Fprint(c.App.Writer, diff.String())
+}
+
 func printMetadata(contents, dir string, shouldClean bool) error {
 	if shouldClean {
 		if err := os.RemoveAll(dir); err != nil {
@@ -198,6 +283,19 @@ func runMetadataCompare(cnf *config.Config, c *cli.Context) error {
 	printOutput := c.Bool("print")
 	asJSON := c.Bool("json")
 	name := c.String("section")
+
+	if name != "" && c.Bool("all") {
+		return cli.Exit("use either --section or --all", 1)
+	}
+
+	if format := c.String("format"); format != "" && !report.IsFormat(format) {
+		return cli.Exit(fmt.Sprintf("unknown format %q, expected %s", format, strings.Join(report.Formats, ", ")), 1)
+	}
+
+	if name == "" {
+		return runMetadataCompareAll(cnf, c)
+	}
+
 	ignored := cnf.MergeIgnored(name, c.StringSlice("ignore"))
 
 	source, target, err := metadataComparePaths(cnf, c)
@@ -217,7 +315,81 @@ func runMetadataCompare(cnf *config.Config, c *cli.Context) error {
 	diff := envset.CompareSections(*s1, *s2, ignored)
 	diff.Name = name
 
-	return reportCompareResult(diff, source, target, ignored, printOutput, asJSON)
+	if format := c.String("format"); format != "" {
+		return writeReport(c, format, "envset metadata compare", report.SectionChecks(diff, source, target))
+	}
+
+	return reportCompareResult(c, diff, source, target, printOutput, asJSON)
+}
+
+func runMetadataCompareAll(cnf *config.Config, c *cli.Context) error {
+	source, target, err := metadataComparePaths(cnf, c)
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	if msg := validateMetadataArgs(source, target); msg != "" {
+		return cli.Exit(msg, 1)
+	}
+
+	src := envset.EnvFile{}
+	if err := src.FromJSON(source); err != nil {
+		return cli.Exit(fmt.Sprintf("Unable to load source metadata file %q: %s", source, err), 1)
+	}
+
+	tgt := envset.EnvFile{}
+	if err := tgt.FromJSON(target); err != nil {
+		return cli.Exit(fmt.Sprintf("Unable to load target metadata file %q: %s", target, err), 1)
+	}
+
+	ignored := c.StringSlice("ignore")
+	res, err := envset.CompareAllSections(&src, &tgt, func(section string) []string {
+		return cnf.MergeIgnored(section, ignored)
+	})
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	if format := c.String("format"); format != "" {
+		return writeReport(c, format, "envset metadata compare", report.ComparisonChecks(res, source, target))
+	}
+
+	return reportCompareAllResult(res, source, target, c.Bool("print"), c.Bool("json"))
+}
+
+func runMetadataVerify(cnf *config.Config, c *cli.Context) error {
+	format := c.String("format")
+	if format != "" && !report.IsFormat(format) {
+		return cli.Exit(fmt.Sprintf("unknown format %q, expected %s", format, strings.Join(report.Formats, ", ")), 1)
+	}
+
+	options, _, err := newMetadataOptions(cnf, c)
+	if err != nil {
+		return err
+	}
+
+	path := makeRelative(options.Filepath)
+	if !exists(options.Filepath) {
+		return cli.Exit(fmt.Sprintf("metadata file %s not found, run envset metadata to create it", path), 1)
+	}
+
+	diff, err := envset.VerifyMetadataFile(options, c.String("section"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+
+	if format != "" {
+		return writeReport(c, format, "envset metadata verify", report.MetadataDiffChecks(diff, options.Name, path))
+	}
+
+	if diff.IsEmpty() {
+		fmt.Fprintf(c.App.Writer, "%s matches %s\n", options.Name, path)
+		return nil
+	}
+
+	fmt.Fprintf(c.App.Writer, "%s does not match %s:\n", options.Name, path)
+	fmt.Fprint(c.App.Writer, diff.String())
+	return cli.Exit("metadata is out of date, run envset metadata to update it", 1)
 }
 
 func metadataComparePaths(cnf *config.Config, c *cli.Context) (string, string, error) {
@@ -258,17 +430,28 @@ func loadCompareSections(source, target, name string) (*envset.EnvSection, *envs
 	return s1, s2, nil
 }
 
-func reportCompareResult(diff envset.EnvSection, source, target string, ignored []string, printOutput, asJSON bool) error {
+func reportCompareResult(c *cli.Context, diff envset.EnvSection, source, target string, printOutput, asJSON bool) error {
 	if diff.IsEmpty() {
+		if printOutput && !asJSON && len(diff.Ignored) > 0 {
+			report.PrettyPrint(diff, source, target)
+			return cli.Exit("", 0)
+		}
 		if printOutput && !asJSON {
-			prettyOk(source, target)
+			report.PrettyOk(source, target)
 			return cli.Exit("", 0)
 		}
+		if printOutput && len(diff.Ignored) > 0 {
+			j, err := diff.ToJSON()
+			if err != nil {
+				return cli.Exit(err, 1)
+			}
+			fmt.Fprintln(c.App.Writer, j)
+		}
 		return nil
 	}
 
 	if printOutput && !asJSON {
-		prettyPrint(diff, source, target, ignored)
+		report.PrettyPrint(diff, source, target)
 		return cli.Exit("", 1)
 	}
 
@@ -283,120 +466,41 @@ func reportCompareResult(diff envset.EnvSection, source, target string, ignored
 	return cli.Exit("Metadata test failed!", 1)
 }
 
-func prettyPrint(diff envset.EnvSection, source, target string, ignored []string) {
-
-	sort.Slice(diff.Keys, func(p, q int) bool {
-		return diff.Keys[p].Comment > diff.Keys[q].Comment
-	})
-
-	fmt.Println("")
-
-	mit := uitable.New()
-	mit.MaxColWidth = 50
-
-	mrt := uitable.New()
-	mrt.MaxColWidth = 50
-
-	dvt := uitable.New()
-	dvt.MaxColWidth = 50
-
-	mi := 0
-	mr := 0
-	dv := 0
-
-	for _, k := range diff.Keys {
-		if strings.Contains(k.Comment, "missing") {
-			if mi == 0 {
-
-				mit.AddRow(
-					"   "+colors.Bold("STATUS").Underline().String(),
-					colors.Bold("ENV KEY").Underline(),
-					colors.Bold("HASH").Underline(),
-				)
-			}
-			mi++
-			mit.AddRow("👻 Missing", k.Name, strmax(k.Hash, 12, "..."))
-		} else if strings.Contains(k.Comment, "extra") {
-			if mr == 0 {
-				mrt.AddRow(
-					"   "+colors.Bold("STATUS").Underline().String(),
-					colors.Bold("ENV KEY").Underline(),
-					colors.Bold("HASH").Underline(),
-				)
-			}
-			mr++
-			mrt.AddRow("🌱 Missing", k.Name, strmax(k.Hash, 12, "..."))
-		} else if strings.Contains(k.Comment, "different") {
-			if dv == 0 {
-				dvt.AddRow(
-					"   "+colors.Bold("STATUS").Underline().String(),
-					colors.Bold("ENV KEY").Underline(),
-					colors.Bold("HASH").Underline(),
-				)
-			}
-			dv++
-			dvt.AddRow("❓ Different", k.Name, strmax(k.Hash, 12, "..."))
-		}
+// writeReport prints the checks in format and exits with
+// an error if any check failed
+func writeReport(c *cli.Context, format, title string, checks []report.Check) error {
+	if err := report.Write(c.App.Writer, format, title, checks); err != nil {
+		return err
 	}
 
-	fmt.Printf("•  %s: %s\n", colors.Bold("source"), source)
-	fmt.Println(tableOrMessage(mit.String(), colors.Green("👍 source is not missing environment variables").String()))
-
-	fmt.Printf("\n\n•  %s: %s\n", colors.Bold("target"), target)
-	fmt.Println(tableOrMessage(mrt.String(), colors.Green("👍 target has no extra environment variables").String()))
-
-	fmt.Printf("\n\n•  %s\n", colors.Bold("different values"))
-	fmt.Println(tableOrMessage(dvt.String(), colors.Green("👍 All variables have same values").String()))
-
-	//TODO: print ignored keys
-	//for _, ik := range diff.Ignored
-
-	fmt.Println("")
-
-	//TODO: add dynamic padding
-	fmt.Printf(
-		"\n👻 Missing in %s (%d) | 🌱 Missing in %s (%d) \n\n❓ Different values  (%d) | 🤷 Ignored Keys (%d)\n\n",
-		colors.Bold("source"),
-		greenOrRed(mr).Bold(),
-		colors.Bold("target"),
-		greenOrRed(mi).Bold(),
-		greenOrYellow(dv).Bold(),
-		greenOrYellow(len(ignored)).Bold(),
-	)
-}
-
-func greenOrRed(val int) colors.Value {
-	if val == 0 {
-		return colors.Green(val)
+	if report.Failed(checks) > 0 {
+		return cli.Exit("", 1)
 	}
-	return colors.Red(val)
+	return nil
 }
 
-func greenOrYellow(val int) colors.Value {
-	if val == 0 {
-		return colors.Green(val)
+func reportCompareAllResult(res envset.MetadataComparison, source, target string, printOutput, asJSON bool) error {
+	if printOutput && !asJSON {
+		report.PrettyPrintAll(res, source, target)
+		if res.IsEmpty() {
+			return cli.Exit("", 0)
+		}
+		return cli.Exit("", 1)
 	}
-	return colors.Yellow(val)
-}
 
-func tableOrMessage(tbl, message string) string {
-	if tbl == "" {
-		return message
+	if res.IsEmpty() {
+		return nil
 	}
-	return tbl
-}
 
-func strmax(str string, l int, suffix string) string {
-	if len(str) <= l {
-		return str
+	if printOutput && asJSON {
+		j, err := res.ToJSON()
+		if err != nil {
+			return cli.Exit(err, 1)
+		}
+		return cli.Exit(j, 1)
 	}
-	return str[:l] + suffix
-}
 
-func prettyOk(source, target string) {
-	fmt.Printf("\n•  %s: %s\n", colors.Bold("source"), source)
-	fmt.Printf("•  %s: %s\n", colors.Bold("target"), target)
-	fmt.Printf("\n🚀 %s\n\n", colors.Bold("All good!").Green())
+	return cli.Exit(fmt.Sprintf("Metadata test failed! %d of %d sections are different", res.Different(), len(res.Sections)), 1)
 }
 
 func makeRelative(src string) string {
@@ -413,7 +517,8 @@ func exists(path string) bool {
 }
 
 func isMissingRemoteURL(err error) bool {
-	msg := err.Error()
+	//gitconfig quotes the key name, e.g. the key `remote.origin.url` is not found
+	msg := strings.ReplaceAll(err.Error(), "`", "")
 	return msg == "the key remote.origin.url is not found"
 }
 
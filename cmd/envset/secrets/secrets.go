@@ -0,0 +1,117 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/goliatone/go-envset/cmd/envset/internal/cliopts"
+	"github.com/goliatone/go-envset/pkg/config"
+	"github.com/goliatone/go-envset/pkg/envset"
+	"github.com/urfave/cli/v2"
+)
+
+// DefaultAddr is where secrets serve listens by default
+const DefaultAddr = "127.0.0.1:8787"
+
+// GetCommand returns a new cli.Command for the
+// secrets command
+func GetCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "secrets",
+		Usage: "work with ref+http:// secret providers",
+		Subcommands: []*cli.Command{
+			getServeCommand(cnf),
+		},
+	}
+}
+
+func getServeCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "serve",
+		Usage: "serve the values of an env file as a ref+http:// secret provider",
+		UsageText: `envset secrets serve --env-file [file] --addr [host:port]
+
+EXAMPLE:
+   envset secrets serve --env-file .envset.secrets --key-file .envset.key --token dev
+   curl -H "Authorization: Bearer dev" http://127.0.0.1:8787/production/DB_PASSWORD`,
+		Description: "serve GET /<section>/<KEY> as {\"value\": \"...\"} for local development and tests. " +
+			"Encrypted values and sections are decrypted with the key file or passphrase",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "env-file", Value: cnf.Filename, Usage: "serve secrets from `FILE`"},
+			&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file"},
+			&cli.StringFlag{Name: "addr", Value: DefaultAddr, Usage: "`address` to listen on"},
+			&cli.StringFlag{
+				Name:    "token",
+				Usage:   "bearer `token` clients have to send. Define with ENVSET_SECRETS_TOKEN",
+				EnvVars: []string{envset.SecretsTokenEnv},
+			},
+			&cli.StringFlag{
+				Name:  "key-file",
+				Usage: "`file` with the encryption key. Define with ENVSET_KEY_FILE",
+				Value: cnf.KeyFile,
+			},
+			&cli.StringFlag{
+				Name:  "passphrase",
+				Usage: "`passphrase` used instead of a key file. Define with ENVSET_PASSPHRASE",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			o := envset.SecretsServerOptions{
+				Filename:            cliopts.EnvFile(c),
+				Format:              cliopts.String(c, cliopts.EnvFileFormatFlag),
+				Token:               c.String("token"),
+				CommentSectionNames: cnf.CommentSectionNames.Keys,
+			}
+
+			//without a key we can still serve plain text values
+			keyFile, passphrase := cliopts.Key(c, cliopts.KeyFileFlag, "passphrase")
+			if keyFile != "" || passphrase != "" {
+				cipher, err := envset.LoadCipher(keyFile, passphrase)
+				if err != nil {
+					return err
+				}
+				o.Cipher = cipher
+			}
+
+			ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			return serve(ctx, c, c.String("addr"), envset.NewSecretsHandler(o))
+		},
+	}
+}
+
+func serve(ctx context.Context, c *cli.Context, addr string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+
+	fmt.Fprintf(c.App.Writer, "serving secrets on http://%s\n", addr)
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(shutdown); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
+}
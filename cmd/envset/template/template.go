@@ -1,19 +1,20 @@
 package template
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 
 	"github.com/goliatone/go-envset/cmd/envset/internal/cliopts"
 	"github.com/goliatone/go-envset/pkg/config"
 	"github.com/goliatone/go-envset/pkg/envset"
+	"github.com/goliatone/go-envset/pkg/exec"
 	"github.com/urfave/cli/v2"
 )
 
 // GetCommand exports template command
 func GetCommand(cnf *config.Config) *cli.Command {
 	return &cli.Command{
-		//TODO: This actually should load a template file and resolve it using the context.
 		//Default template should generate envset.example
 		Name:        "template",
 		Usage:       "make a template file from an environment",
@@ -23,11 +24,17 @@ func GetCommand(cnf *config.Config) *cli.Command {
 			&cli.StringFlag{Name: "filename", Usage: "template file `name`", Value: cnf.Template.File},
 			&cli.StringFlag{Name: "filepath", Usage: "template file `path`", Value: cnf.Template.Dir},
 			&cli.StringFlag{Name: "env-file", Value: cnf.Filename, Usage: "load environment from `FILE`"},
+			&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file, ini, dotenv, yaml, toml or json"},
 			&cli.BoolFlag{Name: "overwrite", Usage: "overwrite file, this will delete any changes"},
+			&cli.BoolFlag{Name: "raw", Usage: "use sections as defined, without keys inherited from parent sections"},
+		},
+		Subcommands: []*cli.Command{
+			getRenderCommand(cnf),
 		},
 		Action: func(c *cli.Context) error {
 			printOutput := c.Bool("print")
-			filename := cliopts.String(c, cliopts.EnvFileFlag)
+			filename := cliopts.EnvFile(c)
+			format := cliopts.String(c, cliopts.EnvFileFormatFlag)
 			template := c.String("filename")
 			dir := c.String("filepath")
 			overwrite := c.Bool("overwrite")
@@ -42,10 +49,58 @@ func GetCommand(cnf *config.Config) *cli.Command {
 					return err
 				}
 			}
-			//TODO: This should take a a template file which we use to run against our thing
 			template = filepath.Join(dir, template)
 
-			return envset.DocumentTemplate(filename, template, overwrite, printOutput)
+			return envset.CreateTemplateFile(envset.TemplateOptions{
+				Name:      filename,
+				Format:    format,
+				Template:  template,
+				Overwrite: overwrite,
+				Print:     printOutput,
+				Raw:       c.Bool("raw"),
+			})
+		},
+	}
+}
+
+func getRenderCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:      "render",
+		Usage:     "render a template file with the values of an environment",
+		UsageText: "envset template render --env development in.tpl > out",
+		Description: "render a template using {{KEY}} placeholders or text/template syntax, e.g. {{.KEY}}, " +
+			"with the resolved values of an environment",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "env", Usage: "environment `name` to render", Value: envset.DefaultSection},
+			&cli.StringSliceFlag{Name: "env-file", Value: cli.NewStringSlice(cnf.EnvFiles()...), Usage: "load environment from `FILE`, repeat to merge files in order"},
+			&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file, ini, dotenv, yaml, toml or json"},
+			&cli.StringFlag{Name: "key-file", Value: cnf.KeyFile, Usage: "`file` with the key to decrypt encrypted values"},
+			&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "write output to `file` instead of stdout"},
+			&cli.BoolFlag{Name: "allow-missing", Usage: "keep placeholders for variables that are not defined instead of failing"},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() != 1 {
+				return cli.Exit("template render expects one template file", 1)
+			}
+
+			env := cnf.ResolveAlias(c.String("env"))
+			o := cliopts.RunOptions(c, cnf, env, exec.ExecCmd{})
+
+			ro := envset.RenderOptions{
+				Template:     c.Args().First(),
+				AllowMissing: c.Bool("allow-missing"),
+			}
+
+			output := c.String("output")
+			if output == "" {
+				return envset.RenderTemplate(os.Stdout, env, ro, o)
+			}
+
+			var out bytes.Buffer
+			if err := envset.RenderTemplate(&out, env, ro, o); err != nil {
+				return err
+			}
+			return os.WriteFile(output, out.Bytes(), 0600)
 		},
 	}
 }
@@ -0,0 +1,146 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goliatone/go-envset/cmd/envset/internal/cliopts"
+	"github.com/goliatone/go-envset/cmd/envset/internal/report"
+	"github.com/goliatone/go-envset/pkg/config"
+	"github.com/goliatone/go-envset/pkg/envset"
+	"github.com/urfave/cli/v2"
+)
+
+// GetCommand returns a new cli.Command for the
+// validate command
+func GetCommand(cnf *config.Config) *cli.Command {
+	return &cli.Command{
+		Name:  "validate",
+		Usage: "validate environments with the schema",
+		UsageText: `envset validate [environment]
+   envset validate --all
+
+EXAMPLE:
+   envset validate production
+   envset validate --all --schema .envset.schema
+   envset validate --all --format=sarif`,
+		Description: "check the values of an environment with the types, required keys and defaults " +
+			"declared in the schema file, exits with an error if any key is invalid",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "all", Usage: "validate all environments in the env file"},
+			&cli.StringFlag{Name: "schema", Usage: "schema `file`, defaults to the schema file next to the env file"},
+			&cli.StringSliceFlag{Name: "env-file", Value: cli.NewStringSlice(cnf.EnvFiles()...), Usage: "load environment from `FILE`, repeat to merge files in order"},
+			&cli.StringFlag{Name: "env-file-format", Value: cnf.Format, Usage: "`format` of the env file, ini, dotenv, yaml, toml or json"},
+			&cli.StringFlag{Name: "key-file", Value: cnf.KeyFile, Usage: "`file` with the key to decrypt encrypted values"},
+			&cli.StringFlag{Name: "format", Usage: "print the results to stdout in `format`: junit, sarif or markdown"},
+		},
+		Action: func(c *cli.Context) error {
+			format := c.String("format")
+			if format != "" && !report.IsFormat(format) {
+				return cli.Exit(fmt.Sprintf("unknown format %q, expected %s", format, strings.Join(report.Formats, ", ")), 1)
+			}
+
+			files := cliopts.EnvFiles(c)
+
+			filename := c.String("schema")
+			if filename == "" {
+				filename = cnf.SchemaFile(files[0])
+			}
+			if filename == "" {
+				return cli.Exit("no schema found, create a .envset.schema file next to the env file or use --schema", 1)
+			}
+
+			schema, err := envset.LoadSchema(filename)
+			if err != nil {
+				return err
+			}
+
+			keyFile, passphrase := cliopts.Key(c, cliopts.KeyFileFlag, "")
+			o := envset.RunOptions{
+				Filename:            files[0],
+				Overlays:            files[1:],
+				Format:              cliopts.String(c, cliopts.EnvFileFormatFlag),
+				LocalOverlays:       cnf.LocalOverlays,
+				Expand:              cnf.Expand,
+				ExportEnvName:       cnf.ExportEnvName,
+				CommentSectionNames: cnf.CommentSectionNames.Keys,
+				KeyFile:             keyFile,
+				Passphrase:          passphrase,
+			}
+
+			environments, err := validateEnvironments(c, cnf, o)
+			if err != nil {
+				return err
+			}
+
+			invalid := 0
+			checks := []report.Check{}
+			for i, env := range environments {
+				res, err := envset.ValidateEnvironment(env, schema, o)
+				if err != nil {
+					return err
+				}
+				if !res.Valid() {
+					invalid++
+				}
+
+				if format != "" {
+					checks = append(checks, report.ValidationChecks(res, files[0])...)
+					continue
+				}
+
+				if i > 0 {
+					fmt.Fprintln(c.App.Writer)
+				}
+				if err := printReport(c, res); err != nil {
+					return err
+				}
+			}
+
+			if format != "" {
+				if err := report.Write(c.App.Writer, format, "envset validate", checks); err != nil {
+					return err
+				}
+			}
+
+			if invalid > 0 {
+				return cli.Exit(fmt.Sprintf("%d of %d environments are not valid", invalid, len(environments)), 1)
+			}
+			return nil
+		},
+	}
+}
+
+func validateEnvironments(c *cli.Context, cnf *config.Config, o envset.RunOptions) ([]string, error) {
+	if c.Bool("all") {
+		return envset.EnvironmentNames(o)
+	}
+
+	switch c.NArg() {
+	case 0:
+		return []string{envset.DefaultSection}, nil
+	case 1:
+		return []string{cnf.ResolveAlias(c.Args().First())}, nil
+	default:
+		return nil, cli.Exit("validate expects one environment or --all", 1)
+	}
+}
+
+func printReport(c *cli.Context, res envset.ValidationReport) error {
+	fmt.Fprintf(c.App.Writer, "[%s]\n", res.Environment)
+	if len(res.Results) == 0 {
+		fmt.Fprintln(c.App.Writer, "  no keys in schema")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0)
+	for _, r := range res.Results {
+		status := "ok"
+		if r.Error != "" {
+			status = "error: " + r.Error
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", r.Key.Name, r.Key.Type, status)
+	}
+	return w.Flush()
+}
@@ -3,6 +3,7 @@ module github.com/goliatone/go-envset
 go 1.25.0
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/gosuri/uitable v0.0.4
 	github.com/logrusorgru/aurora/v3 v3.0.0
 	github.com/onsi/gomega v1.40.0 // indirect
@@ -11,6 +12,7 @@ require (
 	github.com/tcnksm/go-gitconfig v0.1.2
 	github.com/urfave/cli/v2 v2.27.7
 	gopkg.in/ini.v1 v1.67.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -27,5 +29,4 @@ require (
 	github.com/xrash/smetrics v0.0.0-20240521201337-686a1a2994c1 // indirect
 	golang.org/x/sys v0.43.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
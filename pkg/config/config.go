@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"os"
 	"path"
+	"path/filepath"
 	"slices"
 	"time"
 
@@ -20,6 +23,15 @@ restart=true
 max_restarts=3
 restart_forever=false
 restart_exclude=test
+restart_delay=0s
+restart_max_delay=30s
+restart_jitter=0
+restart_reset_after=0s
+stop_timeout=10s
+watch_interval=500ms
+local_overlays=true
+mask_output=false
+schema=.envset.schema
 
 [metadata]
 dir=.meta
@@ -40,14 +52,29 @@ name=development
 [comments]
 key=COMMENTS
 key=DOCUMENTATION
+
+[secrets]
+# keys with names matching a pattern are secret, e.g.
+# key=*_SECRET
+# key=*_TOKEN
+# key=*_PASSWORD
 `)
 
 // Config has the rc config options
 type Config struct {
+	// Name is the path of the loaded rc file, empty if
+	// we are using the default configuration.
 	Name                string
 	Filename            string               `ini:"filename"`
+	Format              string               `ini:"file_format"`
+	Overlays            []string             `ini:"overlay,omitempty,allowshadow"`
+	LocalOverlays       bool                 `ini:"local_overlays"`
+	KeyFile             string               `ini:"key_file"`
+	Schema              string               `ini:"schema"`
 	Environments        *Environments        `ini:"environments"`
 	CommentSectionNames *CommentSectionNames `ini:"comments"`
+	SecretKeys          *SecretKeys          `ini:"secrets"`
+	MaskOutput          bool                 `ini:"mask_output"`
 	Created             time.Time            `ini:"-"`
 	Expand              bool                 `ini:"expand"`
 	Isolated            bool                 `ini:"isolated"`
@@ -57,10 +84,20 @@ type Config struct {
 	Template            *Template            `ini:"template"`
 	Ignored             map[string][]string
 	Required            map[string][]string
-	Restart             bool     `ini:"restart"`
-	ExcludeFromRestart  []string `ini:"restart_exclude"`
-	RestartForever      bool     `ini:"restart_forever"`
-	MaxRestarts         int      `ini:"max_restarts"`
+	LintRules           map[string]bool
+	Aliases             map[string]string
+	Restart             bool          `ini:"restart"`
+	ExcludeFromRestart  []string      `ini:"restart_exclude"`
+	RestartForever      bool          `ini:"restart_forever"`
+	MaxRestarts         int           `ini:"max_restarts"`
+	RestartDelay        time.Duration `ini:"restart_delay"`
+	RestartMaxDelay     time.Duration `ini:"restart_max_delay"`
+	RestartJitter       float64       `ini:"restart_jitter"`
+	RestartResetAfter   time.Duration `ini:"restart_reset_after"`
+	RestartExitCodes    []int         `ini:"restart_exit_codes"`
+	RestartIgnoreCodes  []int         `ini:"restart_ignore_exit_codes"`
+	StopTimeout         time.Duration `ini:"stop_timeout"`
+	WatchInterval       time.Duration `ini:"watch_interval"`
 }
 
 // Environments holds the environment names
@@ -73,6 +110,11 @@ type CommentSectionNames struct {
 	Keys []string `ini:"key,omitempty,allowshadow"`
 }
 
+// SecretKeys are name patterns of keys with secret values
+type SecretKeys struct {
+	Keys []string `ini:"key,omitempty,allowshadow"`
+}
+
 // Meta are options for the metadata command
 type Meta struct {
 	Dir    string `ini:"dir"`
@@ -113,6 +155,7 @@ func Load(name string) (*Config, error) {
 	if err != nil {
 		return &Config{}, err
 	}
+	c.Name = filename
 
 	if c.ExportEnvNameOld != "" {
 		c.ExportEnvName = c.ExportEnvNameOld
@@ -134,9 +177,83 @@ func Load(name string) (*Config, error) {
 		}
 	}
 
+	if sec, err := cfg.GetSection("aliases"); err == nil {
+		c.Aliases = make(map[string]string)
+		for _, k := range sec.Keys() {
+			c.Aliases[k.Name()] = k.String()
+		}
+	}
+
+	if sec, err := cfg.GetSection("lint"); err == nil {
+		c.LintRules = make(map[string]bool)
+		for _, k := range sec.Keys() {
+			v, err := k.Bool()
+			if err != nil {
+				return &Config{}, fmt.Errorf("lint rule %s: %w", k.Name(), err)
+			}
+			c.LintRules[k.Name()] = v
+		}
+	}
+
 	return c, nil
 }
 
+// EnvFiles returns the env file followed by the overlays
+// that are merged over it.
+func (c *Config) EnvFiles() []string {
+	return append([]string{c.Filename}, c.Overlays...)
+}
+
+// EnvironmentNames returns the configured environment names
+func (c *Config) EnvironmentNames() []string {
+	if c.Environments == nil {
+		return nil
+	}
+	return c.Environments.Names
+}
+
+// ResolveAlias returns the environment name of an alias,
+// e.g. dev for development, or name if it is not an alias.
+func (c *Config) ResolveAlias(name string) string {
+	if env, ok := c.Aliases[name]; ok {
+		return env
+	}
+	return name
+}
+
+// SecretPatterns returns the name patterns of keys with secret values
+func (c *Config) SecretPatterns() []string {
+	if c.SecretKeys == nil {
+		return nil
+	}
+	return c.SecretKeys.Keys
+}
+
+// SchemaFile returns the schema used to validate the environments
+// of envFile if it exists. A relative schema path is resolved next
+// to the env file, we don't look for it in parent directories.
+func (c *Config) SchemaFile(envFile string) string {
+	if c.Schema == "" {
+		return ""
+	}
+
+	//absolute paths are returned as they are
+	if filepath.IsAbs(c.Schema) {
+		return c.Schema
+	}
+
+	dir := "."
+	if filename, err := envset.FileFinder(envFile); err == nil {
+		dir = filepath.Dir(filename)
+	}
+
+	filename := filepath.Join(dir, c.Schema)
+	if _, err := os.Stat(filename); err != nil {
+		return ""
+	}
+	return filename
+}
+
 // MergeIgnored will merge ignored values from flags
 // with values from envsetrc for a given section
 func (c *Config) MergeIgnored(section string, ignored []string) []string {
@@ -227,12 +344,18 @@ func newConfig() *Config {
 	c.Restart = true
 	c.MaxRestarts = 3
 	c.RestartForever = false
+	c.RestartMaxDelay = 30 * time.Second
+	c.StopTimeout = envset.DefaultStopTimeout
+	c.WatchInterval = envset.DefaultWatchInterval
+	c.LocalOverlays = true
+	c.Schema = ".envset.schema"
 	c.Meta = &Meta{
 		Dir:    ".meta",
 		File:   "data.json",
 		Print:  true,
 		AsJSON: false,
 	}
+	c.SecretKeys = &SecretKeys{}
 	c.Template = &Template{
 		Dir:  ".",
 		File: "envset.example",
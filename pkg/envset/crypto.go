@@ -0,0 +1,350 @@
+package envset
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// EncryptedPrefix marks values encrypted by envset
+	EncryptedPrefix = "enc:v1:"
+	// EncryptedKey holds the encrypted key=values of a
+	// section that was encrypted as a whole.
+	EncryptedKey = "@encrypted"
+	// KeyFileEnv is the environment variable with the path
+	// to the key file used to encrypt and decrypt values.
+	KeyFileEnv = "ENVSET_KEY_FILE"
+	// PassphraseEnv is the environment variable with the
+	// passphrase used to encrypt and decrypt values.
+	PassphraseEnv = "ENVSET_PASSPHRASE"
+)
+
+const (
+	saltSize = 16
+	keySize  = 32
+)
+
+// pbkdf2Iterations follows the OWASP recommendation for
+// PBKDF2 with SHA-256
+var pbkdf2Iterations = 600000
+
+var tokenEncoding = base64.RawURLEncoding
+
+// Cipher encrypts and decrypts env values with AES-256-GCM
+// using a key derived from a secret with PBKDF2.
+//
+// Encrypted values look like enc:v1:<salt>:<nonce+ciphertext>.
+// A Cipher uses the same random salt for every value it encrypts
+// so we only derive a key once per file. Values are bound to the
+// section and key they are encrypted for, a value copied to another
+// key or section can't be decrypted.
+type Cipher struct {
+	secret []byte
+	salt   []byte
+	aeads  map[string]cipher.AEAD
+}
+
+// NewCipher returns a cipher for the given secret, which can be
+// a passphrase or the contents of a key file.
+func NewCipher(secret []byte) (*Cipher, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("encryption key is empty")
+	}
+	return &Cipher{
+		secret: secret,
+		aeads:  make(map[string]cipher.AEAD),
+	}, nil
+}
+
+// LoadCipher returns a cipher for the passphrase or the contents of
+// the key file, it is an error to set both. Trailing white space in
+// the key file is ignored.
+func LoadCipher(keyFile, passphrase string) (*Cipher, error) {
+	if keyFile != "" && passphrase != "" {
+		return nil, fmt.Errorf("both a key file and a passphrase are set, use one of them")
+	}
+
+	if passphrase != "" {
+		return NewCipher([]byte(passphrase))
+	}
+
+	if keyFile == "" {
+		return nil, fmt.Errorf("no encryption key, use --key-file or set %s or %s", KeyFileEnv, PassphraseEnv)
+	}
+
+	b, err := os.ReadFile(keyFile) // #nosec G304 -- envset intentionally reads user-provided key files.
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+
+	c, err := NewCipher(bytes.TrimRight(b, " \t\r\n"))
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", keyFile, err)
+	}
+	return c, nil
+}
+
+// IsEncrypted returns true if value was encrypted by envset
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix)
+}
+
+// Encrypt returns value as an enc:v1: token for key in section
+func (c *Cipher) Encrypt(section, key, value string) (string, error) {
+	if c.salt == nil {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("generate salt: %w", err)
+		}
+		c.salt = salt
+	}
+
+	aead, err := c.aead(c.salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), additionalData(section, key))
+	return EncryptedPrefix + tokenEncoding.EncodeToString(c.salt) + ":" + tokenEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the value of an enc:v1: token for key in section
+func (c *Cipher) Decrypt(section, key, token string) (string, error) {
+	payload, ok := strings.CutPrefix(token, EncryptedPrefix)
+	if !ok {
+		return "", fmt.Errorf("value is not encrypted")
+	}
+
+	saltStr, sealedStr, ok := strings.Cut(payload, ":")
+	if !ok {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+
+	salt, err := tokenEncoding.DecodeString(saltStr)
+	if err != nil || len(salt) != saltSize {
+		return "", fmt.Errorf("malformed encrypted value salt")
+	}
+
+	sealed, err := tokenEncoding.DecodeString(sealedStr)
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+
+	aead, err := c.aead(salt)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, additionalData(section, key))
+	if err != nil {
+		//authentication fails for wrong keys, modified values and
+		//values that were encrypted for another key or section
+		return "", fmt.Errorf("wrong key or corrupted value")
+	}
+	return string(plain), nil
+}
+
+// additionalData binds a value to its section and key
+func additionalData(section, key string) []byte {
+	return []byte(section + "\x00" + key)
+}
+
+func (c *Cipher) aead(salt []byte) (cipher.AEAD, error) {
+	if aead, ok := c.aeads[string(salt)]; ok {
+		return aead, nil
+	}
+
+	key, err := pbkdf2.Key(sha256.New, string(c.secret), salt, pbkdf2Iterations, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("new cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("new gcm: %w", err)
+	}
+
+	c.aeads[string(salt)] = aead
+	return aead, nil
+}
+
+// lazyCipher loads the cipher from the run options the first
+// time we find an encrypted value, unless we already have one.
+type lazyCipher struct {
+	options RunOptions
+	cipher  *Cipher
+}
+
+func (l *lazyCipher) decrypt(section, key, value string) (string, error) {
+	if l.cipher == nil {
+		c, err := LoadCipher(l.options.KeyFile, l.options.Passphrase)
+		if err != nil {
+			return "", fmt.Errorf("[%s] %s is encrypted: %w", section, key, err)
+		}
+		l.cipher = c
+	}
+
+	plain, err := l.cipher.Decrypt(section, key, value)
+	if err != nil {
+		return "", fmt.Errorf("decrypt [%s] %s: %w", section, key, err)
+	}
+	return plain, nil
+}
+
+// decryptContext replaces encrypted values in the context
+func decryptContext(environment string, context EnvMap, l *lazyCipher) error {
+	for _, k := range sortedEnvKeys(context) {
+		if !IsEncrypted(context[k]) {
+			continue
+		}
+		plain, err := l.decrypt(environment, k, context[k])
+		if err != nil {
+			return err
+		}
+		context[k] = plain
+	}
+	return nil
+}
+
+// decryptSections decrypts the values of the environment section
+// and the sections it extends before they are inherited, so values
+// are decrypted for the section that defines them. The @encrypted
+// key of a section is replaced with the keys it holds, keys defined
+// in plain text, e.g. in a local overlay, take precedence over
+// encrypted keys.
+func decryptSections(env *ini.File, environment string, sources map[*ini.Key]string, l *lazyCipher) error {
+	encrypted := false
+	for _, sec := range env.Sections() {
+		for _, key := range sec.Keys() {
+			encrypted = encrypted || IsEncrypted(key.Value())
+		}
+	}
+	if !encrypted {
+		return nil
+	}
+
+	r, err := newSectionInheritance(env)
+	if err != nil {
+		return fmt.Errorf("inherit sections: %w", err)
+	}
+
+	visited := make(map[string]bool)
+	for name := environment; name != "" && !visited[name]; name = r.sections[name].parent {
+		visited[name] = true
+
+		s, ok := r.sections[name]
+		if !ok {
+			continue
+		}
+
+		if err := decryptValues(name, s.sec, l); err != nil {
+			return err
+		}
+
+		if !s.sec.HasKey(EncryptedKey) {
+			continue
+		}
+
+		token := s.sec.Key(EncryptedKey)
+		plain, err := l.decrypt(name, EncryptedKey, token.Value())
+		if err != nil {
+			return err
+		}
+
+		keys, err := parseSectionBody(plain)
+		if err != nil {
+			return fmt.Errorf("decrypt [%s] %s: %w", name, EncryptedKey, err)
+		}
+
+		for _, key := range keys {
+			if s.sec.HasKey(key.Name()) {
+				continue
+			}
+			k, err := s.sec.NewKey(key.Name(), key.Value())
+			if err != nil {
+				return fmt.Errorf("new key %s.%s: %w", name, key.Name(), err)
+			}
+			//keys of encrypted sections are secrets
+			k.Comment = markSecret(key.Comment)
+			if sources != nil {
+				sources[k] = sources[token]
+			}
+		}
+		s.sec.DeleteKey(EncryptedKey)
+	}
+	return nil
+}
+
+// decryptValues replaces the encrypted values of a section in place,
+// decrypted keys are marked as secrets.
+func decryptValues(name string, sec *ini.Section, l *lazyCipher) error {
+	for _, key := range sec.Keys() {
+		if key.Name() == EncryptedKey || !IsEncrypted(key.Value()) {
+			continue
+		}
+		plain, err := l.decrypt(name, key.Name(), key.Value())
+		if err != nil {
+			return err
+		}
+		key.SetValue(plain)
+		key.Comment = markSecret(key.Comment)
+	}
+	return nil
+}
+
+// sectionBody serializes keys so we can encrypt a section. We write
+// the keys ourselves, ini.File.WriteTo depends on package globals.
+func sectionBody(keys []*ini.Key) (string, error) {
+	var b strings.Builder
+	for _, key := range keys {
+		for _, line := range strings.Split(key.Comment, "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if line[0] != '#' && line[0] != ';' {
+				line = "; " + line
+			}
+			b.WriteString(line + "\n")
+		}
+
+		value, err := quoteINI(key.Value())
+		if err != nil {
+			return "", fmt.Errorf("key %s: %w", key.Name(), err)
+		}
+		b.WriteString(key.Name() + "=" + value + "\n")
+	}
+	return b.String(), nil
+}
+
+func parseSectionBody(body string) ([]*ini.Key, error) {
+	file, err := ini.Load([]byte(body))
+	if err != nil {
+		return nil, fmt.Errorf("parse section: %w", err)
+	}
+	return file.Section(DefaultSection).Keys(), nil
+}
@@ -0,0 +1,157 @@
+package envset
+
+import "fmt"
+
+// SameValueComment is the comment of keys that have the same
+// value in both environments of a diff
+const SameValueComment = "same value"
+
+// DiffOptions configures DiffEnvironments
+type DiffOptions struct {
+	Filename            string
+	Format              string
+	Overlays            []string
+	LocalOverlays       bool
+	CommentSectionNames []string
+	// KeyFile and Passphrase decrypt encrypted values, we
+	// compare the plain values.
+	KeyFile    string
+	Passphrase string
+	// Algorithm used to hash values, HashHMAC if Secret is set
+	Algorithm string
+	Secret    string
+	// Ignored keys are not compared
+	Ignored []string
+	// Values adds values to the keys of the diff, values of
+	// secret keys are redacted unless Reveal is set. Hashes of
+	// secret keys are redacted too unless Secret or Reveal is set.
+	Values     bool
+	SecretKeys []string
+	Reveal     bool
+}
+
+// DiffEnvironments compares two environments of the env file, after
+// inheritance and decryption but without expanding values. The diff
+// has the keys CompareSections reports, with the hash and value of the
+// target in TargetHash and TargetValue for keys with different values,
+// and the keys with the same value with a SameValueComment comment.
+func DiffEnvironments(source, target string, o DiffOptions) (EnvSection, error) {
+	algorithm := o.Algorithm
+	if o.Secret != "" {
+		algorithm = HashHMAC
+	}
+	file := EnvFile{Algorithm: algorithm, secret: o.Secret}
+
+	secrets := make(map[string]bool)
+
+	s1, err := o.section(&file, source, secrets)
+	if err != nil {
+		return EnvSection{}, err
+	}
+
+	s2, err := o.section(&file, target, secrets)
+	if err != nil {
+		return EnvSection{}, err
+	}
+
+	targets := make(map[string]*EnvKey, len(s2.Keys))
+	for _, k := range s2.Keys {
+		targets[k.Name] = k
+	}
+
+	diff := CompareSections(*s1, *s2, o.Ignored)
+	for _, keys := range [][]*EnvKey{diff.Keys, diff.Ignored} {
+		for _, k := range keys {
+			if t, ok := targets[k.Name]; ok && k.Comment == "different hash value" {
+				k.TargetHash = t.Hash
+				k.TargetValue = t.Value
+			}
+		}
+	}
+
+	ignore := make(map[string]bool)
+	for _, k := range o.Ignored {
+		ignore[k] = true
+	}
+
+	for _, k := range s1.Keys {
+		if t, ok := targets[k.Name]; ok && !ignore[k.Name] && k.Hash == t.Hash {
+			k.Comment = SameValueComment
+			diff.Keys = append(diff.Keys, k)
+		}
+	}
+
+	//plain hashes of secret values can be brute forced, only
+	//hashes keyed with the HMAC secret are safe to show
+	if o.Secret == "" && !o.Reveal {
+		for _, keys := range [][]*EnvKey{diff.Keys, diff.Ignored} {
+			for _, k := range keys {
+				if secrets[k.Name] {
+					k.Hash = RedactedValue
+					if k.TargetHash != "" {
+						k.TargetHash = RedactedValue
+					}
+				}
+			}
+		}
+	}
+
+	diff.Name = source
+	return diff, nil
+}
+
+// section loads an environment of the env file as an EnvSection
+// and adds its secret keys to secrets
+func (o DiffOptions) section(file *EnvFile, environment string, secrets map[string]bool) (*EnvSection, error) {
+	options := RunOptions{
+		Filename:            o.Filename,
+		Format:              o.Format,
+		Overlays:            o.Overlays,
+		LocalOverlays:       o.LocalOverlays,
+		CommentSectionNames: o.CommentSectionNames,
+		KeyFile:             o.KeyFile,
+		Passphrase:          o.Passphrase,
+	}
+
+	env, err := getEnvFile(environment, options)
+	if err != nil {
+		return nil, err
+	}
+
+	sec, err := env.GetSection(environment)
+	if err != nil {
+		return nil, envSectionErrorNotFound{
+			err,
+			fmt.Sprintf("diff: section [%s] not found in env file", environment),
+		}
+	}
+
+	context := LoadIniSection(sec)
+	if err := decryptContext(environment, context, &lazyCipher{options: options}); err != nil {
+		return nil, err
+	}
+
+	for k := range secretKeys(sec, context, o.SecretKeys) {
+		secrets[k] = true
+	}
+
+	es := file.AddSection(environment)
+	for _, k := range sec.KeyStrings() {
+		if isSpecialKey(k) {
+			continue
+		}
+
+		v := context[k]
+		envKey, err := es.AddKey(k, v)
+		if err != nil {
+			return nil, fmt.Errorf("add key %s: %w", k, err)
+		}
+
+		if !o.Values {
+			envKey.Value = ""
+		} else if secrets[k] && !o.Reveal && v != "" {
+			envKey.Value = RedactedValue
+		}
+	}
+	return es, nil
+}
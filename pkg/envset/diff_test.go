@@ -0,0 +1,81 @@
+package envset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_DiffEnvironments(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".envset")
+	contents := "[development]\nAPP_NAME=envset\nDEBUG=true\nAPI_TOKEN=dev\nLOG_LEVEL=debug\n\n[staging : development]\nDEBUG=false\nAPI_TOKEN=stg\nREGION=eu\n"
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	diff, err := DiffEnvironments("development", "staging", DiffOptions{
+		Filename:   filename,
+		Ignored:    []string{"LOG_LEVEL"},
+		Values:     true,
+		SecretKeys: []string{"*_TOKEN"},
+	})
+	if err != nil {
+		t.Fatalf("diff environments: %v", err)
+	}
+
+	got := map[string][]string{}
+	for _, k := range diff.Keys {
+		got[k.Name] = []string{k.Comment, k.Value, k.TargetValue}
+	}
+
+	want := map[string][]string{
+		"APP_NAME":  {SameValueComment, "envset", ""},
+		"DEBUG":     {"different hash value", "true", "false"},
+		"API_TOKEN": {"different hash value", RedactedValue, RedactedValue},
+		"REGION":    {"missing in source", "eu", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if len(diff.Ignored) != 1 || diff.Ignored[0].Name != "LOG_LEVEL" || diff.Ignored[0].Comment != SameValueComment {
+		t.Fatalf("expected LOG_LEVEL to be ignored, got %+v", diff.Ignored)
+	}
+
+	for _, k := range diff.Keys {
+		if k.Name == "DEBUG" && (k.TargetHash == "" || k.TargetHash == k.Hash) {
+			t.Fatalf("expected target hash for DEBUG, got %q", k.TargetHash)
+		}
+	}
+
+	//hashes of secret keys are only shown keyed with a secret
+	hashes := func(o DiffOptions) map[string]string {
+		o.Filename = filename
+		o.SecretKeys = []string{"*_TOKEN"}
+		diff, err := DiffEnvironments("development", "staging", o)
+		if err != nil {
+			t.Fatalf("diff environments: %v", err)
+		}
+		out := map[string]string{}
+		for _, k := range diff.Keys {
+			out[k.Name] = k.Hash + " " + k.TargetHash
+		}
+		return out
+	}
+
+	if got := hashes(DiffOptions{Algorithm: HashSHA256})["API_TOKEN"]; got != RedactedValue+" "+RedactedValue {
+		t.Fatalf("expected redacted API_TOKEN hashes, got %q", got)
+	}
+	if got := hashes(DiffOptions{Algorithm: HashSHA256})["DEBUG"]; got == RedactedValue+" "+RedactedValue {
+		t.Fatalf("expected DEBUG hashes, got %q", got)
+	}
+	if got := hashes(DiffOptions{Secret: "s3cr3t"})["API_TOKEN"]; strings.Contains(got, RedactedValue) {
+		t.Fatalf("expected HMAC hashes for API_TOKEN, got %q", got)
+	}
+
+	if _, err := DiffEnvironments("development", "production", DiffOptions{Filename: filename}); !IsSectionNotFound(err) {
+		t.Fatalf("expected section not found error, got %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package envset
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// LoadDotenv will load a dotenv environment definition
+func LoadDotenv(b []byte) (EnvMap, error) {
+	file, err := parseDotenv("", b)
+	if err != nil {
+		return EnvMap{}, err
+	}
+	env := LoadIniSection(file.Section(DefaultSection))
+	for k, v := range env {
+		env[k] = unescapeDollar(v)
+	}
+	return env, nil
+}
+
+// parseDotenv parses a dotenv file into the default section of
+// an ini file so it can be used anywhere we use ini env files.
+//
+// Supported syntax:
+//
+//	# comment
+//	KEY=value # inline comment
+//	export KEY=value
+//	KEY='literal value, no ${VAR} or $(command)'
+//	KEY="escaped\nvalue"
+//	KEY="multi
+//	line value"
+func parseDotenv(filename string, b []byte) (*ini.File, error) {
+	file := ini.Empty()
+	sec := file.Section(DefaultSection)
+
+	p := &dotenvParser{
+		filename: filename,
+		src:      []rune(strings.ReplaceAll(string(b), "\r\n", "\n")),
+		line:     1,
+		col:      1,
+	}
+
+	comments := []string{}
+	for !p.eof() {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+
+		switch p.peek() {
+		case '\n':
+			p.next()
+		case '#':
+			comments = append(comments, strings.TrimSpace(p.readLine()))
+		default:
+			key, value, err := p.parseEntry()
+			if err != nil {
+				return nil, err
+			}
+
+			k, err := sec.NewKey(key, value)
+			if err != nil {
+				return nil, fmt.Errorf("new key %s: %w", key, err)
+			}
+
+			if len(comments) > 0 {
+				k.Comment = strings.Join(comments, "\n")
+				comments = comments[:0]
+			}
+		}
+	}
+
+	return file, nil
+}
+
+type dotenvParser struct {
+	filename string
+	src      []rune
+	pos      int
+	line     int
+	col      int
+}
+
+func (p *dotenvParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *dotenvParser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *dotenvParser) next() rune {
+	ch := p.src[p.pos]
+	p.pos++
+	if ch == '\n' {
+		p.line++
+		p.col = 1
+	} else {
+		p.col++
+	}
+	return ch
+}
+
+func (p *dotenvParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == '\r') {
+		p.next()
+	}
+}
+
+func (p *dotenvParser) readLine() string {
+	var out strings.Builder
+	for !p.eof() && p.peek() != '\n' {
+		out.WriteRune(p.next())
+	}
+	return out.String()
+}
+
+func (p *dotenvParser) errorf(line, col int, format string, args ...any) error {
+	return &ErrorSyntax{
+		Filename: p.filename,
+		Line:     line,
+		Column:   col,
+		Msg:      fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *dotenvParser) parseEntry() (string, string, error) {
+	key := p.readKey()
+	if key == "export" && (p.peek() == ' ' || p.peek() == '\t') {
+		p.skipSpace()
+		key = p.readKey()
+	}
+
+	if key == "" {
+		return "", "", p.errorf(p.line, p.col, "unexpected character %q, expected variable name", p.peek())
+	}
+
+	p.skipSpace()
+	if p.peek() != '=' {
+		return "", "", p.errorf(p.line, p.col, "expected '=' after %s", key)
+	}
+	p.next()
+	p.skipSpace()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return "", "", err
+	}
+
+	return key, value, nil
+}
+
+func (p *dotenvParser) readKey() string {
+	var out strings.Builder
+	for !p.eof() && isKeyRune(p.peek(), out.Len() == 0) {
+		out.WriteRune(p.next())
+	}
+	return out.String()
+}
+
+func isKeyRune(ch rune, first bool) bool {
+	if ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') {
+		return true
+	}
+	if first {
+		return false
+	}
+	return ch == '.' || ch == '-' || (ch >= '0' && ch <= '9')
+}
+
+func (p *dotenvParser) parseValue() (string, error) {
+	var value string
+	var err error
+
+	switch p.peek() {
+	case '\'':
+		value, err = p.parseSingleQuoted()
+	case '"':
+		value, err = p.parseDoubleQuoted()
+	default:
+		return p.parseUnquoted(), nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	//only whitespace or a comment can follow a quoted value
+	p.skipSpace()
+	if p.peek() == '#' {
+		p.readLine()
+	}
+
+	if !p.eof() && p.peek() != '\n' {
+		return "", p.errorf(p.line, p.col, "unexpected character %q after quoted value", p.peek())
+	}
+
+	return value, nil
+}
+
+func (p *dotenvParser) parseSingleQuoted() (string, error) {
+	line, col := p.line, p.col
+	p.next()
+
+	var out strings.Builder
+	for !p.eof() {
+		ch := p.next()
+		if ch == '\'' {
+			return out.String(), nil
+		}
+		//escape $ so the value is not expanded
+		if ch == '$' {
+			out.WriteRune('\\')
+		}
+		out.WriteRune(ch)
+	}
+
+	return "", p.errorf(line, col, "unterminated single quoted value")
+}
+
+func (p *dotenvParser) parseDoubleQuoted() (string, error) {
+	line, col := p.line, p.col
+	p.next()
+
+	var out strings.Builder
+	for !p.eof() {
+		ch := p.next()
+		switch ch {
+		case '"':
+			return out.String(), nil
+		case '\\':
+			if p.eof() {
+				continue
+			}
+			out.WriteString(unescapeDotenv(p.next()))
+		default:
+			out.WriteRune(ch)
+		}
+	}
+
+	return "", p.errorf(line, col, "unterminated double quoted value")
+}
+
+func unescapeDotenv(ch rune) string {
+	switch ch {
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case '"', '\\':
+		return string(ch)
+	default:
+		//keep unknown escape sequences, e.g. \$
+		return "\\" + string(ch)
+	}
+}
+
+func (p *dotenvParser) parseUnquoted() string {
+	var out strings.Builder
+	for !p.eof() && p.peek() != '\n' {
+		ch := p.next()
+		//a # preceded by whitespace starts an inline comment
+		if ch == '#' && (out.Len() == 0 || strings.HasSuffix(out.String(), " ") || strings.HasSuffix(out.String(), "\t")) {
+			p.readLine()
+			break
+		}
+		out.WriteRune(ch)
+	}
+	return strings.TrimSpace(out.String())
+}
@@ -0,0 +1,147 @@
+package envset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func Test_LoadDotenv(t *testing.T) {
+	fixture := []byte(`# database settings
+export DB_HOST=localhost
+DB_PORT = 5432 # inline comment
+DB_NAME=app#name
+SINGLE='literal \n ${VAR} # not a comment'
+DOUBLE="line1\nline2 \"quoted\" # not a comment"
+MULTI="first
+second"
+EMPTY=
+URL=postgres://$(whoami)@localhost/db
+`)
+
+	env, err := LoadDotenv(fixture)
+	if err != nil {
+		t.Fatalf("load dotenv: %v", err)
+	}
+
+	expected := EnvMap{
+		"DB_HOST": "localhost",
+		"DB_PORT": "5432",
+		"DB_NAME": "app#name",
+		"SINGLE":  `literal \n ${VAR} # not a comment`,
+		"DOUBLE":  "line1\nline2 \"quoted\" # not a comment",
+		"MULTI":   "first\nsecond",
+		"EMPTY":   "",
+		"URL":     "postgres://$(whoami)@localhost/db",
+	}
+
+	if len(env) != len(expected) {
+		t.Fatalf("got %d keys, want %d: %v", len(env), len(expected), env)
+	}
+
+	for k, want := range expected {
+		if got := env[k]; got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func Test_LoadDotenv_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		line    int
+		column  int
+	}{
+		{name: "missing key", fixture: "A=1\n=2\n", line: 2, column: 1},
+		{name: "missing equal", fixture: "A=1\nexport B\n", line: 2, column: 9},
+		{name: "unterminated double quote", fixture: "A=1\nB=\"value\n", line: 2, column: 3},
+		{name: "unterminated single quote", fixture: "A='value\n", line: 1, column: 3},
+		{name: "text after quote", fixture: "A=\"value\" extra\n", line: 1, column: 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadDotenv([]byte(tt.fixture))
+
+			var syntaxErr *ErrorSyntax
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("err = %v, want ErrorSyntax", err)
+			}
+			if syntaxErr.Line != tt.line || syntaxErr.Column != tt.column {
+				t.Fatalf("position = %d:%d, want %d:%d (%s)", syntaxErr.Line, syntaxErr.Column, tt.line, tt.column, err)
+			}
+		})
+	}
+}
+
+func Test_LoadFile_DetectsFormat(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".env.local")
+	if err := os.WriteFile(envFile, []byte("# comment\nexport A=\"quoted value\"\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	file, err := LoadFile(envFile, "", ini.LoadOptions{})
+	if err != nil {
+		t.Fatalf("load file: %v", err)
+	}
+
+	key := file.Section(DefaultSection).Key("A")
+	if key.String() != "quoted value" {
+		t.Fatalf("A = %q, want %q", key.String(), "quoted value")
+	}
+	if key.Comment != "# comment" {
+		t.Fatalf("comment = %q, want %q", key.Comment, "# comment")
+	}
+}
+
+func Test_LoadEnvironment_DotenvSingleQuotedLiterals(t *testing.T) {
+	t.Setenv("HOME", "/home/envset")
+
+	filename := filepath.Join(t.TempDir(), ".env")
+	contents := "A=$(echo hi)\nB=${HOME}\nC='$(echo hi)'\nD='${HOME}'\nE='a\\$b'\nF=\"\\$(echo hi)\"\n"
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	env, err := loadEnvironment(DefaultSection, RunOptions{Filename: filename, ExportEnvName: "APP_ENV", Expand: true})
+	if err != nil {
+		t.Fatalf("load environment: %v", err)
+	}
+
+	expected := EnvMap{
+		"A": "hi",
+		"B": "/home/envset",
+		"C": "$(echo hi)",
+		"D": "${HOME}",
+		"E": `a\$b`,
+		"F": "$(echo hi)",
+	}
+	for k, want := range expected {
+		if got := env[k]; got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func Test_DetectFormat(t *testing.T) {
+	tests := map[string]string{
+		".env":               FormatDotenv,
+		".env.local":         FormatDotenv,
+		"/app/.env":          FormatDotenv,
+		"production.env":     FormatDotenv,
+		".envset":            FormatINI,
+		".envset.local":      FormatINI,
+		"/app/config/.envrc": FormatINI,
+	}
+
+	for name, want := range tests {
+		if got := DetectFormat(name); got != want {
+			t.Errorf("DetectFormat(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package envset
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EditOptions configures SetValues, GetValues and UnsetValues
+type EditOptions struct {
+	Filename string
+	Format   string
+	// Section we edit, ignored if AllSections is true
+	Section string
+	// AllSections edits every environment of the env file.
+	// DEFAULT is only edited if the file has no sections.
+	AllSections bool
+	// CommentSectionNames are kept as they are
+	CommentSectionNames []string
+}
+
+// SectionValue is the value of a key in a section
+type SectionValue struct {
+	Section string
+	Value   string
+}
+
+// SetValues sets KEY=value pairs in an ini env file in place and
+// returns the sections it updated. Keys that are not defined in a
+// section are added after its last key.
+func SetValues(o EditOptions, values []string) ([]string, error) {
+	keys := make([]string, 0, len(values))
+	vals := make([]string, 0, len(values))
+	for _, kv := range values {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected KEY=value, got %q", kv)
+		}
+		if !isVarName(key) {
+			return nil, fmt.Errorf("invalid variable name %q", key)
+		}
+		keys = append(keys, key)
+		vals = append(vals, value)
+	}
+
+	e, err := loadEnvFileEditor(o.Filename, o.Format, o.CommentSectionNames)
+	if err != nil {
+		return nil, err
+	}
+
+	sections, err := o.sections(e)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, section := range sections {
+		for i, key := range keys {
+			if err := e.setValue(section, key, vals[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	//refuse to save values ini would not read back as they are
+	file, err := e.parse()
+	if err != nil {
+		return nil, err
+	}
+	for _, section := range sections {
+		for i, key := range keys {
+			if err := e.checkValue(file, section, key, vals[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sections, e.save()
+}
+
+// GetValues returns the value of a key in the sections of an env
+// file, including values sections inherit. Values are returned as
+// they are defined, without expanding or decrypting them.
+func GetValues(o EditOptions, key string) ([]SectionValue, error) {
+	e, err := loadEnvFileEditor(o.Filename, o.Format, o.CommentSectionNames)
+	if err != nil {
+		return nil, err
+	}
+
+	sections, err := o.sections(e)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := InheritSections(e.file, true)
+	if err != nil {
+		return nil, fmt.Errorf("inherit sections: %w", err)
+	}
+
+	values := []SectionValue{}
+	for _, section := range sections {
+		sec := file.Section(section)
+		if !sec.HasKey(key) {
+			continue
+		}
+		values = append(values, SectionValue{Section: section, Value: sec.Key(key).String()})
+	}
+
+	if len(values) == 0 {
+		return nil, o.keyNotFound(key)
+	}
+	return values, nil
+}
+
+// UnsetValues removes keys and their comments from an ini env file
+// in place and returns the sections it updated. Keys a section
+// inherits are not removed.
+func UnsetValues(o EditOptions, keys []string) ([]string, error) {
+	e, err := loadEnvFileEditor(o.Filename, o.Format, o.CommentSectionNames)
+	if err != nil {
+		return nil, err
+	}
+
+	sections, err := o.sections(e)
+	if err != nil {
+		return nil, err
+	}
+
+	if !o.AllSections {
+		sec, _ := e.section(o.Section)
+		if err := checkKeys(sec, keys); err != nil {
+			return nil, err
+		}
+	}
+
+	updated := []string{}
+	for _, section := range sections {
+		found := false
+		for _, key := range keys {
+			if len(e.keySpans(section, key)) == 0 {
+				continue
+			}
+			e.deleteKey(section, key)
+			found = true
+		}
+		if found {
+			updated = append(updated, section)
+		}
+	}
+
+	if len(updated) == 0 {
+		return nil, o.keyNotFound(strings.Join(keys, ", "))
+	}
+	return updated, e.save()
+}
+
+// sections returns the names of the sections we edit
+func (o EditOptions) sections(e *envFileEditor) ([]string, error) {
+	if !o.AllSections {
+		sec, err := e.section(o.Section)
+		if err != nil {
+			return nil, err
+		}
+		name, _ := sectionParent(sec.Name())
+		return []string{name}, nil
+	}
+
+	names := []string{}
+	for _, sec := range e.file.Sections() {
+		if sec.Name() == DefaultSection || sec.Body() != "" {
+			continue
+		}
+		name, _ := sectionParent(sec.Name())
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		names = append(names, DefaultSection)
+	}
+	return names, nil
+}
+
+func (o EditOptions) keyNotFound(key string) error {
+	if o.AllSections {
+		return fmt.Errorf("%s not found in env file", key)
+	}
+	return fmt.Errorf("%s not found in section [%s]", key, o.Section)
+}
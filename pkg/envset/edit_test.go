@@ -0,0 +1,185 @@
+package envset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+const editTestFile = `# globals
+APP_NAME=envset
+
+[development]
+# the api
+API_URL=http://localhost
+DEBUG=true
+
+[COMMENTS]
+free text = kept as is
+
+[staging : development]
+DEBUG=false
+`
+
+func Test_SetValues(t *testing.T) {
+	filename := writeSecretsFile(t, editTestFile)
+	o := EditOptions{Filename: filename, Section: "development", CommentSectionNames: []string{"COMMENTS"}}
+
+	values := []string{"API_URL=http://api", "GREETING=hello world", `QUOTE=say "hi"`, "EMPTY="}
+	sections, err := SetValues(o, values)
+	if err != nil || !reflect.DeepEqual(sections, []string{"development"}) {
+		t.Fatalf("set values: %v %v", sections, err)
+	}
+
+	want := strings.Replace(editTestFile, "API_URL=http://localhost\nDEBUG=true\n",
+		"API_URL=http://api\nDEBUG=true\nGREETING=\"hello world\"\nQUOTE=`say \"hi\"`\nEMPTY=\n", 1)
+	b, _ := os.ReadFile(filename)
+	if string(b) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", b, want)
+	}
+
+	file, err := ini.Load(filename)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if v := file.Section("development").Key("QUOTE").String(); v != `say "hi"` {
+		t.Fatalf("unexpected value %q", v)
+	}
+	if v := file.Section("development").Key("GREETING").String(); v != "hello world" {
+		t.Fatalf("unexpected value %q", v)
+	}
+
+	o = EditOptions{Filename: filename, AllSections: true, CommentSectionNames: []string{"COMMENTS"}}
+	sections, err = SetValues(o, []string{"DEBUG=0"})
+	if err != nil || !reflect.DeepEqual(sections, []string{"development", "staging"}) {
+		t.Fatalf("set all values: %v %v", sections, err)
+	}
+	b, _ = os.ReadFile(filename)
+	if strings.Count(string(b), "DEBUG=0\n") != 2 || !strings.Contains(string(b), "free text = kept as is") {
+		t.Fatalf("unexpected file:\n%s", b)
+	}
+
+	if _, err := SetValues(o, []string{"DEBUG"}); err == nil || !strings.Contains(err.Error(), "expected KEY=value") {
+		t.Fatalf("expected KEY=value error, got %v", err)
+	}
+	if _, err := SetValues(o, []string{"1DEBUG=1"}); err == nil || !strings.Contains(err.Error(), "invalid variable name") {
+		t.Fatalf("expected invalid name error, got %v", err)
+	}
+	if _, err := SetValues(EditOptions{Filename: filename, Section: "qa"}, []string{"A=1"}); !IsSectionNotFound(err) {
+		t.Fatalf("expected section not found, got %v", err)
+	}
+}
+
+func Test_SetValues_RoundTrip(t *testing.T) {
+	filename := writeSecretsFile(t, editTestFile)
+	o := EditOptions{Filename: filename, Section: "development", CommentSectionNames: []string{"COMMENTS"}}
+
+	values := map[string]string{
+		"WINDOWS":   `C:\dir\`,
+		"QUOTED":    `C:\"dir"\`,
+		"MULTILINE": "a\nb`c",
+		"TRIPLE":    `a"""b`,
+		"COMMENT":   "a # b",
+	}
+	for key, value := range values {
+		if _, err := SetValues(o, []string{key + "=" + value}); err != nil {
+			t.Fatalf("set %s: %v", key, err)
+		}
+	}
+
+	for key, value := range values {
+		got, err := GetValues(o, key)
+		if err != nil || got[0].Value != value {
+			t.Fatalf("get %s: expected %q got %v %v", key, value, got, err)
+		}
+	}
+
+	before, _ := os.ReadFile(filename)
+	for _, value := range []string{"a\"\"\"b\nc", "a\"\"\"b`c"} {
+		if _, err := SetValues(o, []string{"B=" + value}); err == nil {
+			t.Fatalf("expected error setting %q", value)
+		}
+	}
+	if after, _ := os.ReadFile(filename); string(after) != string(before) {
+		t.Fatalf("expected file to be unchanged, got:\n%s", after)
+	}
+}
+
+func Test_quoteINI(t *testing.T) {
+	for _, value := range []string{"plain", "a b", `a\`, "a;b", `'a'`, `"a"`, "`a`", "a\nb", `a"""b`, " a ", `a\b`} {
+		quoted, err := quoteINI(value)
+		if err != nil {
+			t.Fatalf("quote %q: %v", value, err)
+		}
+		file, err := ini.Load([]byte("A=" + quoted + "\nB=1\n"))
+		if err != nil {
+			t.Fatalf("load %q: %v", quoted, err)
+		}
+		if got := file.Section("").Key("A").String(); got != value {
+			t.Fatalf("expected %q got %q from %q", value, got, quoted)
+		}
+	}
+
+	if _, err := quoteINI("a\"\"\"b\nc"); err == nil {
+		t.Fatal("expected error for \"\"\" and a line break")
+	}
+}
+
+func Test_GetValues(t *testing.T) {
+	filename := writeSecretsFile(t, editTestFile)
+
+	values, err := GetValues(EditOptions{Filename: filename, Section: "staging", CommentSectionNames: []string{"COMMENTS"}}, "API_URL")
+	if err != nil || !reflect.DeepEqual(values, []SectionValue{{Section: "staging", Value: "http://localhost"}}) {
+		t.Fatalf("get inherited value: %v %v", values, err)
+	}
+
+	values, err = GetValues(EditOptions{Filename: filename, AllSections: true, CommentSectionNames: []string{"COMMENTS"}}, "DEBUG")
+	want := []SectionValue{{Section: "development", Value: "true"}, {Section: "staging", Value: "false"}}
+	if err != nil || !reflect.DeepEqual(values, want) {
+		t.Fatalf("get all values: %v %v", values, err)
+	}
+
+	if _, err := GetValues(EditOptions{Filename: filename, Section: "development"}, "MISSING"); err == nil || err.Error() != "MISSING not found in section [development]" {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func Test_UnsetValues(t *testing.T) {
+	filename := writeSecretsFile(t, editTestFile)
+	info, _ := os.Stat(filename)
+	if err := os.Chmod(filename, 0600); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+
+	sections, err := UnsetValues(EditOptions{Filename: filename, Section: "development", CommentSectionNames: []string{"COMMENTS"}}, []string{"API_URL"})
+	if err != nil || !reflect.DeepEqual(sections, []string{"development"}) {
+		t.Fatalf("unset values: %v %v", sections, err)
+	}
+
+	b, _ := os.ReadFile(filename)
+	if want := strings.Replace(editTestFile, "# the api\nAPI_URL=http://localhost\n", "", 1); string(b) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", b, want)
+	}
+	if info, _ = os.Stat(filename); info.Mode().Perm() != 0600 {
+		t.Fatalf("expected permissions to be kept, got %v", info.Mode().Perm())
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(filepath.Dir(filename), "*.tmp")); len(tmp) > 0 {
+		t.Fatalf("expected temporary files to be removed, got %v", tmp)
+	}
+
+	sections, err = UnsetValues(EditOptions{Filename: filename, AllSections: true, CommentSectionNames: []string{"COMMENTS"}}, []string{"DEBUG"})
+	if err != nil || !reflect.DeepEqual(sections, []string{"development", "staging"}) {
+		t.Fatalf("unset all values: %v %v", sections, err)
+	}
+
+	if _, err := UnsetValues(EditOptions{Filename: filename, Section: "development"}, []string{"DEBUG"}); err == nil {
+		t.Fatal("expected error removing missing key")
+	}
+	if _, err := UnsetValues(EditOptions{Filename: filename, AllSections: true}, []string{"DEBUG"}); err == nil || err.Error() != "DEBUG not found in env file" {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
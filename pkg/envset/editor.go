@@ -0,0 +1,323 @@
+package envset
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// envFileEditor updates keys of an ini env file in place. We use
+// ini to read values but write the file line by line, lines we
+// don't change are kept as they are so comments, blank lines and
+// comment sections survive the update.
+type envFileEditor struct {
+	filename        string
+	perm            os.FileMode
+	file            *ini.File
+	lines           []string
+	commentSections []string
+}
+
+// keySpan are the lines of a key in the env file, comments are
+// the comment lines right above the key.
+type keySpan struct {
+	section  string
+	key      string
+	comments int
+	start    int
+	end      int
+	prefix   string
+}
+
+func loadEnvFileEditor(name, format string, commentSections []string) (*envFileEditor, error) {
+	filename, err := FileFinder(name)
+	if err != nil {
+		return nil, fmt.Errorf("file finder %s: %w", name, err)
+	}
+
+	if format == "" {
+		format = DetectFormat(filename)
+	}
+	if format != FormatINI {
+		return nil, fmt.Errorf("can only update ini env files, %s is %s", filename, format)
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, fmt.Errorf("stat %s: %w", filename, err)
+	}
+
+	b, err := os.ReadFile(filename) // #nosec G304 -- envset intentionally reads user-provided env files.
+	if err != nil {
+		return nil, fmt.Errorf("read file %s: %w", filename, err)
+	}
+
+	file, err := ini.LoadSources(ini.LoadOptions{UnparseableSections: commentSections}, b)
+	if err != nil {
+		return nil, fmt.Errorf("ini load %s: %w", filename, err)
+	}
+
+	return &envFileEditor{
+		filename:        filename,
+		perm:            info.Mode().Perm(),
+		file:            file,
+		lines:           strings.SplitAfter(string(b), "\n"),
+		commentSections: commentSections,
+	}, nil
+}
+
+// section returns the parsed section, names are matched
+// without the section they extend.
+func (e *envFileEditor) section(name string) (*ini.Section, error) {
+	if sec := findSection(e.file, name); sec != nil {
+		return sec, nil
+	}
+	return nil, envSectionErrorNotFound{
+		nil,
+		fmt.Sprintf("section [%s] not found in env file", name),
+	}
+}
+
+// scan returns the key lines of the file and for each section the
+// line after its last key, where we can add new keys.
+func (e *envFileEditor) scan() ([]keySpan, map[string]int) {
+	spans := []keySpan{}
+	ends := map[string]int{DefaultSection: 0}
+
+	section := DefaultSection
+	raw := false
+	comments := -1
+
+	for i := 0; i < len(e.lines); i++ {
+		line := strings.TrimSpace(e.lines[i])
+
+		switch {
+		case line == "":
+			comments = -1
+			continue
+		case line[0] == '#' || line[0] == ';':
+			if comments == -1 {
+				comments = i
+			}
+			continue
+		case line[0] == '[':
+			end := strings.LastIndexByte(line, ']')
+			if end == -1 {
+				continue
+			}
+			name := line[1:end]
+			raw = slices.Contains(e.commentSections, name)
+			section, _ = sectionParent(name)
+			ends[section] = i + 1
+			comments = -1
+			continue
+		}
+
+		if raw {
+			continue
+		}
+
+		span := keySpan{section: section, comments: i, start: i}
+		if comments != -1 {
+			span.comments = comments
+		}
+		comments = -1
+
+		delim := strings.IndexAny(e.lines[i], "=:")
+		if delim == -1 {
+			continue
+		}
+		span.key = strings.TrimSpace(e.lines[i][:delim])
+
+		rest := e.lines[i][delim+1:]
+		value := strings.TrimLeft(rest, " \t")
+		span.prefix = e.lines[i][:delim+1] + rest[:len(rest)-len(value)]
+
+		//values can span lines with """ quotes or \ continuations
+		switch {
+		case strings.HasPrefix(value, `"""`) && !strings.Contains(value[3:], `"""`):
+			for i+1 < len(e.lines) {
+				i++
+				if strings.Contains(e.lines[i], `"""`) {
+					break
+				}
+			}
+		default:
+			for strings.HasSuffix(strings.TrimRight(e.lines[i], "\r\n"), `\`) && i+1 < len(e.lines) {
+				i++
+			}
+		}
+
+		span.end = min(i+1, len(e.lines))
+		spans = append(spans, span)
+		ends[section] = span.end
+	}
+
+	return spans, ends
+}
+
+func (e *envFileEditor) keySpans(section, key string) []keySpan {
+	spans, _ := e.scan()
+	out := []keySpan{}
+	for _, span := range spans {
+		if span.section == section && span.key == key {
+			out = append(out, span)
+		}
+	}
+	return out
+}
+
+// setValue updates the value of a key, keys that are not
+// in the section are added after its last key.
+func (e *envFileEditor) setValue(section, key, value string) error {
+	quoted, err := quoteINI(value)
+	if err != nil {
+		return fmt.Errorf("set %s: %w", key, err)
+	}
+
+	spans := e.keySpans(section, key)
+	if len(spans) == 0 {
+		_, ends := e.scan()
+		e.insert(ends[section], []string{key + "=" + quoted})
+		return nil
+	}
+
+	//replace from the end so line numbers stay valid
+	for _, span := range slices.Backward(spans) {
+		e.splice(span.start, span.end, []string{span.prefix + quoted})
+	}
+	return nil
+}
+
+// checkValue makes sure ini reads back value for key
+// in section from the edited lines.
+func (e *envFileEditor) checkValue(file *ini.File, section, key, value string) error {
+	sec := findSection(file, section)
+	if sec == nil || !sec.HasKey(key) {
+		return fmt.Errorf("set %s: key not found in section [%s] after update", key, section)
+	}
+	if v := sec.Key(key).String(); v != value {
+		return fmt.Errorf("set %s: value would be read back as %q", key, v)
+	}
+	return nil
+}
+
+// replaceKey replaces a key and its comments with lines
+func (e *envFileEditor) replaceKey(section, key string, lines []string) {
+	for _, span := range slices.Backward(e.keySpans(section, key)) {
+		e.splice(span.comments, span.end, lines)
+		lines = nil
+	}
+}
+
+// deleteKey removes a key and its comments
+func (e *envFileEditor) deleteKey(section, key string) {
+	e.replaceKey(section, key, nil)
+}
+
+func (e *envFileEditor) insert(i int, lines []string) {
+	//make sure the previous line ends with a line break
+	if i > 0 && !strings.HasSuffix(e.lines[i-1], "\n") {
+		e.lines[i-1] += "\n"
+	}
+	e.splice(i, i, lines)
+}
+
+func (e *envFileEditor) splice(start, end int, lines []string) {
+	add := make([]string, len(lines))
+	for i, line := range lines {
+		add[i] = strings.TrimRight(line, "\r\n") + "\n"
+	}
+	//keep the line ending of the replaced line
+	if end > start && len(add) > 0 && !strings.HasSuffix(e.lines[end-1], "\n") {
+		add[len(add)-1] = strings.TrimSuffix(add[len(add)-1], "\n")
+	}
+	e.lines = slices.Replace(e.lines, start, end, add...)
+}
+
+// parse parses the edited lines the way we load the env file
+func (e *envFileEditor) parse() (*ini.File, error) {
+	file, err := ini.LoadSources(ini.LoadOptions{UnparseableSections: e.commentSections}, []byte(strings.Join(e.lines, "")))
+	if err != nil {
+		return nil, fmt.Errorf("updated env file does not load: %w", err)
+	}
+	return file, nil
+}
+
+// save writes the file to a temporary file next to it and renames
+// it, so the env file is never left half written. The file is not
+// written if the edited lines do not load.
+func (e *envFileEditor) save() error {
+	if _, err := e.parse(); err != nil {
+		return fmt.Errorf("save %s: %w", e.filename, err)
+	}
+
+	//replace the file a symlink points to, not the symlink
+	filename, err := filepath.EvalSymlinks(e.filename)
+	if err != nil {
+		return fmt.Errorf("save %s: %w", e.filename, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("save %s: %w", e.filename, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strings.Join(e.lines, "")); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save %s: %w", e.filename, err)
+	}
+	if err := tmp.Chmod(e.perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save %s: %w", e.filename, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("save %s: %w", e.filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("save %s: %w", e.filename, err)
+	}
+
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("save %s: %w", e.filename, err)
+	}
+	return nil
+}
+
+// quoteINI quotes values so ini reads them back as they are, values
+// with spaces or a trailing \ are quoted as well. Values ini can't
+// read back, e.g. with """ and a line break, are an error.
+func quoteINI(value string) (string, error) {
+	switch {
+	case strings.Contains(value, `"""`) && strings.ContainsAny(value, "\n`"):
+		return "", fmt.Errorf(`values with """ and a line break or backtick can't be written to an ini file`)
+	case strings.Contains(value, `"""`):
+		return "`" + value + "`", nil
+	case strings.ContainsAny(value, "\n`"):
+		return `"""` + value + `"""`, nil
+	case strings.ContainsAny(value, "#;"):
+		return "`" + value + "`", nil
+	case strings.ContainsAny(value, `"'`):
+		return "`" + value + "`", nil
+	case strings.ContainsAny(value, " \t"), strings.HasSuffix(value, `\`):
+		return `"` + value + `"`, nil
+	}
+	return value, nil
+}
+
+// findSection returns a section by name ignoring the section
+// it extends, e.g. staging matches [staging : production]
+func findSection(file *ini.File, name string) *ini.Section {
+	for _, sec := range file.Sections() {
+		if n, _ := sectionParent(sec.Name()); n == name {
+			return sec
+		}
+	}
+	return nil
+}
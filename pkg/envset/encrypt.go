@@ -0,0 +1,329 @@
+package envset
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// EncryptOptions configures EncryptFile and DecryptFile
+type EncryptOptions struct {
+	Filename string
+	Format   string
+	Section  string
+	// Keys limits the keys we encrypt or decrypt, all keys
+	// of the section are used if empty. For EncryptFile keys
+	// can be KEY=value to add a new encrypted value.
+	Keys []string
+	// WholeSection encrypts all keys of the section into a
+	// single @encrypted key.
+	WholeSection bool
+	// CommentSectionNames are kept as they are
+	CommentSectionNames []string
+}
+
+// EncryptFile encrypts values of a section of an ini env file in
+// place and returns how many values were encrypted. Values that
+// are already encrypted are left as is.
+func EncryptFile(c *Cipher, o EncryptOptions) (int, error) {
+	e, err := loadEnvFileEditor(o.Filename, o.Format, o.CommentSectionNames)
+	if err != nil {
+		return 0, err
+	}
+
+	sec, err := e.section(o.Section)
+	if err != nil {
+		return 0, err
+	}
+	//values are bound to the section name without its parent
+	o.Section, _ = sectionParent(sec.Name())
+
+	var count int
+	if o.WholeSection {
+		count, err = encryptSection(c, e, sec, o)
+	} else {
+		count, err = encryptValues(c, e, sec, o)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return count, e.save()
+}
+
+// DecryptFile decrypts values of a section of an ini env file in
+// place and returns how many values were decrypted. An encrypted
+// section is replaced by its keys unless we only decrypt some keys.
+func DecryptFile(c *Cipher, o EncryptOptions) (int, error) {
+	e, err := loadEnvFileEditor(o.Filename, o.Format, o.CommentSectionNames)
+	if err != nil {
+		return 0, err
+	}
+
+	sec, err := e.section(o.Section)
+	if err != nil {
+		return 0, err
+	}
+	//values are bound to the section name without its parent
+	o.Section, _ = sectionParent(sec.Name())
+
+	if err := checkKeys(sec, o.Keys); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, key := range sec.Keys() {
+		if !IsEncrypted(key.Value()) {
+			continue
+		}
+		if len(o.Keys) > 0 && !slices.Contains(o.Keys, key.Name()) {
+			continue
+		}
+
+		plain, err := c.Decrypt(o.Section, key.Name(), key.Value())
+		if err != nil {
+			return 0, fmt.Errorf("decrypt [%s] %s: %w", o.Section, key.Name(), err)
+		}
+
+		if key.Name() != EncryptedKey {
+			if err := e.setValue(o.Section, key.Name(), plain); err != nil {
+				return 0, err
+			}
+			count++
+			continue
+		}
+
+		keys, err := parseSectionBody(plain)
+		if err != nil {
+			return 0, fmt.Errorf("decrypt [%s] %s: %w", o.Section, key.Name(), err)
+		}
+		for _, k := range keys {
+			if sec.HasKey(k.Name()) {
+				return 0, fmt.Errorf("key %s is defined in plain text and encrypted in section [%s]", k.Name(), o.Section)
+			}
+		}
+		e.replaceKey(o.Section, EncryptedKey, strings.Split(strings.TrimSpace(plain), "\n"))
+		count += len(keys)
+	}
+
+	return count, e.save()
+}
+
+func encryptValues(c *Cipher, e *envFileEditor, sec *ini.Section, o EncryptOptions) (int, error) {
+	count := 0
+	names := []string{}
+	assigned := map[string]bool{}
+	for _, k := range o.Keys {
+		name, value, ok := strings.Cut(k, "=")
+		if !ok {
+			names = append(names, name)
+			continue
+		}
+
+		//new values are added encrypted so they are never in the file
+		token, err := c.Encrypt(o.Section, name, value)
+		if err != nil {
+			return 0, fmt.Errorf("encrypt %s: %w", name, err)
+		}
+		if err := e.setValue(o.Section, name, token); err != nil {
+			return 0, err
+		}
+		assigned[name] = true
+		count++
+	}
+
+	if err := checkKeys(sec, names); err != nil {
+		return 0, err
+	}
+
+	if len(names) == 0 && len(assigned) > 0 {
+		return count, nil
+	}
+
+	for _, key := range sec.Keys() {
+		if isSpecialKey(key.Name()) || IsEncrypted(key.Value()) || assigned[key.Name()] {
+			continue
+		}
+		if len(names) > 0 && !slices.Contains(names, key.Name()) {
+			continue
+		}
+
+		token, err := c.Encrypt(o.Section, key.Name(), key.Value())
+		if err != nil {
+			return 0, fmt.Errorf("encrypt %s: %w", key.Name(), err)
+		}
+		if err := e.setValue(o.Section, key.Name(), token); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// encryptSection replaces all keys of a section with an encrypted
+// @encrypted key, merging keys that were already encrypted.
+func encryptSection(c *Cipher, e *envFileEditor, sec *ini.Section, o EncryptOptions) (int, error) {
+	if len(o.Keys) > 0 {
+		return 0, fmt.Errorf("can't encrypt a whole section and a list of keys")
+	}
+
+	plain := ini.Empty().Section(DefaultSection)
+	for _, key := range sec.Keys() {
+		if key.Name() == ExtendsKey {
+			continue
+		}
+
+		keys := []*ini.Key{key}
+		if IsEncrypted(key.Value()) {
+			value, err := c.Decrypt(o.Section, key.Name(), key.Value())
+			if err != nil {
+				return 0, fmt.Errorf("decrypt [%s] %s: %w", o.Section, key.Name(), err)
+			}
+
+			if keys, err = decryptedKeys(key, value); err != nil {
+				return 0, fmt.Errorf("decrypt [%s] %s: %w", o.Section, key.Name(), err)
+			}
+		}
+
+		for _, key := range keys {
+			k, err := plain.NewKey(key.Name(), key.Value())
+			if err != nil {
+				return 0, fmt.Errorf("new key %s: %w", key.Name(), err)
+			}
+			k.Comment = key.Comment
+		}
+	}
+
+	keys := plain.Keys()
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	body, err := sectionBody(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	token, err := c.Encrypt(o.Section, EncryptedKey, body)
+	if err != nil {
+		return 0, fmt.Errorf("encrypt section %s: %w", o.Section, err)
+	}
+
+	//the first key is replaced by the encrypted section
+	line := []string{EncryptedKey + "=" + token}
+	for _, name := range sec.KeyStrings() {
+		if name == ExtendsKey {
+			continue
+		}
+		e.replaceKey(o.Section, name, line)
+		line = nil
+	}
+
+	return len(keys), nil
+}
+
+// decryptedKeys returns the keys of a decrypted @encrypted key or
+// the key itself with the decrypted value.
+func decryptedKeys(key *ini.Key, value string) ([]*ini.Key, error) {
+	if key.Name() == EncryptedKey {
+		return parseSectionBody(value)
+	}
+
+	sec := ini.Empty().Section(DefaultSection)
+	k, err := sec.NewKey(key.Name(), value)
+	if err != nil {
+		return nil, fmt.Errorf("new key %s: %w", key.Name(), err)
+	}
+	k.Comment = key.Comment
+	return []*ini.Key{k}, nil
+}
+
+func checkKeys(sec *ini.Section, keys []string) error {
+	missing := []string{}
+	for _, k := range keys {
+		if !sec.HasKey(k) {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("keys not found in section [%s]: %s", sec.Name(), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func isSpecialKey(name string) bool {
+	return name == ExtendsKey || name == EncryptedKey
+}
+
+// RekeyOptions configures RekeyFile
+type RekeyOptions struct {
+	Filename string
+	Format   string
+	// CommentSectionNames are kept as they are
+	CommentSectionNames []string
+}
+
+// RekeyResult is the number of values rotated in a section
+type RekeyResult struct {
+	Section string
+	Count   int
+}
+
+// RekeyFile encrypts every encrypted value of an ini env file with
+// a new key in place. Values of encrypted sections are counted as
+// rotated values. Nothing is written if a value can't be decrypted
+// with the old key.
+func RekeyFile(oldCipher, newCipher *Cipher, o RekeyOptions) ([]RekeyResult, error) {
+	e, err := loadEnvFileEditor(o.Filename, o.Format, o.CommentSectionNames)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []RekeyResult{}
+	for _, sec := range e.file.Sections() {
+		if sec.Body() != "" {
+			continue
+		}
+
+		name, _ := sectionParent(sec.Name())
+		result := RekeyResult{Section: name}
+
+		for _, key := range sec.Keys() {
+			if !IsEncrypted(key.Value()) {
+				continue
+			}
+
+			plain, err := oldCipher.Decrypt(name, key.Name(), key.Value())
+			if err != nil {
+				return nil, fmt.Errorf("decrypt [%s] %s: %w", name, key.Name(), err)
+			}
+
+			token, err := newCipher.Encrypt(name, key.Name(), plain)
+			if err != nil {
+				return nil, fmt.Errorf("encrypt [%s] %s: %w", name, key.Name(), err)
+			}
+			if err := e.setValue(name, key.Name(), token); err != nil {
+				return nil, err
+			}
+
+			if key.Name() != EncryptedKey {
+				result.Count++
+				continue
+			}
+
+			keys, err := parseSectionBody(plain)
+			if err != nil {
+				return nil, fmt.Errorf("decrypt [%s] %s: %w", name, key.Name(), err)
+			}
+			result.Count += len(keys)
+		}
+
+		if result.Count > 0 {
+			results = append(results, result)
+		}
+	}
+
+	return results, e.save()
+}
@@ -0,0 +1,62 @@
+package envset
+
+import "fmt"
+
+// Environment is a section of the env file
+type Environment struct {
+	Name string
+	// Keys is the number of keys of the environment, including
+	// the keys it inherits. Keys of encrypted sections are not
+	// counted since we don't decrypt them.
+	Keys int
+}
+
+// ListEnvironments returns the sections of the env file and its
+// overlays in the order they are defined, without the DEFAULT
+// and comment sections.
+func ListEnvironments(options RunOptions) ([]Environment, error) {
+	env, _, err := mergeEnvLayers(DefaultSection, options)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err = InheritSections(env, true)
+	if err != nil {
+		return nil, fmt.Errorf("inherit sections: %w", err)
+	}
+
+	environments := []Environment{}
+	for _, sec := range env.Sections() {
+		if sec.Name() == DefaultSection || sec.Body() != "" {
+			continue
+		}
+
+		keys := 0
+		for _, key := range sec.KeyStrings() {
+			if !isSpecialKey(key) {
+				keys++
+			}
+		}
+		environments = append(environments, Environment{Name: sec.Name(), Keys: keys})
+	}
+	return environments, nil
+}
+
+// EnvironmentNames returns the names of the environments of the
+// env file. If the file has no sections we return DEFAULT.
+func EnvironmentNames(options RunOptions) ([]string, error) {
+	environments, err := ListEnvironments(options)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, env := range environments {
+		names = append(names, env.Name)
+	}
+
+	if len(names) == 0 {
+		names = append(names, DefaultSection)
+	}
+	return names, nil
+}
@@ -0,0 +1,44 @@
+package envset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_ListEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".envset")
+	contents := "GLOBAL=1\n\n[development]\nA=1\nB=2\n\n[qa : development]\nC=3\n\n[COMMENTS]\nsome notes\n\n[production]\n@encrypted=enc:v1:abc\n"
+	if err := os.WriteFile(base, []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if err := os.WriteFile(base+".local", []byte("[sandbox]\nD=4\n"), 0644); err != nil {
+		t.Fatalf("write local file: %v", err)
+	}
+
+	got, err := ListEnvironments(RunOptions{
+		Filename:            base,
+		LocalOverlays:       true,
+		CommentSectionNames: []string{"COMMENTS"},
+	})
+	if err != nil {
+		t.Fatalf("list environments: %v", err)
+	}
+
+	want := []Environment{
+		{Name: "development", Keys: 2},
+		{Name: "qa", Keys: 3},
+		{Name: "production", Keys: 0},
+		{Name: "sandbox", Keys: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	names, err := EnvironmentNames(RunOptions{Filename: writeSecretsFile(t, "A=1\n")})
+	if err != nil || !reflect.DeepEqual(names, []string{DefaultSection}) {
+		t.Fatalf("unexpected environment names %v %v", names, err)
+	}
+}
@@ -1,7 +1,9 @@
 package envset
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -55,7 +57,7 @@ func LoadIniSection(sec *ini.Section) EnvMap {
 	return env
 }
 
-// Expand ${VAR} and $(command) in values
+// Expand ${VAR}, $(command) and ref+<scheme>:// secret references in values
 func (e EnvMap) Expand(osExpand bool) error {
 	resolver := newEnvResolver(e, osExpand)
 	for _, k := range sortedEnvKeys(e) {
@@ -117,43 +119,16 @@ func InterpolateKVStrings(args []string, context EnvMap, expand bool) []string {
 
 func interpolateKVStrings(args []string, context EnvMap, expand bool) ([]string, error) {
 	for i, arg := range args {
-		//we use custom interpolation string for variables we load
-		interpolated, err := interpolateVars(arg, context)
+		//variables we load take precedence over OS variables
+		interpolated, err := interpolateVars(arg, context, expand)
 		if err != nil {
 			return args, err
 		}
 		args[i] = interpolated
-
-		//try using built in OS variables
-		if expand {
-			args[i] = expandBracedEnv(args[i])
-		}
 	}
 	return args, nil
 }
 
-func expandBracedEnv(str string) string {
-	var out strings.Builder
-	for i := 0; i < len(str); {
-		if i+2 >= len(str) || str[i] != '$' || str[i+1] != '{' {
-			out.WriteByte(str[i])
-			i++
-			continue
-		}
-
-		end := strings.IndexByte(str[i+2:], '}')
-		if end == -1 {
-			out.WriteString(str[i:])
-			break
-		}
-
-		key := str[i+2 : i+2+end]
-		out.WriteString(os.Getenv(key))
-		i = i + 2 + end + 1
-	}
-	return out.String()
-}
-
 type envResolver struct {
 	source    EnvMap
 	resolved  EnvMap
@@ -187,17 +162,40 @@ func (r *envResolver) resolveKey(key string) (string, error) {
 	r.resolving[key] = true
 	defer delete(r.resolving, key)
 
-	res, err := interpolateVarsWithResolver(raw, func(ref string) (string, bool, error) {
+	x := expander{lookupEnv: r.osExpand, unbraced: r.osExpand}
+	x.resolve = func(ref string) (string, bool, error) {
+		//a key that references itself, e.g. PORT=${PORT:-3000},
+		//refers to the value from the OS environment
+		if ref == key {
+			return "", false, nil
+		}
 		if _, ok := r.source[ref]; !ok {
 			return "", false, nil
 		}
 		val, err := r.resolveKey(ref)
 		return val, true, err
-	})
+	}
+
+	res, err := x.expand(raw)
 	if err != nil {
+		var perr *ErrorParameterNotSet
+		if errors.As(err, &perr) && perr.Key == "" {
+			perr.Key = key
+		}
 		return "", fmt.Errorf("interpolate vars for %s: %w", key, err)
 	}
 
+	//ref+<scheme>:// values are resolved by a SecretResolver and
+	//used as they are, without command or OS expansion
+	if ref, ok := ParseSecretRef(res); ok {
+		val, err := ResolveSecretRef(context.Background(), ref)
+		if err != nil {
+			return "", fmt.Errorf("secret for %s: %w", key, err)
+		}
+		r.resolved[key] = val
+		return val, nil
+	}
+
 	if hasCommandSubstitution(res) {
 		cmdVars, err := r.commandEnv(key)
 		if err != nil {
@@ -209,10 +207,7 @@ func (r *envResolver) resolveKey(key string) (string, error) {
 		}
 	}
 
-	if r.osExpand {
-		res = os.ExpandEnv(res)
-	}
-
+	res = unescapeDollar(res)
 	r.resolved[key] = res
 	return res, nil
 }
@@ -249,12 +244,19 @@ func sortedEnvKeys(env EnvMap) []string {
 }
 
 func hasCommandSubstitution(str string) bool {
-	return strings.Contains(str, "$(")
+	return strings.Contains(strings.ReplaceAll(str, `\$`, ""), "$(")
 }
 
 func interpolateCmds(str string, vars map[string]string) (string, error) {
 	var out strings.Builder
 	for i := 0; i < len(str); {
+		//\$( is not a command, keep it for unescapeDollar
+		if str[i] == '\\' && i+1 < len(str) && str[i+1] == '$' {
+			out.WriteString(str[i : i+2])
+			i += 2
+			continue
+		}
+
 		if i+1 >= len(str) || str[i] != '$' || str[i+1] != '(' {
 			out.WriteByte(str[i])
 			i++
@@ -346,41 +348,17 @@ func runShellCommand(command string, vars map[string]string) (string, error) {
 	return string(res), nil
 }
 
-func interpolateVars(str string, vars map[string]string) (string, error) {
+func interpolateVars(str string, vars map[string]string, lookupEnv bool) (string, error) {
 	return interpolateVarsWithResolver(str, func(key string) (string, bool, error) {
 		val, ok := vars[key]
 		return val, ok, nil
-	})
+	}, lookupEnv)
 }
 
-func interpolateVarsWithResolver(str string, resolve func(string) (string, bool, error)) (string, error) {
-	var out strings.Builder
-	for i := 0; i < len(str); {
-		if i+2 >= len(str) || str[i] != '$' || str[i+1] != '{' {
-			out.WriteByte(str[i])
-			i++
-			continue
-		}
-
-		end := strings.IndexByte(str[i+2:], '}')
-		if end == -1 {
-			out.WriteString(str[i:])
-			break
-		}
-
-		key := str[i+2 : i+2+end]
-		val, ok, err := resolve(key)
-		if err != nil {
-			return "", err
-		}
-		if ok {
-			out.WriteString(val)
-		} else {
-			out.WriteString(str[i : i+2+end+1])
-		}
-		i = i + 2 + end + 1
-	}
-	return out.String(), nil
+// interpolateVarsWithResolver expands ${VAR} parameters in str, see
+// expander for the supported operators.
+func interpolateVarsWithResolver(str string, resolve varResolver, lookupEnv bool) (string, error) {
+	return expander{resolve: resolve, lookupEnv: lookupEnv}.expand(str)
 }
 
 /////
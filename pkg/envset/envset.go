@@ -6,7 +6,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"gopkg.in/ini.v1"
 )
@@ -17,6 +20,7 @@ var DefaultSection = ini.DefaultSection
 // RunOptions is used to configure a run command
 type RunOptions struct {
 	Filename            string
+	Format              string
 	Cmd                 string
 	Args                []string
 	Isolated            bool
@@ -28,94 +32,261 @@ type RunOptions struct {
 	CommentSectionNames []string
 	Restart             bool
 	MaxRestarts         int
+	RestartPolicy       RestartPolicy
+	ProcessGroup        bool
+	// StopTimeout is how long we wait for the command to exit
+	// after sending SIGTERM before we kill it.
+	StopTimeout time.Duration
+	// Watch restarts the command when the environment changes.
+	Watch bool
+	// WatchFiles are extra files that trigger a reload, e.g. .envsetrc
+	WatchFiles []string
+	// Reload builds the options again when one of the WatchFiles
+	// changes, e.g. to load .envsetrc. If nil options don't change.
+	Reload func() (RunOptions, error)
+	// WatchInterval is how often we check watched files for changes.
+	WatchInterval time.Duration
+	// Stop stops the command and Run when closed, same as
+	// sending envset a SIGTERM.
+	Stop <-chan struct{}
+	// Overlays are env files merged over Filename in order, keys
+	// in later files take precedence.
+	Overlays []string
+	// LocalOverlays merges .envset.local and .envset.<env>.local
+	// files found next to Filename if they exist.
+	LocalOverlays bool
+	// ShowSources prints the file each key was loaded from
+	// instead of the environment.
+	ShowSources bool
+	// PrintFormat is the output format used by Print, e.g. bash
+	// or json. See OutputFormats.
+	PrintFormat string
+	// KeyFile is the file with the key used to decrypt
+	// encrypted values, see ENVSET_KEY_FILE.
+	KeyFile string
+	// Passphrase is used instead of KeyFile if set.
+	Passphrase string
+	// SecretKeys are name patterns of keys with secret values,
+	// e.g. *_TOKEN. See IsSecretKey.
+	SecretKeys []string
+	// Reveal prints secret values instead of redacting them.
+	Reveal bool
+	// MaskOutput replaces secret values in the command stdout
+	// and stderr with RedactedValue.
+	MaskOutput bool
+	// Schema is the file with the schema used to validate the
+	// environment before we run or print it, see LoadSchema.
+	Schema string
 }
 
-// Run will run the given command after loading the environment
+// Run will run the given command after loading the environment.
+// If the command fails it will be restarted following the options
+// restart policy. In watch mode a command that exits is started
+// again once the environment changes.
 func Run(environment string, options RunOptions) error {
 	restarts := 0
 	for {
-		err := doRun(environment, options)
-		if err == nil {
+		started := time.Now()
+		context, err := doRun(environment, options)
+
+		if errors.Is(err, errEnvironmentChanged) {
+			fmt.Fprintln(os.Stderr, "envset: environment changed, restarting command")
+			if options, err = options.reload(); err != nil {
+				fmt.Fprintf(os.Stderr, "envset: reload options: %s\n", err)
+			}
+			continue
+		}
+
+		if errors.Is(err, errCommandInterrupted) {
 			return nil
 		}
 
-		if !options.Restart || restarts >= options.MaxRestarts {
+		if err != nil {
+			if options.RestartPolicy.healthy(time.Since(started)) {
+				restarts = 0
+			}
+
+			if options.shouldRestart(err, restarts) {
+				restarts++
+				if !waitRestart(options.RestartPolicy.Backoff(restarts), options.Stop) {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !options.Watch || !commandExited(err) {
 			return err
 		}
-		restarts++
+
+		fmt.Fprintln(os.Stderr, "envset: command exited, waiting for the environment to change")
+		if !waitEnvironmentChange(environment, options, context) {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "envset: environment changed, starting command")
+		if options, err = options.reload(); err != nil {
+			fmt.Fprintf(os.Stderr, "envset: reload options: %s\n", err)
+		}
+		restarts = 0
 	}
 }
 
-func doRun(environment string, options RunOptions) error {
-	env, err := getEnvFile(options)
-	if err != nil {
-		return err
+// reload returns the options built by Reload, or the same options
+// if there is no Reload or it fails. Stop is kept as is since it
+// does not come from the configuration.
+func (o RunOptions) reload() (RunOptions, error) {
+	if o.Reload == nil {
+		return o, nil
 	}
 
-	context, err := getContext(environment, env, options)
+	options, err := o.Reload()
 	if err != nil {
-		return err
+		return o, err
 	}
+	options.Stop = o.Stop
+	return options, nil
+}
 
-	//Replace ${VAR} and $(command) in values
-	err = context.Expand(options.Expand)
+// doRun runs the command once and returns the environment
+// it was started with.
+func doRun(environment string, options RunOptions) (EnvMap, error) {
+	context, secrets, err := loadEnvironmentSecrets(environment, options)
 	if err != nil {
-		return fmt.Errorf("context expand: %w", err)
+		return nil, err
 	}
 
-	//Once we have resolved all ${VAR}/$(command) we build cmd.Env value
-	vars := context.ToKVStrings()
-
 	//Replace '${VAR}' in the executable cmd arguments
 	//note that if these are not in single quotes they will
 	//be resolved by the shell when we call envset and we will
 	//read the the result of that replacement, even if is empty.
-	_, err = interpolateKVStrings(options.Args, context, options.Expand)
+	//We work on a copy so that restarts see the original arguments.
+	args, err := interpolateKVStrings(slices.Clone(options.Args), context, options.Expand)
+	if err != nil {
+		return nil, fmt.Errorf("interpolate command args: %w", err)
+	}
+
+	command := exec.Command(options.Cmd, args...) // #nosec G204 -- envset intentionally runs the user-provided command.
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	command.Env = commandEnv(context, options)
+
+	//The command writes to pipes we scan for secret values
+	if options.MaskOutput {
+		values := secretValues(context, secrets)
+		stdout := newMaskWriter(os.Stdout, values)
+		stderr := newMaskWriter(os.Stderr, values)
+		defer stdout.Flush()
+		defer stderr.Flush()
+		command.Stdout = stdout
+		command.Stderr = stderr
+	}
+
+	//If we are watching the env file we get notified when
+	//the environment changes so we can restart the command.
+	var changed <-chan struct{}
+	if options.Watch {
+		w := newEnvWatcher(environment, options, context)
+		changed = w.start()
+		defer w.stop()
+	}
+
+	//We want to Start and watch for errors. If it crashes we
+	//might want to restart. Signals we get are relayed to the command.
+	return context, runCommand(command, options, changed)
+}
+
+// loadEnvironment loads the env file and returns the expanded
+// environment section, ensuring required keys are present.
+func loadEnvironment(environment string, options RunOptions) (EnvMap, error) {
+	context, _, err := loadEnvironmentSecrets(environment, options)
+	return context, err
+}
+
+// loadEnvironmentSecrets loads the environment like loadEnvironment
+// and returns the keys with secret values.
+func loadEnvironmentSecrets(environment string, options RunOptions) (EnvMap, map[string]bool, error) {
+	schema, err := loadRunSchema(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env, err := getEnvFile(environment, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	context, err := getContext(environment, env, options)
 	if err != nil {
-		return fmt.Errorf("interpolate command args: %w", err)
+		return nil, nil, err
 	}
+	schema.ApplyDefaults(environment, context)
+
+	//Replace ${VAR} and $(command) in values
+	err = context.Expand(options.Expand)
+	if err != nil {
+		return nil, nil, expandError(err, environment)
+	}
+
+	//secret values are masked by their expanded value
+	secrets := secretKeys(env.Section(environment), context, options.SecretKeys)
 
 	//If we want to check for required variables do it now.
 	missing := context.GetMissingKeys(options.Required)
 	if len(missing) > 0 {
-		return fmt.Errorf("missing required keys: %s", strings.Join(missing, ","))
+		return nil, nil, fmt.Errorf("missing required keys: %s", strings.Join(missing, ","))
 	}
 
-	command := exec.Command(options.Cmd, options.Args...) // #nosec G204 -- envset intentionally runs the user-provided command.
-	command.Stdout = os.Stdout
-	command.Stderr = os.Stderr
+	if err := schema.Validate(environment, context).Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return context, secrets, nil
+}
 
+// commandEnv builds the environment for the executed command
+func commandEnv(context EnvMap, options RunOptions) []string {
 	//If we want to run in an isolated context we just use
 	//our variables from the loaded file
 	if options.Isolated {
-		command.Env = vars
+		env := context.ToKVStrings()
 		//add value for any inherited env vars we have in options
 		for _, k := range options.Inherit {
 			if v := os.Getenv(k); v != "" {
-				command.Env = append(command.Env, fmt.Sprintf("%s=%s", k, v))
+				env = append(env, fmt.Sprintf("%s=%s", k, v))
 			}
 		}
-	} else {
-		local := LocalEnv()
-		for k, v := range context {
-			//TODO: what do we get if we have unset variables
-			if _, ok := local[k]; !ok {
-				if err := os.Setenv(k, v); err != nil {
-					return fmt.Errorf("set environment variable %s: %w", k, err)
-				}
-			}
+		return env
+	}
+
+	//Variables defined in the shell take precedence
+	env := os.Environ()
+	local := LocalEnv()
+	for _, k := range sortedEnvKeys(context) {
+		//TODO: what do we get if we have unset variables
+		if _, ok := local[k]; !ok {
+			env = append(env, fmt.Sprintf("%s=%s", k, context[k]))
 		}
 	}
-	//We want to Start and watch for errors. If it crashes we
-	//might want to restart.
-	return command.Run()
+	return env
 }
 
 // Print will show the current environment
 // We don't need to do variable replacement if we print since
-// the idea is to use it as a source
+// the idea is to use it as a source. Secret values are redacted
+// in JSON output unless options.Reveal is set, the other formats
+// are sourced by shells and tools so we fail instead of exporting
+// redacted values.
 func Print(environment string, options RunOptions) error {
-	env, err := getEnvFile(options)
+	if options.ShowSources {
+		return printSources(environment, options)
+	}
+
+	schema, err := loadRunSchema(options)
+	if err != nil {
+		return err
+	}
+
+	env, err := getEnvFile(environment, options)
 	if err != nil {
 		return err
 	}
@@ -124,30 +295,67 @@ func Print(environment string, options RunOptions) error {
 	if err != nil {
 		return err
 	}
+	schema.ApplyDefaults(environment, context)
 
 	//Replace ${VAR} and $(command) in values
 	err = context.Expand(options.Expand)
 	if err != nil {
-		return fmt.Errorf("context expand: %w", err)
+		return expandError(err, environment)
+	}
+
+	if err := schema.Validate(environment, context).Err(); err != nil {
+		return err
 	}
 
 	//----- actual print action
 	if !options.Isolated {
-		for _, e := range os.Environ() {
-			fmt.Println(e)
+		//Variables defined in the shell take precedence, we skip
+		//names shells can't export e.g. BASH_FUNC_name%%
+		for k, v := range LocalEnv() {
+			if isVarName(k) {
+				context[k] = v
+			}
 		}
 	}
 
-	//vars := context.GetEnvSlice()
-	for k, v := range context {
-		//TODO: do proper scaping, here we want to check if its not already been "..."
-		if strings.Contains(v, " ") {
-			v = fmt.Sprintf("\"%s\"", v)
+	if !options.Reveal {
+		redacted := RedactEnv(context, secretKeys(env.Section(environment), context, options.SecretKeys))
+		if keys := redactedKeys(context, redacted); len(keys) > 0 && options.PrintFormat != OutputJSON {
+			return fmt.Errorf(
+				"environment %s has secret values in %s, use --reveal to export them or --format json to print them redacted",
+				environment,
+				strings.Join(keys, ", "),
+			)
 		}
-		fmt.Printf("%s=%s\n", k, v)
+		context = redacted
 	}
 
-	return nil
+	return WriteEnv(os.Stdout, context, options.PrintFormat)
+}
+
+// printSources shows the file each key of the environment
+// was loaded from, relative to the current directory.
+func printSources(environment string, options RunOptions) error {
+	sources, err := EnvSources(environment, options)
+	if err != nil {
+		return err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get wd: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSOURCE")
+	for _, key := range sortedEnvKeys(sources) {
+		source := sources[key]
+		if rel, err := filepath.Rel(wd, source); err == nil {
+			source = rel
+		}
+		fmt.Fprintf(w, "%s\t%s\n", key, source)
+	}
+	return w.Flush()
 }
 
 // FileFinder will find the file and return its full path
@@ -181,33 +389,27 @@ func getContext(environment string, env *ini.File, options RunOptions) (EnvMap,
 	}
 	//Build context object from section key/values
 	context := LoadIniSection(sec)
+
+	//Decrypt enc:v1: values before we expand them
+	if err := decryptContext(environment, context, &lazyCipher{options: options}); err != nil {
+		return EnvMap{}, err
+	}
 	return context, nil
 }
 
-func getEnvFile(options RunOptions) (*ini.File, error) {
-	filename, err := FileFinder(options.Filename) //TODO: This might be an issue here!
+// getEnvFile loads the env file merged with its overlays and
+// with section inheritance applied.
+func getEnvFile(environment string, options RunOptions) (*ini.File, error) {
+	env, _, err := loadEnvLayers(environment, options)
 	if err != nil {
-		return nil, fmt.Errorf("file finder %s: %w", options.Filename, err)
+		return nil, err
 	}
 
-	env, err := ini.LoadSources(ini.LoadOptions{
-		UnparseableSections:     options.CommentSectionNames,
-		SkipUnrecognizableLines: true,
-	}, filename)
-
+	env, err = InheritSections(env, true)
 	if err != nil {
-		if ini.IsErrDelimiterNotFound(err) {
-			fmt.Printf("The file \"%s\" has an error and we can't parse it.\n", options.Filename)
-			fmt.Println("It looks as if you forgot a variable name.")
-			var delErr ini.ErrDelimiterNotFound
-			if errors.As(err, &delErr) {
-				fmt.Printf("The offending line content: %s\n", delErr.Line)
-			}
-		}
-		//error parsing data source: unknown type
-		return nil, fmt.Errorf("file load: %w", err)
+		return nil, fmt.Errorf("inherit sections: %w", err)
 	}
-	return env, err
+	return env, nil
 }
 
 func getSec(environment string, env *ini.File, options RunOptions) (*ini.Section, error) {
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"syscall"
 	"testing"
 )
 
@@ -134,6 +136,71 @@ func Test_Run_MaxRestartsCountsRestartsPerCall(t *testing.T) {
 	assertFileSize(t, countFile, 4)
 }
 
+func Test_Run_PropagatesExitCode(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".envset")
+	if err := os.WriteFile(envFile, []byte("[development]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		script   string
+		wantCode int
+	}{
+		{name: "exit status", script: "exit 3", wantCode: 3},
+		{name: "terminated by signal", script: "kill -KILL $$", wantCode: 128 + int(syscall.SIGKILL)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Run("development", RunOptions{
+				Filename:      envFile,
+				Cmd:           "sh",
+				Args:          []string{"-c", tt.script},
+				Isolated:      true,
+				ExportEnvName: "APP_ENV",
+			})
+			code, ok := ExitCode(err)
+			if !ok {
+				t.Fatalf("err = %v, want ErrorCommandExit", err)
+			}
+			if code != tt.wantCode {
+				t.Fatalf("code = %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func Test_Run_ForwardsSignalsAndDoesNotRestart(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".envset")
+	if err := os.WriteFile(envFile, []byte("[development]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	countFile := filepath.Join(dir, "runs")
+	script := `printf x >> "$1"; trap 'exit 7' TERM; kill -TERM $PPID; while :; do sleep 0.1; done`
+
+	err := Run("development", RunOptions{
+		Filename:      envFile,
+		Cmd:           "sh",
+		Args:          []string{"-c", script, "sh", countFile},
+		Isolated:      true,
+		ExportEnvName: "APP_ENV",
+		Restart:       true,
+		MaxRestarts:   3,
+	})
+	code, ok := ExitCode(err)
+	if !ok {
+		t.Fatalf("err = %v, want ErrorCommandExit", err)
+	}
+	if code != 7 {
+		t.Fatalf("code = %d, want 7", code)
+	}
+	assertFileSize(t, countFile, 1)
+}
+
 func Test_EnvFileLoadPersistsState(t *testing.T) {
 	dir := t.TempDir()
 	envFile := filepath.Join(dir, ".envset")
@@ -236,7 +303,7 @@ func Test_CompareMetadataFiles(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			changed, err := CompareMetadataFiles(tt.source, tt.target)
+			diff, err := CompareMetadataFiles(tt.source, tt.target)
 			if tt.wantErr {
 				var wrongAlgorithm *ErrorWrongAlgorithm
 				if !errors.As(err, &wrongAlgorithm) {
@@ -247,13 +314,234 @@ func Test_CompareMetadataFiles(t *testing.T) {
 			if err != nil {
 				t.Fatalf("compare: %v", err)
 			}
-			if changed != tt.wantChanged {
+			if changed := !diff.IsEmpty(); changed != tt.wantChanged {
 				t.Fatalf("changed = %v, want %v", changed, tt.wantChanged)
 			}
 		})
 	}
 }
 
+func Test_CompareMetadataFilesDiff(t *testing.T) {
+	source := &EnvFile{
+		Algorithm: HashSHA256,
+		Filename:  ".envset",
+		Project:   "new-project",
+		Sections: []*EnvSection{
+			{Name: "development", Comment: "new comment", Keys: []*EnvKey{
+				{Name: "A", Hash: "1"},
+				{Name: "B", Hash: "3", Comment: "changed"},
+				{Name: "D", Hash: "4"},
+			}},
+			{Name: "qa", Keys: []*EnvKey{}},
+		},
+	}
+	target := &EnvFile{
+		Algorithm: HashSHA256,
+		Filename:  ".envset",
+		Project:   "old-project",
+		Sections: []*EnvSection{
+			{Name: "development", Keys: []*EnvKey{
+				{Name: "A", Hash: "1"},
+				{Name: "B", Hash: "2"},
+				{Name: "C", Hash: "3"},
+			}},
+			{Name: "production", Keys: []*EnvKey{}},
+		},
+	}
+
+	diff, err := CompareMetadataFiles(source, target)
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+
+	want := MetadataDiff{
+		Project:         &ValueChange{From: "old-project", To: "new-project"},
+		SectionsAdded:   []string{"qa"},
+		SectionsRemoved: []string{"production"},
+		Sections: []*SectionDiff{
+			{
+				Name:        "development",
+				Comment:     &ValueChange{From: "", To: "new comment"},
+				KeysAdded:   []string{"D"},
+				KeysRemoved: []string{"C"},
+				KeysChanged: []string{"B"},
+				KeyComments: []string{"B"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Fatalf("got %+v, want %+v", diff, want)
+	}
+
+	for _, k := range source.Sections[0].Keys {
+		if k.Name == "A" && k.Comment != "" {
+			t.Fatalf("expected source keys to not be modified, got comment %q", k.Comment)
+		}
+	}
+
+	report := diff.String()
+	for _, line := range []string{"+ [qa]", "- [production]", "~ [development]", "    + D", "    - C", "    ~ B value changed", "    ~ B comment changed"} {
+		if !strings.Contains(report, line) {
+			t.Fatalf("expected report %q to contain %q", report, line)
+		}
+	}
+}
+
+func Test_VerifyMetadataFile(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".envset")
+	metaFile := filepath.Join(dir, "data.json")
+
+	if err := os.WriteFile(envFile, []byte("[development]\nA=1\n\n[qa]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	o := MetadataOptions{Name: envFile, Filepath: metaFile, Algorithm: HashSHA256, Values: true}
+	stored, err := CreateMetadataFile(o)
+	if err != nil {
+		t.Fatalf("create metadata: %v", err)
+	}
+	//metadata created from another checkout or path is not drift
+	stored.Project = "git@example.com:other/project.git"
+	stored.Filename = "../other/.envset"
+	contents, err := stored.ToJSON()
+	if err != nil {
+		t.Fatalf("to json: %v", err)
+	}
+	if err := os.WriteFile(metaFile, []byte(contents), 0600); err != nil {
+		t.Fatalf("write metadata: %v", err)
+	}
+
+	diff, err := VerifyMetadataFile(o, "")
+	if err != nil || !diff.IsEmpty() {
+		t.Fatalf("expected no drift, got %+v %v", diff, err)
+	}
+
+	//comments are not compared
+	if err := os.WriteFile(envFile, []byte("# dev\n[development]\n# a key\nA=1\n\n[qa]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	diff, err = VerifyMetadataFile(o, "")
+	if err != nil || !diff.IsEmpty() {
+		t.Fatalf("expected comments to be ignored, got %+v %v", diff, err)
+	}
+
+	if err := os.WriteFile(envFile, []byte("[development]\nA=2\n\n[qa]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	diff, err = VerifyMetadataFile(o, "")
+	if err != nil || len(diff.Sections) != 1 || !reflect.DeepEqual(diff.Sections[0].KeysChanged, []string{"A"}) {
+		t.Fatalf("expected A to change, got %+v %v", diff, err)
+	}
+
+	diff, err = VerifyMetadataFile(o, "qa")
+	if err != nil || !diff.IsEmpty() {
+		t.Fatalf("expected qa to match, got %+v %v", diff, err)
+	}
+
+	if _, err := VerifyMetadataFile(o, "production"); err == nil {
+		t.Fatal("expected error for unknown section")
+	}
+}
+
+func Test_CompareSectionsIgnored(t *testing.T) {
+	s1 := EnvSection{Keys: []*EnvKey{
+		{Name: "SAME", Hash: "1"},
+		{Name: "DIFFERENT", Hash: "1"},
+		{Name: "EXTRA", Hash: "1"},
+		{Name: "CHANGED", Hash: "1"},
+	}}
+	s2 := EnvSection{Keys: []*EnvKey{
+		{Name: "SAME", Hash: "1"},
+		{Name: "DIFFERENT", Hash: "2"},
+		{Name: "MISSING", Hash: "1"},
+		{Name: "CHANGED", Hash: "2"},
+	}}
+
+	diff := CompareSections(s1, s2, []string{"SAME", "DIFFERENT", "EXTRA", "MISSING"})
+
+	got := map[string]string{}
+	for _, k := range diff.Ignored {
+		got[k.Name] = k.Comment
+	}
+	want := map[string]string{
+		"SAME":      SameValueComment,
+		"DIFFERENT": "different hash value",
+		"EXTRA":     "extra in source",
+		"MISSING":   "missing in source",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if len(diff.Keys) != 1 || diff.Keys[0].Name != "CHANGED" {
+		t.Fatalf("expected only CHANGED in diff, got %+v", diff.Keys)
+	}
+
+	j, err := diff.ToJSON()
+	if err != nil || !strings.Contains(j, `"ignored": [`) {
+		t.Fatalf("expected ignored keys in json %q %v", j, err)
+	}
+}
+
+func Test_CompareAllSections(t *testing.T) {
+	source := metadataFixture(HashSHA256, map[string]string{
+		"development": "abc",
+		"qa":          "abc",
+		"old":         "abc",
+	})
+	target := metadataFixture(HashSHA256, map[string]string{
+		"development": "def",
+		"qa":          "abc",
+		"new":         "abc",
+	})
+
+	res, err := CompareAllSections(source, target, func(section string) []string {
+		if section == "qa" {
+			return []string{"KEY", "UNDEFINED"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, s := range res.Sections {
+		got[s.Name] = s.Status
+	}
+	want := map[string]string{
+		"development": SectionDifferent,
+		"qa":          SectionOK,
+		"old":         SectionMissingInTarget,
+		"new":         SectionMissingInSource,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if res.IsEmpty() || res.Different() != 3 {
+		t.Fatalf("expected 3 different sections, got %d", res.Different())
+	}
+
+	for _, s := range res.Sections {
+		if s.Name == "development" && (s.Different != 1 || len(s.Keys) != 1) {
+			t.Fatalf("unexpected development comparison %+v", s)
+		}
+		if s.Name == "qa" && (s.Ignored != 1 || s.IgnoredKeys[0].Comment != SameValueComment) {
+			t.Fatalf("expected 1 ignored key in qa, got %d", s.Ignored)
+		}
+	}
+
+	_, err = CompareAllSections(metadataFixture(HashMD5, nil), target, nil)
+	var wrongAlgorithm *ErrorWrongAlgorithm
+	if !errors.As(err, &wrongAlgorithm) {
+		t.Fatalf("err = %v, want ErrorWrongAlgorithm", err)
+	}
+}
+
 func metadataFixture(algorithm string, sections map[string]string) *EnvFile {
 	return metadataFixtureWithProject(algorithm, "", sections)
 }
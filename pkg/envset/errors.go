@@ -1,6 +1,11 @@
 package envset
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
 
 type envFileErrorNotFound struct {
 	err error
@@ -57,3 +62,88 @@ type ErrorWrongAlgorithm struct {
 func (e *ErrorWrongAlgorithm) Error() string {
 	return fmt.Sprintf("wrong algorithm: source %s target %s", e.source, e.target)
 }
+
+// ErrorCommandExit is returned by Run when the executed command
+// exits with a non zero status or is terminated by a signal.
+type ErrorCommandExit struct {
+	// Code is the exit status of the command, or 128+signal
+	// if the command was terminated by a signal.
+	Code int
+	// Signal is the signal that terminated the command, if any.
+	Signal      os.Signal
+	interrupted bool
+	err         error
+}
+
+func (e ErrorCommandExit) Error() string {
+	return e.err.Error()
+}
+
+func (e ErrorCommandExit) Unwrap() error {
+	return e.err
+}
+
+// ExitCode returns the exit code of the executed command if err
+// was generated by the command exiting with an error.
+func ExitCode(err error) (int, bool) {
+	var exitErr ErrorCommandExit
+	if errors.As(err, &exitErr) {
+		return exitErr.Code, true
+	}
+	return 0, false
+}
+
+// ErrorSyntax is returned when an env file can't be parsed
+type ErrorSyntax struct {
+	Filename string
+	Line     int
+	Column   int
+	Msg      string
+}
+
+func (e *ErrorSyntax) Error() string {
+	if e.Filename == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.Filename, e.Line, e.Column, e.Msg)
+}
+
+// ErrorParameterNotSet is returned by ${VAR:?message} and
+// ${VAR?message} when VAR is not set.
+type ErrorParameterNotSet struct {
+	Section   string
+	Key       string
+	Parameter string
+	Msg       string
+}
+
+func (e *ErrorParameterNotSet) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "parameter null or not set"
+	}
+
+	out := fmt.Sprintf("%s: %s", e.Parameter, msg)
+	if e.Key != "" {
+		out = fmt.Sprintf("%s: %s", e.Key, out)
+	}
+	if e.Section != "" {
+		out = fmt.Sprintf("[%s] %s", e.Section, out)
+	}
+	return out
+}
+
+// ErrorValidation is returned when the values of an environment
+// don't match its schema.
+type ErrorValidation struct {
+	Environment string
+	Errors      []KeyValidation
+}
+
+func (e *ErrorValidation) Error() string {
+	errs := make([]string, len(e.Errors))
+	for i, res := range e.Errors {
+		errs[i] = fmt.Sprintf("%s: %s", res.Key.Name, res.Error)
+	}
+	return fmt.Sprintf("[%s] invalid environment: %s", e.Environment, strings.Join(errs, "; "))
+}
@@ -0,0 +1,398 @@
+package envset
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// varResolver returns the value of a variable and whether it is set
+type varResolver func(key string) (string, bool, error)
+
+// expander replaces ${VAR} parameters using POSIX shell semantics:
+//
+//	${VAR}             value of VAR
+//	${VAR:-word}       word if VAR is unset or empty
+//	${VAR-word}        word if VAR is unset
+//	${VAR:=word}       same as :- since we can't assign
+//	${VAR:?message}    error if VAR is unset or empty
+//	${VAR:+word}       word if VAR is set and not empty
+//	${#VAR}            length of VAR
+//	${VAR#pattern}     remove shortest matching prefix, ## for longest
+//	${VAR%pattern}     remove shortest matching suffix, %% for longest
+//	${VAR:offset:len}  substring of VAR
+//
+// Variables that can't be resolved are looked up in the OS
+// environment when lookupEnv is true, a bare ${VAR} that is not
+// set expands to an empty string. If lookupEnv is false a bare
+// ${VAR} is left as is and variables used with an operator are
+// considered unset. Any other expression is an error.
+//
+// If unbraced is true $VAR is expanded as well, a $VAR that is
+// not set is left as is so values like pa$word are kept.
+//
+// A \$ is a literal $, the escape is kept in the output so that
+// command substitution skips it, see unescapeDollar.
+type expander struct {
+	resolve   varResolver
+	lookupEnv bool
+	unbraced  bool
+}
+
+func (x expander) expand(str string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(str); {
+		if str[i] == '\\' && i+1 < len(str) && str[i+1] == '$' {
+			out.WriteString(str[i : i+2])
+			i += 2
+			continue
+		}
+
+		if x.unbraced && str[i] == '$' {
+			if name := leadingVarName(str[i+1:]); name != "" {
+				val, ok, err := x.lookup(name)
+				if err != nil {
+					return "", err
+				}
+				if ok {
+					out.WriteString(val)
+				} else {
+					out.WriteString(str[i : i+1+len(name)])
+				}
+				i += 1 + len(name)
+				continue
+			}
+		}
+
+		if i+2 >= len(str) || str[i] != '$' || str[i+1] != '{' {
+			out.WriteByte(str[i])
+			i++
+			continue
+		}
+
+		end := closingBrace(str, i+2)
+		if end == -1 {
+			out.WriteString(str[i:])
+			break
+		}
+
+		var val string
+		var ok bool
+		var err error
+
+		expr := str[i+2 : end]
+		if isVarName(expr) {
+			val, ok, err = x.lookup(expr)
+			ok = ok || x.lookupEnv
+		} else {
+			val, ok, err = x.parameter(expr)
+			if err == nil && !ok {
+				err = fmt.Errorf("unsupported parameter expansion %s", str[i:end+1])
+			}
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		if ok {
+			out.WriteString(val)
+		} else {
+			out.WriteString(str[i : end+1])
+		}
+
+		i = end + 1
+	}
+	return out.String(), nil
+}
+
+// closingBrace returns the index of the brace that closes the
+// parameter starting at i, nested parameters are skipped.
+func closingBrace(str string, i int) int {
+	depth := 0
+	for ; i < len(str); i++ {
+		switch {
+		case str[i] == '\\' && i+1 < len(str):
+			i++
+		case str[i] == '$' && i+1 < len(str) && str[i+1] == '{':
+			depth++
+			i++
+		case str[i] == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+func (x expander) lookup(name string) (string, bool, error) {
+	val, ok, err := x.resolve(name)
+	if err != nil || ok {
+		return val, ok, err
+	}
+
+	if x.lookupEnv {
+		val, ok = os.LookupEnv(name)
+		return val, ok, nil
+	}
+	return "", false, nil
+}
+
+// parameter expands an operator expression, returns false if
+// expr is not a parameter we know how to expand.
+func (x expander) parameter(expr string) (string, bool, error) {
+	//${#VAR}
+	if name, ok := strings.CutPrefix(expr, "#"); ok && isVarName(name) {
+		val, _, err := x.lookup(name)
+		if err != nil {
+			return "", false, err
+		}
+		return strconv.Itoa(utf8.RuneCountInString(val)), true, nil
+	}
+
+	name := leadingVarName(expr)
+	if name == "" {
+		return "", false, nil
+	}
+
+	val, set, err := x.lookup(name)
+	if err != nil {
+		return "", false, err
+	}
+
+	op, word := splitOperator(expr[len(name):])
+	switch op {
+	case ":-", ":=":
+		if !set || val == "" {
+			return x.word(word)
+		}
+		return val, true, nil
+	case "-", "=":
+		if !set {
+			return x.word(word)
+		}
+		return val, true, nil
+	case ":?", "?":
+		if !set || (op == ":?" && val == "") {
+			msg, _, err := x.word(word)
+			if err != nil {
+				return "", false, err
+			}
+			return "", false, &ErrorParameterNotSet{Parameter: name, Msg: msg}
+		}
+		return val, true, nil
+	case ":+":
+		if set && val != "" {
+			return x.word(word)
+		}
+		return "", true, nil
+	case "+":
+		if set {
+			return x.word(word)
+		}
+		return "", true, nil
+	case "#", "##", "%", "%%":
+		pattern, _, err := x.word(word)
+		if err != nil {
+			return "", false, err
+		}
+		return trimPattern(val, op, pattern), true, nil
+	case ":":
+		res, ok := substring(val, word)
+		return res, ok, nil
+	}
+
+	return "", false, nil
+}
+
+func (x expander) word(word string) (string, bool, error) {
+	res, err := x.expand(word)
+	if err != nil {
+		return "", false, err
+	}
+	return res, true, nil
+}
+
+func splitOperator(rest string) (string, string) {
+	for _, op := range []string{":-", ":=", ":?", ":+", "##", "%%", "-", "=", "?", "+", "#", "%", ":"} {
+		if word, ok := strings.CutPrefix(rest, op); ok {
+			return op, word
+		}
+	}
+	return rest, ""
+}
+
+// substring handles ${VAR:offset} and ${VAR:offset:length},
+// negative offsets count from the end of the value.
+func substring(val, spec string) (string, bool) {
+	offsetStr, lengthStr, hasLength := strings.Cut(spec, ":")
+
+	offset, err := strconv.Atoi(strings.TrimSpace(offsetStr))
+	if err != nil {
+		return "", false
+	}
+
+	runes := []rune(val)
+	if offset < 0 {
+		offset = max(len(runes)+offset, 0)
+	}
+	offset = min(offset, len(runes))
+
+	end := len(runes)
+	if hasLength {
+		length, err := strconv.Atoi(strings.TrimSpace(lengthStr))
+		if err != nil {
+			return "", false
+		}
+		if length < 0 {
+			end = max(len(runes)+length, offset)
+		} else {
+			end = min(offset+length, len(runes))
+		}
+	}
+
+	return string(runes[offset:end]), true
+}
+
+// trimPattern removes a prefix (#, ##) or suffix (%, %%)
+// matching a shell pattern, the doubled operators remove
+// the longest match.
+func trimPattern(val, op, pattern string) string {
+	longest := len(op) == 2
+
+	switch op[0] {
+	case '#':
+		for i := range len(val) + 1 {
+			n := i
+			if longest {
+				n = len(val) - i
+			}
+			if matchPattern(pattern, val[:n]) {
+				return val[n:]
+			}
+		}
+	case '%':
+		for i := range len(val) + 1 {
+			n := len(val) - i
+			if longest {
+				n = i
+			}
+			if matchPattern(pattern, val[n:]) {
+				return val[:n]
+			}
+		}
+	}
+	return val
+}
+
+// matchPattern reports whether str matches a shell pattern
+// with *, ? and [...] where * also matches /
+func matchPattern(pattern, str string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(str); i >= 0; i-- {
+				if matchPattern(pattern[1:], str[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if str == "" {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(str)
+			pattern, str = pattern[1:], str[size:]
+		case '[':
+			end := strings.IndexByte(pattern[1:], ']')
+			if end == -1 {
+				//no closing bracket, match [ literally
+				if str == "" || str[0] != '[' {
+					return false
+				}
+				pattern, str = pattern[1:], str[1:]
+				continue
+			}
+			if str == "" {
+				return false
+			}
+			ch, size := utf8.DecodeRuneInString(str)
+			if !matchClass(pattern[1:end+1], ch) {
+				return false
+			}
+			pattern, str = pattern[end+2:], str[size:]
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if str == "" || str[0] != pattern[0] {
+				return false
+			}
+			pattern, str = pattern[1:], str[1:]
+		}
+	}
+	return str == ""
+}
+
+func matchClass(class string, ch rune) bool {
+	negate := false
+	if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+		negate, class = true, class[1:]
+	}
+
+	runes := []rune(class)
+	matched := false
+	for i := 0; i < len(runes); i++ {
+		if i+2 < len(runes) && runes[i+1] == '-' {
+			if ch >= runes[i] && ch <= runes[i+2] {
+				matched = true
+			}
+			i += 2
+			continue
+		}
+		if ch == runes[i] {
+			matched = true
+		}
+	}
+	return matched != negate
+}
+
+// unescapeDollar replaces \$ with $ once a value is expanded
+func unescapeDollar(str string) string {
+	return strings.ReplaceAll(str, `\$`, "$")
+}
+
+func isVarName(str string) bool {
+	return str != "" && leadingVarName(str) == str
+}
+
+func leadingVarName(str string) string {
+	for i, ch := range str {
+		if ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (i > 0 && ch >= '0' && ch <= '9') {
+			continue
+		}
+		return str[:i]
+	}
+	return str
+}
+
+// expandError wraps errors from EnvMap.Expand. Parameter errors
+// are returned as is with the section name, since wrapped error
+// messages are built when wrapping and would not include it.
+func expandError(err error, section string) error {
+	var perr *ErrorParameterNotSet
+	if !errors.As(err, &perr) {
+		return fmt.Errorf("context expand: %w", err)
+	}
+	if perr.Section == "" {
+		perr.Section = section
+	}
+	return perr
+}
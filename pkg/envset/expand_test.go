@@ -0,0 +1,206 @@
+package envset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Expand_ParameterOperators(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"default unset", "${PORT:-3000}", "3000"},
+		{"default empty", "${EMPTY:-3000}", "3000"},
+		{"default set", "${HOST:-0.0.0.0}", "localhost"},
+		{"default unset only", "${EMPTY-3000}", ""},
+		{"default nested", "${PORT:-${HOST}:3000}", "localhost:3000"},
+		{"assign default", "${PORT:=3000}", "3000"},
+		{"alternative set", "${DEBUG:+--verbose}", "--verbose"},
+		{"alternative empty", "${EMPTY:+--verbose}", ""},
+		{"alternative unset", "${PORT:+--verbose}", ""},
+		{"alternative empty set", "${EMPTY+set}", "set"},
+		{"length", "${#HOST}", "9"},
+		{"length unset", "${#PORT}", "0"},
+		{"shortest prefix", "${URL#*/}", "/envset.sh/docs/index.html"},
+		{"longest prefix", "${URL##*/}", "index.html"},
+		{"shortest suffix", "${URL%/*}", "https://envset.sh/docs"},
+		{"longest suffix", "${URL%%/*}", "https:"},
+		{"suffix class", "${FILE%.[a-z]*}", "archive.tar"},
+		{"no match", "${HOST#www.}", "localhost"},
+		{"substring", "${HOST:0:5}", "local"},
+		{"substring offset", "${HOST:5}", "host"},
+		{"substring negative", "${HOST: -4}", "host"},
+		{"unknown bare", "${UNKNOWN}", "${UNKNOWN}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := EnvMap{
+				"HOST":  "localhost",
+				"DEBUG": "true",
+				"EMPTY": "",
+				"URL":   "https://envset.sh/docs/index.html",
+				"FILE":  "archive.tar.gz",
+				"OUT":   tt.value,
+			}
+
+			if err := env.Expand(false); err != nil {
+				t.Fatalf("expand: %v", err)
+			}
+
+			if got := env["OUT"]; got != tt.want {
+				t.Fatalf("%s = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Expand_ParameterOperatorsUseOSEnv(t *testing.T) {
+	t.Setenv("ENVSET_TEST_PORT", "8080")
+
+	env := EnvMap{"PORT": "${ENVSET_TEST_PORT:-3000}"}
+	if err := env.Expand(true); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	if env["PORT"] != "8080" {
+		t.Fatalf("PORT = %q, want %q", env["PORT"], "8080")
+	}
+
+	env = EnvMap{"PORT": "${ENVSET_TEST_PORT:-3000}"}
+	if err := env.Expand(false); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	if env["PORT"] != "3000" {
+		t.Fatalf("PORT = %q, want %q without os expand", env["PORT"], "3000")
+	}
+}
+
+func Test_Expand_OSEnvLiterals(t *testing.T) {
+	t.Setenv("ENVSET_TEST_HOME", "/home/envset")
+	os.Unsetenv("ENVSET_TEST_UNSET")
+
+	env := EnvMap{
+		"DEFAULT": "${ENVSET_TEST_UNSET:-pa$$word}",
+		"LITERAL": "pa$word",
+		"BARE":    "${ENVSET_TEST_HOME}/bin:${ENVSET_TEST_UNSET}",
+	}
+	if err := env.Expand(true); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+
+	want := EnvMap{"DEFAULT": "pa$$word", "LITERAL": "pa$word", "BARE": "/home/envset/bin:"}
+	for k, v := range want {
+		if env[k] != v {
+			t.Fatalf("%s = %q, want %q", k, env[k], v)
+		}
+	}
+}
+
+func Test_Expand_UnbracedVariables(t *testing.T) {
+	t.Setenv("HOME", "/home/envset")
+	os.Unsetenv("ENVSET_TEST_UNSET")
+
+	env := EnvMap{
+		"HOST":    "localhost",
+		"A":       "$HOME/x",
+		"URL":     "http://$HOST:$PORT",
+		"PORT":    "3000",
+		"UNSET":   "$ENVSET_TEST_UNSET",
+		"ESCAPED": `\$HOME`,
+	}
+	if err := env.Expand(true); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+
+	want := EnvMap{"A": "/home/envset/x", "URL": "http://localhost:3000", "UNSET": "$ENVSET_TEST_UNSET", "ESCAPED": "$HOME"}
+	for k, v := range want {
+		if env[k] != v {
+			t.Fatalf("%s = %q, want %q", k, env[k], v)
+		}
+	}
+
+	env = EnvMap{"A": "$HOME/x"}
+	if err := env.Expand(false); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	if env["A"] != "$HOME/x" {
+		t.Fatalf("A = %q, want %q without os expand", env["A"], "$HOME/x")
+	}
+}
+
+func Test_Expand_UnsupportedOperator(t *testing.T) {
+	for _, value := range []string{"${ENVSET_TEST_HOME/x/y}", "${1abc}", "${HOST:x}"} {
+		env := EnvMap{"HOST": "localhost", "OUT": value}
+		if err := env.Expand(true); err == nil {
+			t.Fatalf("%s: expected unsupported expansion error, got %q", value, env["OUT"])
+		}
+	}
+
+	if _, err := interpolateKVStrings([]string{"${HOME/x/y}"}, EnvMap{}, true); err == nil {
+		t.Fatal("expected unsupported expansion error in args")
+	}
+}
+
+func Test_Expand_RequiredParameter(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, ".envset")
+	if err := os.WriteFile(filename, []byte("[production]\nDATABASE=${DB_URL:?must be set}\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	_, err := loadEnvironment("production", RunOptions{Filename: filename, ExportEnvName: "APP_ENV"})
+
+	var perr *ErrorParameterNotSet
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected ErrorParameterNotSet, got %v", err)
+	}
+
+	want := ErrorParameterNotSet{Section: "production", Key: "DATABASE", Parameter: "DB_URL", Msg: "must be set"}
+	if *perr != want {
+		t.Fatalf("error = %+v, want %+v", *perr, want)
+	}
+
+	if got := perr.Error(); got != "[production] DATABASE: DB_URL: must be set" {
+		t.Fatalf("message = %q", got)
+	}
+}
+
+func Test_InterpolateKVStrings_ParameterOperators(t *testing.T) {
+	args, err := interpolateKVStrings([]string{"--port=${PORT:-3000}", "${DEBUG:+--verbose}"}, EnvMap{"DEBUG": "1"}, false)
+	if err != nil {
+		t.Fatalf("interpolate: %v", err)
+	}
+
+	if args[0] != "--port=3000" || args[1] != "--verbose" {
+		t.Fatalf("args = %v", args)
+	}
+
+	t.Setenv("ENVSET_TEST_PORT", "8080")
+	args, err = interpolateKVStrings([]string{"--port=${ENVSET_TEST_PORT:-3000}", "${ENVSET_TEST_PORT}"}, EnvMap{}, true)
+	if err != nil || args[0] != "--port=8080" || args[1] != "8080" {
+		t.Fatalf("args = %v %v", args, err)
+	}
+
+	_, err = interpolateKVStrings([]string{"${TOKEN?}"}, EnvMap{}, false)
+	var perr *ErrorParameterNotSet
+	if !errors.As(err, &perr) || perr.Parameter != "TOKEN" {
+		t.Fatalf("expected ErrorParameterNotSet for TOKEN, got %v", err)
+	}
+}
+
+func Test_Expand_SelfReferenceUsesOSEnv(t *testing.T) {
+	t.Setenv("PORT", "8080")
+
+	env := EnvMap{"PORT": "${PORT:-3000}", "ADDR": "localhost:${PORT}"}
+	if err := env.Expand(true); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+
+	if env["PORT"] != "8080" || env["ADDR"] != "localhost:8080" {
+		t.Fatalf("env = %v", env)
+	}
+}
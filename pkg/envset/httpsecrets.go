@@ -0,0 +1,321 @@
+package envset
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// SecretsTokenEnv is the environment variable with the bearer
+	// token sent to HTTP secret providers.
+	SecretsTokenEnv = "ENVSET_SECRETS_TOKEN"
+	// SecretsHostsEnv is the environment variable with a comma
+	// separated list of hosts we send the token to, e.g.
+	// secrets.example.com,vault.internal:8443
+	SecretsHostsEnv = "ENVSET_SECRETS_HOSTS"
+)
+
+const (
+	// DefaultSecretsTimeout is how long we wait for a secret
+	DefaultSecretsTimeout = 10 * time.Second
+	// DefaultSecretsCacheTTL is how long we keep resolved secrets
+	DefaultSecretsCacheTTL = time.Minute
+	// maxSecretResponse limits the size of provider responses
+	maxSecretResponse = 1 << 20
+)
+
+// SecretResponse is the JSON body returned by HTTP secret
+// providers. Successful responses have a value and errors
+// have a non 2xx status code and an error message.
+type SecretResponse struct {
+	Value string `json:"value"`
+	Error string `json:"error,omitempty"`
+}
+
+// HTTPSecretResolver resolves ref+http:// and ref+https://
+// references using a JSON over HTTP protocol:
+//
+//	GET http://localhost:8787/production/DB_PASSWORD
+//	Authorization: Bearer <token>
+//	Accept: application/json
+//
+//	200 OK
+//	{"value": "s3cr3t"}
+//
+// Any other status code is an error, the body can have an
+// {"error": "message"}. Resolved values are cached for CacheTTL.
+//
+// The token is only sent to loopback hosts and to the hosts in
+// TokenHosts, and never over plain http to a remote host, so a
+// reference in an env file can't leak it to any server.
+type HTTPSecretResolver struct {
+	// Client used for requests, defaults to a client with
+	// DefaultSecretsTimeout
+	Client *http.Client
+	// Token is sent as a bearer token, if empty we use the
+	// ENVSET_SECRETS_TOKEN environment variable.
+	Token string
+	// TokenHosts are the remote hosts we send the token to over
+	// https, if empty we use the ENVSET_SECRETS_HOSTS environment
+	// variable. A host can include a port.
+	TokenHosts []string
+	// CacheTTL is how long we cache values, a negative value
+	// disables the cache. Defaults to DefaultSecretsCacheTTL
+	CacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+// both http and https share the cache
+var defaultHTTPSecretResolver = NewHTTPSecretResolver()
+
+// NewHTTPSecretResolver returns a resolver with default options
+func NewHTTPSecretResolver() *HTTPSecretResolver {
+	return &HTTPSecretResolver{}
+}
+
+// Resolve implements SecretResolver
+func (r *HTTPSecretResolver) Resolve(ctx context.Context, ref SecretRef) (string, error) {
+	if ref.Scheme != "http" && ref.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %s", ref.Scheme)
+	}
+
+	url := ref.Scheme + "://" + ref.Path
+	if val, ok := r.cached(url); ok {
+		return val, nil
+	}
+
+	val, err := r.fetch(ctx, url)
+	if err != nil {
+		return "", err
+	}
+
+	r.store(url, val)
+	return val, nil
+}
+
+func (r *HTTPSecretResolver) fetch(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	token := r.Token
+	if token == "" {
+		token = os.Getenv(SecretsTokenEnv)
+	}
+	if token != "" && r.sendToken(req.URL) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultSecretsTimeout}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request: %w", err)
+	}
+	defer res.Body.Close()
+
+	var body SecretResponse
+	decodeErr := json.NewDecoder(io.LimitReader(res.Body, maxSecretResponse)).Decode(&body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		if decodeErr == nil && body.Error != "" {
+			return "", fmt.Errorf("%s: %s", res.Status, body.Error)
+		}
+		return "", fmt.Errorf("%s", res.Status)
+	}
+
+	if decodeErr != nil {
+		return "", fmt.Errorf("decode response: %w", decodeErr)
+	}
+	return body.Value, nil
+}
+
+// sendToken returns true if we can send the token to u: loopback
+// hosts get it over http or https, allowed hosts only over https.
+func (r *HTTPSecretResolver) sendToken(u *url.URL) bool {
+	if isLoopbackHost(u.Hostname()) {
+		return true
+	}
+	if u.Scheme != "https" {
+		return false
+	}
+
+	hosts := r.TokenHosts
+	if len(hosts) == 0 {
+		hosts = strings.Split(os.Getenv(SecretsHostsEnv), ",")
+	}
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host != "" && (strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname())) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (r *HTTPSecretResolver) ttl() time.Duration {
+	if r.CacheTTL == 0 {
+		return DefaultSecretsCacheTTL
+	}
+	return r.CacheTTL
+}
+
+func (r *HTTPSecretResolver) cached(url string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.cache[url]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.value, true
+}
+
+func (r *HTTPSecretResolver) store(url, value string) {
+	ttl := r.ttl()
+	if ttl < 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cache == nil {
+		r.cache = make(map[string]cachedSecret)
+	}
+	r.cache[url] = cachedSecret{value: value, expires: time.Now().Add(ttl)}
+}
+
+// SecretsServerOptions configures NewSecretsHandler
+type SecretsServerOptions struct {
+	// Filename is the env file with the secrets, values can be
+	// encrypted with envset encrypt.
+	Filename string
+	Format   string
+	// Cipher decrypts encrypted values
+	Cipher *Cipher
+	// Token is the bearer token clients have to send, if
+	// empty requests are not authenticated.
+	Token               string
+	CommentSectionNames []string
+}
+
+// NewSecretsHandler returns a handler that serves the values of an
+// env file with the HTTPSecretResolver protocol, values are found at
+// /<section>/<KEY>. The file is read on every request so changes
+// are picked up. It's meant as a stand-in for a secrets broker
+// during development and tests.
+func NewSecretsHandler(o SecretsServerOptions) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{section}/{key}", func(w http.ResponseWriter, r *http.Request) {
+		if o.Token != "" && !validToken(r.Header.Get("Authorization"), o.Token) {
+			writeSecretResponse(w, http.StatusUnauthorized, SecretResponse{Error: "invalid token"})
+			return
+		}
+
+		value, err := lookupSecret(o, r.PathValue("section"), r.PathValue("key"))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errSecretNotFound) {
+				status = http.StatusNotFound
+			}
+			writeSecretResponse(w, status, SecretResponse{Error: err.Error()})
+			return
+		}
+
+		writeSecretResponse(w, http.StatusOK, SecretResponse{Value: value})
+	})
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeSecretResponse(w, http.StatusNotFound, SecretResponse{Error: "expected GET /section/KEY"})
+	})
+
+	return mux
+}
+
+var errSecretNotFound = errors.New("secret not found")
+
+func lookupSecret(o SecretsServerOptions, section, key string) (string, error) {
+	filename, err := FileFinder(o.Filename)
+	if err != nil {
+		return "", fmt.Errorf("file finder %s: %w", o.Filename, err)
+	}
+
+	file, err := LoadFile(filename, o.Format, ini.LoadOptions{
+		UnparseableSections:     o.CommentSectionNames,
+		SkipUnrecognizableLines: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("file load: %w", err)
+	}
+
+	if findSection(file, section) == nil {
+		return "", fmt.Errorf("%w: section [%s]", errSecretNotFound, section)
+	}
+
+	l := &lazyCipher{cipher: o.Cipher}
+	if err := decryptSections(file, section, nil, l); err != nil {
+		return "", err
+	}
+
+	file, err = InheritSections(file, true)
+	if err != nil {
+		return "", fmt.Errorf("inherit sections: %w", err)
+	}
+
+	sec := findSection(file, section)
+	if sec == nil || isSpecialKey(key) || !sec.HasKey(key) {
+		return "", fmt.Errorf("%w: [%s] %s", errSecretNotFound, section, key)
+	}
+
+	value := sec.Key(key).String()
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	return l.decrypt(section, key, value)
+}
+
+// validToken compares the bearer token in constant time
+func validToken(header, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(header), []byte("Bearer "+token)) == 1
+}
+
+func writeSecretResponse(w http.ResponseWriter, status int, body SecretResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
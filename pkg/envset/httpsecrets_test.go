@@ -0,0 +1,210 @@
+package envset
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_HTTPSecretResolver(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			writeSecretResponse(w, http.StatusUnauthorized, SecretResponse{Error: "invalid token"})
+			return
+		}
+		if r.URL.Path == "/missing" {
+			writeSecretResponse(w, http.StatusNotFound, SecretResponse{Error: "secret not found"})
+			return
+		}
+		if r.URL.Path == "/html" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		writeSecretResponse(w, http.StatusOK, SecretResponse{Value: "value of " + r.URL.Path})
+	}))
+	defer srv.Close()
+
+	r := &HTTPSecretResolver{Token: "t0k3n"}
+	ref, _ := ParseSecretRef("ref+" + srv.URL + "/production/DB_PASSWORD")
+
+	for i := 0; i < 2; i++ {
+		got, err := r.Resolve(context.Background(), ref)
+		if err != nil {
+			t.Fatalf("resolve: %v", err)
+		}
+		if got != "value of /production/DB_PASSWORD" {
+			t.Fatalf("got %q", got)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("expected a cached value, got %d requests", hits.Load())
+	}
+
+	tests := []struct {
+		resolver *HTTPSecretResolver
+		path     string
+		want     string
+	}{
+		{r, "/missing", "404 Not Found: secret not found"},
+		{r, "/html", "502 Bad Gateway"},
+		{&HTTPSecretResolver{Token: "wrong"}, "/key", "401 Unauthorized: invalid token"},
+	}
+	for _, tt := range tests {
+		ref, _ := ParseSecretRef("ref+" + srv.URL + tt.path)
+		if _, err := tt.resolver.Resolve(context.Background(), ref); err == nil || err.Error() != tt.want {
+			t.Fatalf("%s: expected error %q, got %v", tt.path, tt.want, err)
+		}
+	}
+
+	//errors are not cached
+	ref, _ = ParseSecretRef("ref+" + srv.URL + "/missing")
+	before := hits.Load()
+	r.Resolve(context.Background(), ref)
+	if hits.Load() != before+1 {
+		t.Fatal("expected errors to not be cached")
+	}
+}
+
+func Test_HTTPSecretResolverTokenEnv(t *testing.T) {
+	srv := httptest.NewServer(NewSecretsHandler(SecretsServerOptions{Filename: writeSecretsFile(t, "[dev]\nKEY=value\n"), Token: "env-token"}))
+	defer srv.Close()
+
+	t.Setenv(SecretsTokenEnv, "env-token")
+	RegisterSecretResolver("http", &HTTPSecretResolver{CacheTTL: -1})
+	defer RegisterSecretResolver("http", defaultHTTPSecretResolver)
+
+	env := EnvMap{"KEY": "ref+" + srv.URL + "/dev/KEY"}
+	if err := env.Expand(false); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	if env["KEY"] != "value" {
+		t.Fatalf("got %q", env["KEY"])
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func Test_HTTPSecretResolverTokenHosts(t *testing.T) {
+	var auth string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		auth = req.Header.Get("Authorization")
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"value":"v"}`)),
+			Request:    req,
+		}, nil
+	})}
+
+	t.Setenv(SecretsHostsEnv, "vault.example.com:8443, secrets.example.com")
+	r := &HTTPSecretResolver{Client: client, Token: "t0k3n", CacheTTL: -1}
+
+	tests := map[string]bool{
+		"http://localhost:8787/dev/KEY":              true,
+		"http://127.0.0.1/dev/KEY":                   true,
+		"https://secrets.example.com/dev/KEY":        true,
+		"https://vault.example.com:8443/dev/KEY":     true,
+		"https://vault.example.com/dev/KEY":          false,
+		"http://secrets.example.com/dev/KEY":         false,
+		"https://attacker.example.net/dev/KEY":       false,
+		"https://secrets.example.com.evil.net/a/KEY": false,
+	}
+	for ref, want := range tests {
+		auth = ""
+		sr, _ := ParseSecretRef("ref+" + ref)
+		if _, err := r.Resolve(context.Background(), sr); err != nil {
+			t.Fatalf("%s: resolve: %v", ref, err)
+		}
+		if got := auth == "Bearer t0k3n"; got != want {
+			t.Fatalf("%s: sent token %v, want %v", ref, got, want)
+		}
+	}
+}
+
+func Test_HTTPSecretResolverTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	r := &HTTPSecretResolver{Client: &http.Client{Timeout: 50 * time.Millisecond}}
+	ref, _ := ParseSecretRef("ref+" + srv.URL + "/slow")
+	if _, err := r.Resolve(context.Background(), ref); err == nil || !strings.Contains(err.Error(), "Timeout") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+func Test_SecretsHandler(t *testing.T) {
+	c, _ := NewCipher([]byte("secret"))
+	password, _ := c.Encrypt("production", "DB_PASSWORD", "s3cr3t")
+	section, _ := c.Encrypt("production", EncryptedKey, "TOKEN=abc\n")
+
+	filename := writeSecretsFile(t, "[production]\n"+
+		EncryptedKey+"="+section+"\n"+
+		"DB_PASSWORD="+password+"\n"+
+		"HOST=prod.example.com\n"+
+		"\n[staging : production]\nHOST=staging.example.com\n")
+
+	srv := httptest.NewServer(NewSecretsHandler(SecretsServerOptions{Filename: filename, Cipher: c, Token: "dev"}))
+	defer srv.Close()
+
+	tests := []struct {
+		path   string
+		token  string
+		status int
+		body   string
+	}{
+		{"/production/DB_PASSWORD", "dev", http.StatusOK, `{"value":"s3cr3t"}`},
+		{"/production/TOKEN", "dev", http.StatusOK, `{"value":"abc"}`},
+		{"/staging/TOKEN", "dev", http.StatusOK, `{"value":"abc"}`},
+		{"/staging/HOST", "dev", http.StatusOK, `{"value":"staging.example.com"}`},
+		{"/production/MISSING", "dev", http.StatusNotFound, `{"value":"","error":"secret not found: [production] MISSING"}`},
+		{"/production/@encrypted", "dev", http.StatusNotFound, `{"value":"","error":"secret not found: [production] @encrypted"}`},
+		{"/qa/HOST", "dev", http.StatusNotFound, `{"value":"","error":"secret not found: section [qa]"}`},
+		{"/production/DB_PASSWORD", "wrong", http.StatusUnauthorized, `{"value":"","error":"invalid token"}`},
+		{"/production", "dev", http.StatusNotFound, `{"value":"","error":"expected GET /section/KEY"}`},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		body := new(bytes.Buffer)
+		_, _ = body.ReadFrom(res.Body)
+		res.Body.Close()
+
+		if res.StatusCode != tt.status || strings.TrimSpace(body.String()) != tt.body {
+			t.Fatalf("%s: got %d %s, want %d %s", tt.path, res.StatusCode, body, tt.status, tt.body)
+		}
+	}
+}
+
+func writeSecretsFile(t *testing.T, contents string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), ".envset")
+	if err := os.WriteFile(filename, []byte(contents), 0600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	return filename
+}
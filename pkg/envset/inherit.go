@@ -0,0 +1,164 @@
+package envset
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// ExtendsKey can be used in a section to declare its parent
+// section, same as naming the section [staging : production].
+const ExtendsKey = "@extends"
+
+// sectionParent splits a section name like "staging : production"
+// into the section name and the name of the section it extends.
+func sectionParent(name string) (string, string) {
+	child, parent, ok := strings.Cut(name, ":")
+	if !ok {
+		return name, ""
+	}
+	return strings.TrimSpace(child), strings.TrimSpace(parent)
+}
+
+type inheritSection struct {
+	sec    *ini.Section
+	parent string
+}
+
+// sectionInheritance resolves the keys of a section by layering
+// the keys of its parent sections, closest parent wins.
+type sectionInheritance struct {
+	sections  map[string]inheritSection
+	resolved  map[string][]*ini.Key
+	resolving map[string]bool
+}
+
+func newSectionInheritance(file *ini.File) (*sectionInheritance, error) {
+	r := &sectionInheritance{
+		sections:  make(map[string]inheritSection),
+		resolved:  make(map[string][]*ini.Key),
+		resolving: make(map[string]bool),
+	}
+
+	for _, sec := range file.Sections() {
+		name, parent := sectionParent(sec.Name())
+
+		if sec.HasKey(ExtendsKey) {
+			extends := strings.TrimSpace(sec.Key(ExtendsKey).String())
+			if parent != "" && parent != extends {
+				return nil, fmt.Errorf("section [%s] extends both [%s] and [%s]", name, parent, extends)
+			}
+			parent = extends
+		}
+
+		if _, ok := r.sections[name]; ok {
+			return nil, fmt.Errorf("section [%s] is defined more than once", name)
+		}
+		r.sections[name] = inheritSection{sec: sec, parent: parent}
+	}
+
+	return r, nil
+}
+
+func (r *sectionInheritance) resolveSection(name string) ([]*ini.Key, error) {
+	if keys, ok := r.resolved[name]; ok {
+		return keys, nil
+	}
+
+	if r.resolving[name] {
+		return nil, fmt.Errorf("cyclic section inheritance involving [%s]", name)
+	}
+
+	s := r.sections[name]
+	r.resolving[name] = true
+	defer delete(r.resolving, name)
+
+	keys := []*ini.Key{}
+	if s.parent != "" {
+		if _, ok := r.sections[s.parent]; !ok {
+			return nil, fmt.Errorf("section [%s] extends unknown section [%s]", name, s.parent)
+		}
+
+		parentKeys, err := r.resolveSection(s.parent)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, parentKeys...)
+	}
+
+	for _, key := range s.sec.Keys() {
+		if key.Name() == ExtendsKey {
+			continue
+		}
+		keys = setInheritedKey(keys, key)
+	}
+
+	r.resolved[name] = keys
+	return keys, nil
+}
+
+func setInheritedKey(keys []*ini.Key, key *ini.Key) []*ini.Key {
+	for i, k := range keys {
+		if k.Name() == key.Name() {
+			keys[i] = key
+			return keys
+		}
+	}
+	return append(keys, key)
+}
+
+// InheritSections returns a copy of the env file where every
+// section that extends another one, either as [staging : production]
+// or with an @extends key, holds the keys of its parents as well
+// as its own keys. If merge is false sections only hold their own
+// keys, but names are still normalized, e.g. [staging].
+func InheritSections(file *ini.File, merge bool) (*ini.File, error) {
+	r, err := newSectionInheritance(file)
+	if err != nil {
+		return nil, err
+	}
+
+	out := ini.Empty()
+	for _, sec := range file.Sections() {
+		name, _ := sectionParent(sec.Name())
+
+		if body := sec.Body(); body != "" {
+			raw, err := out.NewRawSection(name, body)
+			if err != nil {
+				return nil, fmt.Errorf("new raw section %s: %w", name, err)
+			}
+			raw.Comment = sec.Comment
+			continue
+		}
+
+		keys := []*ini.Key{}
+		if merge {
+			if keys, err = r.resolveSection(name); err != nil {
+				return nil, err
+			}
+		} else {
+			for _, key := range sec.Keys() {
+				if key.Name() != ExtendsKey {
+					keys = append(keys, key)
+				}
+			}
+		}
+
+		target, err := out.NewSection(name)
+		if err != nil {
+			return nil, fmt.Errorf("new section %s: %w", name, err)
+		}
+		target.Comment = sec.Comment
+
+		for _, key := range keys {
+			k, err := target.NewKey(key.Name(), key.Value())
+			if err != nil {
+				return nil, fmt.Errorf("new key %s.%s: %w", name, key.Name(), err)
+			}
+			k.Comment = key.Comment
+		}
+	}
+
+	return out, nil
+}
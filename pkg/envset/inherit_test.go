@@ -0,0 +1,111 @@
+package envset
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func Test_InheritSections(t *testing.T) {
+	file, err := ini.Load([]byte(`
+[production]
+# public url
+APP_URL=https://envset.sh
+DB_HOST=db.envset.sh
+LOG_LEVEL=warn
+
+[staging : production]
+APP_URL=https://staging.envset.sh
+
+[preview]
+@extends=staging
+LOG_LEVEL=debug
+`))
+	if err != nil {
+		t.Fatalf("ini load: %v", err)
+	}
+
+	merged, err := InheritSections(file, true)
+	if err != nil {
+		t.Fatalf("inherit sections: %v", err)
+	}
+
+	wantSections := []string{DefaultSection, "production", "staging", "preview"}
+	if got := merged.SectionStrings(); !reflect.DeepEqual(got, wantSections) {
+		t.Fatalf("sections = %v, want %v", got, wantSections)
+	}
+
+	assertSection(t, merged, "staging", EnvMap{
+		"APP_URL":   "https://staging.envset.sh",
+		"DB_HOST":   "db.envset.sh",
+		"LOG_LEVEL": "warn",
+	})
+	assertSection(t, merged, "preview", EnvMap{
+		"APP_URL":   "https://staging.envset.sh",
+		"DB_HOST":   "db.envset.sh",
+		"LOG_LEVEL": "debug",
+	})
+
+	if got := merged.Section("preview").KeyStrings(); !reflect.DeepEqual(got, []string{"APP_URL", "DB_HOST", "LOG_LEVEL"}) {
+		t.Fatalf("preview keys = %v, expected parent key order", got)
+	}
+
+	if got := merged.Section("staging").Key("APP_URL").Comment; got != "" {
+		t.Fatalf("expected own key comment to win, got %q", got)
+	}
+	if got := merged.Section("production").Key("APP_URL").Comment; got != "# public url" {
+		t.Fatalf("comment = %q, want %q", got, "# public url")
+	}
+
+	raw, err := InheritSections(file, false)
+	if err != nil {
+		t.Fatalf("inherit sections: %v", err)
+	}
+	assertSection(t, raw, "staging", EnvMap{"APP_URL": "https://staging.envset.sh"})
+	assertSection(t, raw, "preview", EnvMap{"LOG_LEVEL": "debug"})
+}
+
+func Test_InheritSectionsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "cycle",
+			src:  "[a : c]\nA=1\n[b : a]\nB=1\n[c]\n@extends=b\n",
+			want: "cyclic section inheritance",
+		},
+		{
+			name: "self",
+			src:  "[a : a]\nA=1\n",
+			want: "cyclic section inheritance involving [a]",
+		},
+		{
+			name: "unknown parent",
+			src:  "[staging : production]\nA=1\n",
+			want: "section [staging] extends unknown section [production]",
+		},
+		{
+			name: "conflicting parents",
+			src:  "[a]\nA=1\n[b]\nB=1\n[c : a]\n@extends=b\n",
+			want: "section [c] extends both [a] and [b]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ini.Load([]byte(tt.src))
+			if err != nil {
+				t.Fatalf("ini load: %v", err)
+			}
+
+			_, err = InheritSections(file, true)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
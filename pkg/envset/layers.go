@@ -0,0 +1,246 @@
+package envset
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// envLayer is an env file that is merged into the environment,
+// files loaded later take precedence over earlier ones.
+type envLayer struct {
+	path   string
+	format string
+	//optional layers are skipped if the file does not exist
+	optional bool
+	//keys in the default section belong to the environment,
+	//e.g. .envset.development.local
+	environment bool
+}
+
+// envLayers returns the env files for an environment in order of
+// precedence: the env file, any overlays and then the local files
+// .envset.local and .envset.<env>.local next to the env file.
+// options.Format only applies to the env file, overlays are loaded
+// in the format of their name.
+func envLayers(environment string, options RunOptions) ([]envLayer, error) {
+	base, err := FileFinder(options.Filename) //TODO: This might be an issue here!
+	if err != nil {
+		return nil, fmt.Errorf("file finder %s: %w", options.Filename, err)
+	}
+
+	format := options.Format
+	if format == "" {
+		format = DetectFormat(base)
+	}
+
+	layers := []envLayer{{path: base, format: format}}
+
+	for _, name := range options.Overlays {
+		path, err := FileFinder(name)
+		if err != nil {
+			return nil, fmt.Errorf("file finder %s: %w", name, err)
+		}
+		layers = append(layers, envLayer{path: path, format: DetectFormat(path)})
+	}
+
+	if !options.LocalOverlays {
+		return layers, nil
+	}
+
+	layers = append(layers, envLayer{
+		path:     localFilename(base, format, ""),
+		format:   format,
+		optional: true,
+	})
+
+	if environment != DefaultSection {
+		layers = append(layers, envLayer{
+			path:        localFilename(base, format, environment),
+			format:      format,
+			optional:    true,
+			environment: true,
+		})
+	}
+
+	return layers, nil
+}
+
+// localFilename returns the name of the local overlay for an env
+// file, e.g. .envset.local, .envset.development.local or for
+// structured files .envset.local.yaml
+func localFilename(path, format, environment string) string {
+	suffix := ".local"
+	if environment != "" {
+		suffix = "." + environment + suffix
+	}
+
+	switch format {
+	case FormatYAML, FormatTOML, FormatJSON:
+		ext := filepath.Ext(path)
+		return strings.TrimSuffix(path, ext) + suffix + ext
+	}
+	return path + suffix
+}
+
+// loadEnvLayers loads and merges all env files of an environment.
+// The returned sources map every key to the file that defined it.
+func loadEnvLayers(environment string, options RunOptions) (*ini.File, map[*ini.Key]string, error) {
+	env, sources, err := mergeEnvLayers(environment, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := decryptSections(env, environment, sources, &lazyCipher{options: options}); err != nil {
+		return nil, nil, err
+	}
+
+	return env, sources, nil
+}
+
+// mergeEnvLayers merges all env files of an environment without
+// decrypting encrypted sections.
+func mergeEnvLayers(environment string, options RunOptions) (*ini.File, map[*ini.Key]string, error) {
+	layers, err := envLayers(environment, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var env *ini.File
+	sources := make(map[*ini.Key]string)
+
+	for _, layer := range layers {
+		if layer.optional {
+			if _, err := os.Stat(layer.path); errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+		}
+
+		file, err := loadEnvLayer(layer, options)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if env == nil {
+			env = file
+			for _, sec := range env.Sections() {
+				for _, key := range sec.Keys() {
+					sources[key] = layer.path
+				}
+			}
+			continue
+		}
+
+		if err := mergeEnvLayer(env, file, layer, environment, sources); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return env, sources, nil
+}
+
+func loadEnvLayer(layer envLayer, options RunOptions) (*ini.File, error) {
+	file, err := LoadFile(layer.path, layer.format, ini.LoadOptions{
+		UnparseableSections:     options.CommentSectionNames,
+		SkipUnrecognizableLines: true,
+	})
+	if err == nil {
+		return file, nil
+	}
+
+	if ini.IsErrDelimiterNotFound(err) {
+		fmt.Printf("The file \"%s\" has an error and we can't parse it.\n", layer.path)
+		fmt.Println("It looks as if you forgot a variable name.")
+		var delErr ini.ErrDelimiterNotFound
+		if errors.As(err, &delErr) {
+			fmt.Printf("The offending line content: %s\n", delErr.Line)
+		}
+	}
+	//error parsing data source: unknown type
+	return nil, fmt.Errorf("file load: %w", err)
+}
+
+func mergeEnvLayer(env, file *ini.File, layer envLayer, environment string, sources map[*ini.Key]string) error {
+	for _, sec := range file.Sections() {
+		//comment sections are documentation, keep the base ones
+		if sec.Body() != "" {
+			continue
+		}
+
+		name := sec.Name()
+		if layer.environment && name == DefaultSection {
+			name = environment
+		}
+
+		target, err := layerSection(env, name)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range sec.Keys() {
+			k, err := target.NewKey(key.Name(), key.Value())
+			if err != nil {
+				return fmt.Errorf("new key %s.%s: %w", name, key.Name(), err)
+			}
+			if key.Comment != "" {
+				k.Comment = key.Comment
+			}
+			sources[k] = layer.path
+		}
+	}
+	return nil
+}
+
+// layerSection finds the section that an overlay section extends,
+// names are compared without parents so [staging] in .envset.local
+// matches [staging : production] in .envset
+func layerSection(env *ini.File, name string) (*ini.Section, error) {
+	child, _ := sectionParent(name)
+	for _, sec := range env.Sections() {
+		if n, _ := sectionParent(sec.Name()); n == child {
+			return sec, nil
+		}
+	}
+
+	sec, err := env.NewSection(name)
+	if err != nil {
+		return nil, fmt.Errorf("new section %s: %w", name, err)
+	}
+	return sec, nil
+}
+
+// EnvSources returns the file each key of an environment was
+// loaded from after merging all env files.
+func EnvSources(environment string, options RunOptions) (EnvMap, error) {
+	env, sources, err := loadEnvLayers(environment, options)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newSectionInheritance(env)
+	if err != nil {
+		return nil, fmt.Errorf("inherit sections: %w", err)
+	}
+
+	if _, ok := r.sections[environment]; !ok {
+		return nil, envSectionErrorNotFound{
+			nil,
+			fmt.Sprintf("run: section [%s] not found in env file", environment),
+		}
+	}
+
+	keys, err := r.resolveSection(environment)
+	if err != nil {
+		return nil, fmt.Errorf("inherit sections: %w", err)
+	}
+
+	out := make(EnvMap, len(keys))
+	for _, key := range keys {
+		out[key.Name()] = sources[key]
+	}
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package envset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_LayeredEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".envset":                   "[production]\nAPP_URL=https://envset.sh\nDB_HOST=db.envset.sh\nLOG_LEVEL=warn\n\n[staging : production]\nAPP_URL=https://staging.envset.sh\n",
+		".envset.shared":            "[production]\nLOG_LEVEL=info\n",
+		".envset.local":             "[production]\nDB_HOST=localhost\n",
+		".envset.staging.local":     "LOG_LEVEL=debug\n",
+		".envset.development.local": "LOG_LEVEL=trace\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	options := RunOptions{
+		Filename:      filepath.Join(dir, ".envset"),
+		Overlays:      []string{filepath.Join(dir, ".envset.shared")},
+		LocalOverlays: true,
+		ExportEnvName: "APP_ENV",
+	}
+
+	env, err := getEnvFile("staging", options)
+	if err != nil {
+		t.Fatalf("get env file: %v", err)
+	}
+
+	assertSection(t, env, "production", EnvMap{
+		"APP_URL":   "https://envset.sh",
+		"DB_HOST":   "localhost",
+		"LOG_LEVEL": "info",
+	})
+	assertSection(t, env, "staging", EnvMap{
+		"APP_URL":   "https://staging.envset.sh",
+		"DB_HOST":   "localhost",
+		"LOG_LEVEL": "debug",
+	})
+
+	sources, err := EnvSources("staging", options)
+	if err != nil {
+		t.Fatalf("env sources: %v", err)
+	}
+
+	want := EnvMap{
+		"APP_URL":   filepath.Join(dir, ".envset"),
+		"DB_HOST":   filepath.Join(dir, ".envset.local"),
+		"LOG_LEVEL": filepath.Join(dir, ".envset.staging.local"),
+	}
+	if !reflect.DeepEqual(sources, want) {
+		t.Fatalf("sources = %v, want %v", sources, want)
+	}
+
+	options.LocalOverlays = false
+	env, err = getEnvFile("staging", options)
+	if err != nil {
+		t.Fatalf("get env file: %v", err)
+	}
+	if got := env.Section("staging").Key("LOG_LEVEL").String(); got != "info" {
+		t.Fatalf("LOG_LEVEL = %q, want %q without local overlays", got, "info")
+	}
+}
+
+func Test_LayeredEnvFilesFormat(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"env.production": "production:\n  APP_URL: https://envset.sh\n  LOG_LEVEL: warn\n",
+		"shared.envset":  "[production]\nLOG_LEVEL=info\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	//the format flag only applies to the env file
+	env, err := getEnvFile("production", RunOptions{
+		Filename: filepath.Join(dir, "env.production"),
+		Format:   FormatYAML,
+		Overlays: []string{filepath.Join(dir, "shared.envset")},
+	})
+	if err != nil {
+		t.Fatalf("get env file: %v", err)
+	}
+
+	assertSection(t, env, "production", EnvMap{
+		"APP_URL":   "https://envset.sh",
+		"LOG_LEVEL": "info",
+	})
+}
+
+func Test_LocalFilename(t *testing.T) {
+	tests := []struct {
+		path        string
+		format      string
+		environment string
+		want        string
+	}{
+		{".envset", FormatINI, "", ".envset.local"},
+		{".envset", FormatINI, "development", ".envset.development.local"},
+		{".env", FormatDotenv, "development", ".env.development.local"},
+		{".envset.yaml", FormatYAML, "", ".envset.local.yaml"},
+		{".envset.json", FormatJSON, "staging", ".envset.staging.local.json"},
+	}
+
+	for _, tt := range tests {
+		if got := localFilename(tt.path, tt.format, tt.environment); got != tt.want {
+			t.Errorf("localFilename(%q, %q, %q) = %q, want %q", tt.path, tt.format, tt.environment, got, tt.want)
+		}
+	}
+}
+
+func Test_LayeredEnvFilesMissingOverlay(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".envset"), []byte("A=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	_, err := getEnvFile(DefaultSection, RunOptions{
+		Filename: filepath.Join(dir, ".envset"),
+		Overlays: []string{filepath.Join(dir, "missing.envset")},
+	})
+	if err == nil {
+		t.Fatal("expected error for missing overlay")
+	}
+}
@@ -0,0 +1,524 @@
+package envset
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// Lint rules, see LintRules
+const (
+	RuleDuplicateKey        = "duplicate-key"
+	RuleInvalidName         = "invalid-name"
+	RuleTrailingWhitespace  = "trailing-whitespace"
+	RuleUnquotedSpaces      = "unquoted-spaces"
+	RuleUndefinedReference  = "undefined-reference"
+	RuleCyclicReference     = "cyclic-reference"
+	RuleCommandSubstitution = "command-substitution"
+	RuleMissingKey          = "missing-key"
+	RuleUnknownSection      = "unknown-section"
+)
+
+// LintRules are the rules checked by Lint
+var LintRules = []string{
+	RuleDuplicateKey,
+	RuleInvalidName,
+	RuleTrailingWhitespace,
+	RuleUnquotedSpaces,
+	RuleUndefinedReference,
+	RuleCyclicReference,
+	RuleCommandSubstitution,
+	RuleMissingKey,
+	RuleUnknownSection,
+}
+
+// LintOptions configures Lint
+type LintOptions struct {
+	Filename            string
+	Format              string
+	Overlays            []string
+	LocalOverlays       bool
+	CommentSectionNames []string
+	// Environments are the known environment names, other
+	// sections are reported by the unknown-section rule.
+	Environments []string
+	// Expand looks up references that are not defined in
+	// the env file in the OS environment.
+	Expand bool
+	// Rules enables or disables rules by name, rules that
+	// are not listed are enabled.
+	Rules map[string]bool
+}
+
+// LintIssue is a problem found in an env file. Line is 0 if
+// we don't know the line, e.g. for yaml files.
+type LintIssue struct {
+	Rule    string `json:"rule"`
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Section string `json:"section,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	pos := i.File
+	if i.Line > 0 {
+		pos = fmt.Sprintf("%s:%d", pos, i.Line)
+	}
+
+	subject := ""
+	if i.Section != "" {
+		subject = fmt.Sprintf("[%s] ", i.Section)
+	}
+	if i.Key != "" {
+		subject += i.Key + ": "
+	}
+	return fmt.Sprintf("%s: %s%s (%s)", pos, subject, i.Message, i.Rule)
+}
+
+// lintLocation is where a key or section is defined
+type lintLocation struct {
+	file string
+	line int
+}
+
+type linter struct {
+	options   LintOptions
+	issues    []LintIssue
+	keys      map[string]map[string]lintLocation
+	sections  map[string]lintLocation
+	baseFile  string
+	ownKeys   map[string][]string
+	encrypted map[string]bool
+}
+
+// Lint checks the env file and its overlays and returns the
+// issues found sorted by file and line.
+func Lint(o LintOptions) ([]LintIssue, error) {
+	for name := range o.Rules {
+		if !slices.Contains(LintRules, name) {
+			return nil, fmt.Errorf("unknown lint rule %q, expected one of %s", name, strings.Join(LintRules, ", "))
+		}
+	}
+
+	options := RunOptions{
+		Filename:            o.Filename,
+		Format:              o.Format,
+		Overlays:            o.Overlays,
+		LocalOverlays:       o.LocalOverlays,
+		CommentSectionNames: o.CommentSectionNames,
+	}
+
+	layers, err := envLayers(DefaultSection, options)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &linter{
+		options:   o,
+		keys:      make(map[string]map[string]lintLocation),
+		sections:  make(map[string]lintLocation),
+		baseFile:  layers[0].path,
+		ownKeys:   make(map[string][]string),
+		encrypted: make(map[string]bool),
+	}
+
+	for _, layer := range layers {
+		if layer.optional {
+			if _, err := os.Stat(layer.path); err != nil {
+				continue
+			}
+		}
+		if err := l.lintLines(layer); err != nil {
+			return nil, err
+		}
+	}
+
+	env, sources, err := loadEnvLayers(DefaultSection, options)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sec := range env.Sections() {
+		if sec.Body() != "" {
+			continue
+		}
+		name, _ := sectionParent(sec.Name())
+		l.encrypted[name] = sec.HasKey(EncryptedKey)
+		for _, key := range sec.Keys() {
+			if isSpecialKey(key.Name()) {
+				continue
+			}
+			l.ownKeys[name] = append(l.ownKeys[name], key.Name())
+			//keys of structured files have no lines
+			if _, ok := l.keys[name][key.Name()]; !ok {
+				l.setKey(name, key.Name(), lintLocation{file: sources[key]})
+			}
+		}
+	}
+
+	env, err = InheritSections(env, true)
+	if err != nil {
+		return nil, fmt.Errorf("inherit sections: %w", err)
+	}
+
+	l.lintSections(env)
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		a, b := l.issues[i], l.issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return l.issues, nil
+}
+
+func (l *linter) enabled(rule string) bool {
+	enabled, ok := l.options.Rules[rule]
+	return !ok || enabled
+}
+
+func (l *linter) report(rule string, loc lintLocation, section, key, format string, args ...any) {
+	if !l.enabled(rule) {
+		return
+	}
+	if loc.file == "" {
+		loc.file = l.baseFile
+	}
+	l.issues = append(l.issues, LintIssue{
+		Rule:    rule,
+		File:    loc.file,
+		Line:    loc.line,
+		Section: section,
+		Key:     key,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) setKey(section, key string, loc lintLocation) {
+	if l.keys[section] == nil {
+		l.keys[section] = make(map[string]lintLocation)
+	}
+	l.keys[section][key] = loc
+}
+
+// lintLines checks the lines of ini and dotenv files, we can't
+// tell where keys of structured files are defined.
+func (l *linter) lintLines(layer envLayer) error {
+	format := layer.format
+	if format == "" {
+		format = DetectFormat(layer.path)
+	}
+	if format != FormatINI && format != FormatDotenv {
+		return nil
+	}
+
+	b, err := os.ReadFile(layer.path) // #nosec G304 -- envset intentionally reads user-provided env files.
+	if err != nil {
+		return fmt.Errorf("read file %s: %w", layer.path, err)
+	}
+
+	e := &envFileEditor{
+		filename:        layer.path,
+		lines:           strings.SplitAfter(string(b), "\n"),
+		commentSections: l.options.CommentSectionNames,
+	}
+
+	for i, line := range e.lines {
+		loc := lintLocation{file: layer.path, line: i + 1}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimRight(line, " \t") != line {
+			l.report(RuleTrailingWhitespace, loc, "", "", "trailing whitespace")
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			name := strings.TrimSpace(line)[1:]
+			if end := strings.LastIndexByte(name, ']'); end != -1 {
+				name, _ = sectionParent(name[:end])
+				if _, ok := l.sections[name]; !ok {
+					l.sections[name] = loc
+				}
+			}
+		}
+	}
+
+	spans, _ := e.scan()
+	seen := make(map[string]map[string]int)
+	for _, span := range spans {
+		section := span.section
+		key := span.key
+		if format == FormatDotenv {
+			key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		}
+
+		loc := lintLocation{file: layer.path, line: span.start + 1}
+		l.setKey(section, key, loc)
+
+		if seen[section] == nil {
+			seen[section] = make(map[string]int)
+		}
+		if first, ok := seen[section][key]; ok {
+			l.report(RuleDuplicateKey, loc, section, key, "defined more than once, this value overrides line %d", first)
+		}
+		seen[section][key] = loc.line
+
+		if isSpecialKey(key) {
+			continue
+		}
+
+		if !isVarName(key) {
+			l.report(RuleInvalidName, loc, section, key, "invalid variable name, use letters, digits and _ and don't start with a digit")
+		}
+
+		if span.end-span.start == 1 && hasUnquotedSpaces(format, e.lines[span.start][len(span.prefix):]) {
+			l.report(RuleUnquotedSpaces, loc, section, key, "value with spaces should be quoted")
+		}
+	}
+	return nil
+}
+
+// hasUnquotedSpaces returns true if the raw value of a key has
+// spaces and is not quoted. Inline comments are ignored.
+func hasUnquotedSpaces(format, value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.ContainsRune("\"'`", rune(value[0])) {
+		return false
+	}
+
+	if format == FormatINI {
+		if i := strings.IndexAny(value, "#;"); i != -1 {
+			value = value[:i]
+		}
+	} else {
+		for i := 1; i < len(value); i++ {
+			if value[i] == '#' && (value[i-1] == ' ' || value[i-1] == '\t') {
+				value = value[:i]
+				break
+			}
+		}
+	}
+	return strings.ContainsAny(strings.TrimSpace(value), " \t")
+}
+
+// location returns where a key of a section is defined, keys
+// inherited from a parent section return false.
+func (l *linter) location(section, key string) (lintLocation, bool) {
+	if !slices.Contains(l.ownKeys[section], key) {
+		return lintLocation{}, false
+	}
+	return l.keys[section][key], true
+}
+
+func (l *linter) lintSections(env *ini.File) {
+	environments := []string{}
+	for _, sec := range env.Sections() {
+		if sec.Body() != "" {
+			continue
+		}
+		name := sec.Name()
+		context := LoadIniSection(sec)
+		delete(context, ExtendsKey)
+		delete(context, EncryptedKey)
+
+		l.lintReferences(name, context)
+
+		if name == DefaultSection {
+			continue
+		}
+		environments = append(environments, name)
+
+		if len(l.options.Environments) > 0 && !slices.Contains(l.options.Environments, name) {
+			l.report(RuleUnknownSection, l.sections[name], name, "", "section is not listed in .envsetrc [environments]")
+		}
+	}
+
+	l.lintMissingKeys(env, environments)
+}
+
+// lintReferences checks the ${VAR} references and command
+// substitutions of the keys of a section.
+func (l *linter) lintReferences(section string, context EnvMap) {
+	graph := make(map[string][]string)
+	for _, key := range sortedEnvKeys(context) {
+		value := context[key]
+		loc, own := l.location(section, key)
+
+		for _, ref := range varReferences(value) {
+			//a key that references itself uses the OS environment
+			if ref.name == key {
+				continue
+			}
+			if _, ok := context[ref.name]; ok {
+				if !slices.Contains(graph[key], ref.name) {
+					graph[key] = append(graph[key], ref.name)
+				}
+				continue
+			}
+			if !own || ref.optional {
+				continue
+			}
+			if _, ok := os.LookupEnv(ref.name); ok && l.options.Expand {
+				continue
+			}
+			l.report(RuleUndefinedReference, loc, section, key, "reference to undefined variable %s", ref.name)
+		}
+
+		if own && hasCommandSubstitution(value) {
+			for _, command := range commandSubstitutions(value) {
+				l.report(RuleCommandSubstitution, loc, section, key, "runs command $(%s)", command)
+			}
+		}
+	}
+
+	for _, cycle := range referenceCycles(graph) {
+		loc, _ := l.location(section, cycle[0])
+		l.report(RuleCyclicReference, loc, section, cycle[0], "cyclic reference %s", strings.Join(append(cycle, cycle[0]), " -> "))
+	}
+}
+
+// lintMissingKeys reports keys that are defined in some
+// environments but not in others.
+func (l *linter) lintMissingKeys(env *ini.File, environments []string) {
+	found := make(map[string][]string)
+	for _, name := range environments {
+		//we can't see the keys of encrypted sections
+		if l.encrypted[name] {
+			continue
+		}
+		for _, key := range env.Section(name).KeyStrings() {
+			if isSpecialKey(key) {
+				continue
+			}
+			found[key] = append(found[key], name)
+		}
+	}
+
+	keys := make([]string, 0, len(found))
+	for key := range found {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, name := range environments {
+		if l.encrypted[name] {
+			continue
+		}
+		for _, key := range keys {
+			if slices.Contains(found[key], name) {
+				continue
+			}
+			l.report(RuleMissingKey, l.sections[name], name, key, "not defined, found in %s", strings.Join(found[key], ", "))
+		}
+	}
+}
+
+// varReference is a ${VAR} reference, optional references
+// have a default or an error message, e.g. ${VAR:-default}.
+type varReference struct {
+	name     string
+	optional bool
+}
+
+// varReferences returns the ${VAR} references of a value,
+// including references nested in parameter words.
+func varReferences(str string) []varReference {
+	refs := []varReference{}
+	for i := 0; i < len(str); i++ {
+		if str[i] == '\\' {
+			i++
+			continue
+		}
+		if i+2 >= len(str) || str[i] != '$' || str[i+1] != '{' {
+			continue
+		}
+
+		end := closingBrace(str, i+2)
+		if end == -1 {
+			break
+		}
+
+		expr := strings.TrimPrefix(str[i+2:end], "#")
+		name := leadingVarName(expr)
+		if name != "" {
+			op, word := splitOperator(expr[len(name):])
+			switch op {
+			case ":-", ":=", "-", "=", ":?", "?", ":+", "+":
+				refs = append(refs, varReference{name: name, optional: true})
+			default:
+				refs = append(refs, varReference{name: name})
+			}
+			refs = append(refs, varReferences(word)...)
+		}
+		i = end
+	}
+	return refs
+}
+
+// commandSubstitutions returns the commands of $(command)
+// substitutions in a value.
+func commandSubstitutions(str string) []string {
+	commands := []string{}
+	for i := 0; i+1 < len(str); i++ {
+		if str[i] == '\\' {
+			i++
+			continue
+		}
+		if str[i] != '$' || str[i+1] != '(' {
+			continue
+		}
+		command, end, err := scanCommandSubstitution(str, i)
+		if err != nil {
+			break
+		}
+		commands = append(commands, command)
+		i = end - 1
+	}
+	return commands
+}
+
+// referenceCycles returns the cycles of a reference graph, each
+// cycle starts with its lowest key so we report it once.
+func referenceCycles(graph map[string][]string) [][]string {
+	cycles := [][]string{}
+	seen := make(map[string]bool)
+	visited := make(map[string]bool)
+
+	var visit func(key string, path []string)
+	visit = func(key string, path []string) {
+		if i := slices.Index(path, key); i != -1 {
+			cycle := slices.Clone(path[i:])
+			first := slices.Index(cycle, slices.Min(cycle))
+			cycle = append(cycle[first:], cycle[:first]...)
+			if id := strings.Join(cycle, " "); !seen[id] {
+				seen[id] = true
+				cycles = append(cycles, cycle)
+			}
+			return
+		}
+		if visited[key] {
+			return
+		}
+		path = append(path, key)
+		for _, ref := range graph[key] {
+			visit(ref, path)
+		}
+		visited[key] = true
+	}
+
+	keys := make([]string, 0, len(graph))
+	for key := range graph {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		visit(key, nil)
+	}
+	return cycles
+}
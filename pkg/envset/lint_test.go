@@ -0,0 +1,114 @@
+package envset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func lintIssues(t *testing.T, issues []LintIssue) []string {
+	t.Helper()
+	out := make([]string, len(issues))
+	for i, issue := range issues {
+		out[i] = strings.TrimPrefix(issue.String(), filepath.Dir(issue.File)+string(filepath.Separator))
+	}
+	return out
+}
+
+func Test_Lint(t *testing.T) {
+	contents := "GLOBAL=1 \n" +
+		"[development]\n" +
+		"APP_NAME=my app\n" +
+		"URL=http://${HOST}:${PORT:-80}/${APP_NAME}\n" +
+		"A=${B}\n" +
+		"B=${A}\n" +
+		"USER_NAME=$(whoami)\n" +
+		"APP_NAME=\"my app\" # quoted\n" +
+		"1BAD=x\n" +
+		"\n" +
+		"[production]\n" +
+		"APP_NAME=prod\n" +
+		"HOST=localhost\n" +
+		"\n" +
+		"[qa : production]\n" +
+		"URL=http://${HOST}\n"
+
+	filename := writeSecretsFile(t, contents)
+	issues, err := Lint(LintOptions{
+		Filename:     filename,
+		Environments: []string{"development", "production"},
+		Rules:        map[string]bool{RuleMissingKey: false},
+	})
+	if err != nil {
+		t.Fatalf("lint: %v", err)
+	}
+
+	name := filepath.Base(filename)
+	want := []string{
+		name + ":1: trailing whitespace (trailing-whitespace)",
+		name + ":3: [development] APP_NAME: value with spaces should be quoted (unquoted-spaces)",
+		name + ":4: [development] URL: reference to undefined variable HOST (undefined-reference)",
+		name + ":5: [development] A: cyclic reference A -> B -> A (cyclic-reference)",
+		name + ":7: [development] USER_NAME: runs command $(whoami) (command-substitution)",
+		name + ":8: [development] APP_NAME: defined more than once, this value overrides line 3 (duplicate-key)",
+		name + ":9: [development] 1BAD: invalid variable name, use letters, digits and _ and don't start with a digit (invalid-name)",
+		name + ":15: [qa] section is not listed in .envsetrc [environments] (unknown-section)",
+	}
+	if got := lintIssues(t, issues); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func Test_LintMissingKeys(t *testing.T) {
+	contents := "[development]\nAPP_NAME=dev\nDEBUG=true\n\n[production]\nAPP_NAME=prod\n\n[staging : production]\nDEBUG=false\n"
+	filename := writeSecretsFile(t, contents)
+
+	issues, err := Lint(LintOptions{Filename: filename})
+	if err != nil {
+		t.Fatalf("lint: %v", err)
+	}
+
+	name := filepath.Base(filename)
+	want := []string{name + ":5: [production] DEBUG: not defined, found in development, staging (missing-key)"}
+	if got := lintIssues(t, issues); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func Test_LintDotenv(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".env")
+	contents := "export APP_NAME=envset\nAPP-NAME=dashed\nGREETING=hello world # comment\nQUOTED='hello world'\n"
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	issues, err := Lint(LintOptions{Filename: filename})
+	if err != nil {
+		t.Fatalf("lint: %v", err)
+	}
+
+	want := []string{
+		".env:2: [DEFAULT] APP-NAME: invalid variable name, use letters, digits and _ and don't start with a digit (invalid-name)",
+		".env:3: [DEFAULT] GREETING: value with spaces should be quoted (unquoted-spaces)",
+	}
+	if got := lintIssues(t, issues); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func Test_LintUnknownRule(t *testing.T) {
+	_, err := Lint(LintOptions{Filename: writeSecretsFile(t, "A=1\n"), Rules: map[string]bool{"tabs": false}})
+	if err == nil || !strings.Contains(err.Error(), `unknown lint rule "tabs"`) {
+		t.Fatalf("expected unknown rule error, got %v", err)
+	}
+}
+
+func Test_VarReferences(t *testing.T) {
+	got := varReferences("${A}-${B:-${C}}-${#D}-${E%.*}-$F")
+	want := []varReference{{name: "A"}, {name: "B", optional: true}, {name: "C"}, {name: "D"}, {name: "E"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+package envset
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const (
+	//FormatINI is the default env file format
+	FormatINI = "ini"
+	//FormatDotenv is used for .env files
+	FormatDotenv = "dotenv"
+	//FormatYAML is used for .yaml and .yml files
+	FormatYAML = "yaml"
+	//FormatTOML is used for .toml files
+	FormatTOML = "toml"
+	//FormatJSON is used for .json files
+	FormatJSON = "json"
+)
+
+// DetectFormat returns the format of an env file based on its
+// name, e.g. .env, .env.local or app.env are dotenv files and
+// .envset.yaml is a yaml file.
+func DetectFormat(filename string) string {
+	base := filepath.Base(filename)
+	switch filepath.Ext(base) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	case ".json":
+		return FormatJSON
+	case ".env":
+		return FormatDotenv
+	}
+
+	if base == ".env" || strings.HasPrefix(base, ".env.") {
+		return FormatDotenv
+	}
+	return FormatINI
+}
+
+// LoadFile will load an env file in the given format. If format
+// is empty the format is detected from the file name.
+func LoadFile(filename, format string, options ini.LoadOptions) (*ini.File, error) {
+	if format == "" {
+		format = DetectFormat(filename)
+	}
+
+	if format == FormatINI {
+		return ini.LoadSources(options, filename)
+	}
+
+	b, err := os.ReadFile(filename) // #nosec G304 -- envset intentionally reads user-provided env files.
+	if err != nil {
+		return nil, fmt.Errorf("read file %s: %w", filename, err)
+	}
+
+	switch format {
+	case FormatDotenv:
+		return parseDotenv(filename, b)
+	case FormatYAML:
+		return parseYAML(b, options.UnparseableSections)
+	case FormatTOML:
+		return parseTOML(b, options.UnparseableSections)
+	case FormatJSON:
+		return parseJSON(b, options.UnparseableSections)
+	default:
+		return nil, fmt.Errorf("unknown env file format %q", format)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"gopkg.in/ini.v1"
@@ -41,6 +42,7 @@ type EnvSection struct {
 	Name      string    `json:"name"`
 	Comment   string    `json:"comment,omitempty"`
 	Keys      []*EnvKey `json:"values"`
+	Ignored   []*EnvKey `json:"ignored,omitempty"` //keys skipped by CompareSections
 	secret    string
 	algorithm string
 	maxLength int
@@ -108,11 +110,15 @@ type EnvKey struct {
 	Value   string `json:"value,omitempty"`
 	Hash    string `json:"hash"`
 	Comment string `json:"comment,omitempty"`
+	//TargetHash and TargetValue are set in a diff when the
+	//target has a different value
+	TargetHash  string `json:"target_hash,omitempty"`
+	TargetValue string `json:"target_value,omitempty"`
 }
 
-// Load will load the ini file
+// Load will load the env file, the format is detected from the path
 func (e *EnvFile) Load(path string) error {
-	file, err := ini.Load(path)
+	file, err := LoadFile(path, "", ini.LoadOptions{})
 	if err != nil {
 		return fmt.Errorf("ini load: %w", err)
 	}
@@ -180,6 +186,7 @@ func (e *EnvFile) FromStdin() error {
 // MetadataOptions are the command options
 type MetadataOptions struct {
 	Name          string
+	Format        string
 	Filepath      string
 	Algorithm     string
 	Project       string
@@ -189,6 +196,12 @@ type MetadataOptions struct {
 	Print         bool
 	Values        bool
 	Secret        string
+	//Raw skips section inheritance, sections only have their own keys
+	Raw bool
+	//SecretKeys are name patterns of keys with secret values
+	SecretKeys []string
+	//Reveal adds secret values instead of redacting them
+	Reveal bool
 }
 
 // CreateMetadataFile will create or update metadata file
@@ -217,11 +230,16 @@ func CreateMetadataFile(o MetadataOptions) (EnvFile, error) {
 		secret:    o.Secret,
 	}
 
-	cfg, err := ini.Load(filename)
+	cfg, err := LoadFile(filename, o.Format, ini.LoadOptions{})
 	if err != nil {
 		return EnvFile{}, fmt.Errorf("ini load %s: %w", filename, err)
 	}
 
+	cfg, err = InheritSections(cfg, !o.Raw)
+	if err != nil {
+		return EnvFile{}, fmt.Errorf("inherit sections %s: %w", filename, err)
+	}
+
 	for _, sec := range cfg.Sections() {
 
 		secName := sec.Name()
@@ -240,6 +258,8 @@ func CreateMetadataFile(o MetadataOptions) (EnvFile, error) {
 			envSect.Comment = sec.Comment
 		}
 
+		secrets := secretKeys(sec, LoadIniSection(sec), o.SecretKeys)
+
 		//Go over section and add new EnvKeys
 		for _, k := range sec.KeyStrings() {
 			v := sec.Key(k).String()
@@ -247,6 +267,8 @@ func CreateMetadataFile(o MetadataOptions) (EnvFile, error) {
 
 			if !o.Values {
 				envKey.Value = ""
+			} else if secrets[k] && !o.Reveal && v != "" {
+				envKey.Value = RedactedValue
 			}
 
 			if err != nil {
@@ -272,19 +294,104 @@ func LoadMetadataFile(path string) (*EnvFile, error) {
 	return envFile, nil
 }
 
-// CompareMetadataFiles will compare two EnvFile instances
-func CompareMetadataFiles(a, b *EnvFile) (bool, error) {
+// MetadataDiff has the changes between two metadata files
+type MetadataDiff struct {
+	Filename        *ValueChange   `json:"envfile,omitempty"`
+	Project         *ValueChange   `json:"project,omitempty"`
+	SectionsAdded   []string       `json:"sections_added,omitempty"`
+	SectionsRemoved []string       `json:"sections_removed,omitempty"`
+	Sections        []*SectionDiff `json:"sections,omitempty"`
+}
+
+// ValueChange is a value that changed
+type ValueChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// SectionDiff has the changes of a section that is
+// in both metadata files
+type SectionDiff struct {
+	Name        string       `json:"name"`
+	Comment     *ValueChange `json:"comment,omitempty"`
+	KeysAdded   []string     `json:"keys_added,omitempty"`
+	KeysRemoved []string     `json:"keys_removed,omitempty"`
+	KeysChanged []string     `json:"keys_changed,omitempty"`
+	//KeyComments are keys with a different comment
+	KeyComments []string `json:"key_comments,omitempty"`
+}
+
+// IsEmpty will return true if the section has no changes
+func (s *SectionDiff) IsEmpty() bool {
+	return s.Comment == nil &&
+		len(s.KeysAdded) == 0 &&
+		len(s.KeysRemoved) == 0 &&
+		len(s.KeysChanged) == 0 &&
+		len(s.KeyComments) == 0
+}
+
+// IsEmpty will return true if the files have no changes
+func (d MetadataDiff) IsEmpty() bool {
+	return d.Filename == nil &&
+		d.Project == nil &&
+		len(d.SectionsAdded) == 0 &&
+		len(d.SectionsRemoved) == 0 &&
+		len(d.Sections) == 0
+}
+
+// String returns a report of the changes, one per line
+func (d MetadataDiff) String() string {
+	var b strings.Builder
+	if d.Filename != nil {
+		fmt.Fprintf(&b, "envfile: %q -> %q\n", d.Filename.From, d.Filename.To)
+	}
+	if d.Project != nil {
+		fmt.Fprintf(&b, "project: %q -> %q\n", d.Project.From, d.Project.To)
+	}
+	for _, name := range d.SectionsAdded {
+		fmt.Fprintf(&b, "+ [%s]\n", name)
+	}
+	for _, name := range d.SectionsRemoved {
+		fmt.Fprintf(&b, "- [%s]\n", name)
+	}
+	for _, s := range d.Sections {
+		fmt.Fprintf(&b, "~ [%s]\n", s.Name)
+		if s.Comment != nil {
+			fmt.Fprintf(&b, "    comment: %q -> %q\n", s.Comment.From, s.Comment.To)
+		}
+		for _, k := range s.KeysAdded {
+			fmt.Fprintf(&b, "    + %s\n", k)
+		}
+		for _, k := range s.KeysRemoved {
+			fmt.Fprintf(&b, "    - %s\n", k)
+		}
+		for _, k := range s.KeysChanged {
+			fmt.Fprintf(&b, "    ~ %s value changed\n", k)
+		}
+		for _, k := range s.KeyComments {
+			fmt.Fprintf(&b, "    ~ %s comment changed\n", k)
+		}
+	}
+	return b.String()
+}
+
+// CompareMetadataFiles will compare two EnvFile instances and return
+// the changes that turn b into a, e.g. sections added are in a but
+// not in b. The files are not modified.
+func CompareMetadataFiles(a, b *EnvFile) (MetadataDiff, error) {
 
 	if a.Algorithm != b.Algorithm {
-		return false, &ErrorWrongAlgorithm{source: a.Algorithm, target: b.Algorithm}
+		return MetadataDiff{}, &ErrorWrongAlgorithm{source: a.Algorithm, target: b.Algorithm}
 	}
 
-	if a.Filename != b.Filename || a.Project != b.Project {
-		return true, nil
+	diff := MetadataDiff{}
+
+	if a.Filename != b.Filename {
+		diff.Filename = &ValueChange{From: b.Filename, To: a.Filename}
 	}
 
-	if len(a.Sections) != len(b.Sections) {
-		return true, nil
+	if a.Project != b.Project {
+		diff.Project = &ValueChange{From: b.Project, To: a.Project}
 	}
 
 	sections := make(map[string]*EnvSection, len(b.Sections))
@@ -292,19 +399,119 @@ func CompareMetadataFiles(a, b *EnvFile) (bool, error) {
 		sections[sb.Name] = sb
 	}
 
+	seen := make(map[string]bool, len(a.Sections))
 	for _, sa := range a.Sections {
+		seen[sa.Name] = true
+
 		sb, ok := sections[sa.Name]
 		if !ok {
-			return true, nil
+			diff.SectionsAdded = append(diff.SectionsAdded, sa.Name)
+			continue
+		}
+
+		if sd := diffSection(sa, sb); !sd.IsEmpty() {
+			diff.Sections = append(diff.Sections, sd)
+		}
+	}
+
+	for _, sb := range b.Sections {
+		if !seen[sb.Name] {
+			diff.SectionsRemoved = append(diff.SectionsRemoved, sb.Name)
+		}
+	}
+
+	return diff, nil
+}
+
+// VerifyMetadataFile compares the metadata of the env file with the
+// metadata file in o.Filepath without writing it. If section is not
+// empty only that section is compared. Values are compared by hash
+// so it doesn't matter if the metadata file has values. Only sections
+// and keys are compared, comments are ignored and the project and
+// filename depend on the git remote and on how the env file path was typed.
+func VerifyMetadataFile(o MetadataOptions, section string) (MetadataDiff, error) {
+	o.Values = false
+
+	live, err := CreateMetadataFile(o)
+	if err != nil {
+		return MetadataDiff{}, err
+	}
+
+	stored, err := LoadMetadataFile(o.Filepath)
+	if err != nil {
+		return MetadataDiff{}, err
+	}
+
+	for _, f := range []*EnvFile{&live, stored} {
+		f.Project = ""
+		f.Filename = ""
+		if section != "" {
+			f.Sections = filterSections(f.Sections, section)
+		}
+		for _, s := range f.Sections {
+			s.Comment = ""
+			for _, k := range s.Keys {
+				k.Value = ""
+				k.Comment = ""
+			}
+		}
+	}
+
+	if section != "" && len(live.Sections) == 0 && len(stored.Sections) == 0 {
+		return MetadataDiff{}, fmt.Errorf("section [%s] not found in env file or metadata file", section)
+	}
+
+	return CompareMetadataFiles(&live, stored)
+}
+
+func filterSections(sections []*EnvSection, name string) []*EnvSection {
+	out := make([]*EnvSection, 0, 1)
+	for _, s := range sections {
+		if s.Name == name {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func diffSection(sa, sb *EnvSection) *SectionDiff {
+	sd := &SectionDiff{Name: sa.Name}
+
+	if sa.Comment != sb.Comment {
+		sd.Comment = &ValueChange{From: sb.Comment, To: sa.Comment}
+	}
+
+	keys := make(map[string]*EnvKey, len(sb.Keys))
+	for _, kb := range sb.Keys {
+		keys[kb.Name] = kb
+	}
+
+	seen := make(map[string]bool, len(sa.Keys))
+	for _, ka := range sa.Keys {
+		seen[ka.Name] = true
+
+		kb, ok := keys[ka.Name]
+		if !ok {
+			sd.KeysAdded = append(sd.KeysAdded, ka.Name)
+			continue
+		}
+
+		if ka.Hash != kb.Hash || ka.Value != kb.Value {
+			sd.KeysChanged = append(sd.KeysChanged, ka.Name)
+		}
+
+		if ka.Comment != kb.Comment {
+			sd.KeyComments = append(sd.KeyComments, ka.Name)
 		}
+	}
 
-		diff := CompareSections(*sa, *sb, []string{})
-		if !diff.IsEmpty() {
-			return true, nil
+	for _, kb := range sb.Keys {
+		if !seen[kb.Name] {
+			sd.KeysRemoved = append(sd.KeysRemoved, kb.Name)
 		}
 	}
 
-	return false, nil
+	return sd
 }
 
 func md5HashValue(value string) (string, error) {
@@ -328,51 +535,187 @@ func hmacSha256HashValue(value, secret string) (string, error) {
 	return sha, nil
 }
 
-// CompareSections will compare two sections and return diff
+// CompareSections will compare two sections and return diff.
+// Ignored keys are added to the Ignored keys of the diff with a
+// comment that says how they would have been reported.
 func CompareSections(s1, s2 EnvSection, ignored []string) EnvSection {
 	ignore := make(map[string]bool)
 	for _, v := range ignored {
 		ignore[v] = true
 	}
 
-	diff := EnvSection{}
+	diff := EnvSection{Keys: make([]*EnvKey, 0)}
 	seen := make(map[string]int)
 
 	for i, k1 := range s1.Keys {
-		if ok := ignore[k1.Name]; ok {
-			//TODO: diff.Ignored = append(diff.Ignored, k1)
-			continue
-		}
-
 		seen[k1.Name] = -1
+		status := "extra in source"
 		for _, k2 := range s2.Keys {
 			if k1.Name == k2.Name {
 				seen[k1.Name] = i + 1
+				status = SameValueComment
 				if k1.Hash != k2.Hash {
-					k1.Comment = "different hash value"
-					diff.Keys = append(diff.Keys, k1)
-					break
+					status = "different hash value"
 				}
+				break
 			}
 		}
 
-		if seen[k1.Name] == -1 {
-			k1.Comment = "extra in source"
+		if ok := ignore[k1.Name]; ok {
+			k1.Comment = status
+			diff.Ignored = append(diff.Ignored, k1)
+			continue
+		}
+
+		if status != SameValueComment {
+			k1.Comment = status
 			diff.Keys = append(diff.Keys, k1)
 		}
 	}
 
 	for _, k2 := range s2.Keys {
-		if ok := ignore[k2.Name]; ok {
-			//TODO: diff.Ignored = append(diff.Ignored, k2)
+		if _, ok := seen[k2.Name]; ok {
 			continue
 		}
 
-		if _, ok := seen[k2.Name]; !ok {
-			k2.Comment = "missing in source"
-			diff.Keys = append(diff.Keys, k2)
+		k2.Comment = "missing in source"
+		if ok := ignore[k2.Name]; ok {
+			diff.Ignored = append(diff.Ignored, k2)
+			continue
 		}
+		diff.Keys = append(diff.Keys, k2)
 	}
 
 	return diff
 }
+
+const (
+	//SectionOK the section has the same keys and values
+	SectionOK = "ok"
+	//SectionDifferent the section has different keys or values
+	SectionDifferent = "different"
+	//SectionMissingInSource the section is only in the target
+	SectionMissingInSource = "missing in source"
+	//SectionMissingInTarget the section is only in the source
+	SectionMissingInTarget = "missing in target"
+)
+
+// SectionComparison is the result of comparing a section
+// of two metadata files
+type SectionComparison struct {
+	Name            string    `json:"name"`
+	Status          string    `json:"status"`
+	MissingInSource int       `json:"missing_in_source"`
+	MissingInTarget int       `json:"missing_in_target"`
+	Different       int       `json:"different"`
+	Ignored         int       `json:"ignored"`
+	Keys            []*EnvKey `json:"values"`
+	IgnoredKeys     []*EnvKey `json:"ignored_values,omitempty"`
+}
+
+// MetadataComparison is the result of comparing all
+// the sections of two metadata files
+type MetadataComparison struct {
+	Sections []*SectionComparison `json:"sections"`
+}
+
+// IsEmpty will return true if all sections are ok
+func (m MetadataComparison) IsEmpty() bool {
+	return m.Different() == 0
+}
+
+// Different returns the number of sections that are
+// different or missing in either file
+func (m MetadataComparison) Different() int {
+	count := 0
+	for _, s := range m.Sections {
+		if s.Status != SectionOK {
+			count++
+		}
+	}
+	return count
+}
+
+// ToJSON returns a JSON representation of the comparison
+func (m MetadataComparison) ToJSON() (string, error) {
+	b, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return "", fmt.Errorf("comparison json marshall: %w", err)
+	}
+	return string(b), nil
+}
+
+// CompareAllSections compares every section present in either
+// metadata file. Sections of the source come first in the order
+// they are defined followed by sections only in the target.
+// ignored returns the keys ignored in a section, it can be nil.
+func CompareAllSections(source, target *EnvFile, ignored func(section string) []string) (MetadataComparison, error) {
+	if source.Algorithm != target.Algorithm {
+		return MetadataComparison{}, &ErrorWrongAlgorithm{source: source.Algorithm, target: target.Algorithm}
+	}
+
+	if ignored == nil {
+		ignored = func(string) []string { return nil }
+	}
+
+	res := MetadataComparison{Sections: make([]*SectionComparison, 0)}
+	seen := make(map[string]bool)
+
+	for _, s1 := range source.Sections {
+		seen[s1.Name] = true
+
+		s2, err := target.GetSection(s1.Name)
+		if err != nil {
+			res.Sections = append(res.Sections, &SectionComparison{
+				Name:   s1.Name,
+				Status: SectionMissingInTarget,
+				Keys:   make([]*EnvKey, 0),
+			})
+			continue
+		}
+
+		res.Sections = append(res.Sections, compareSection(*s1, *s2, ignored(s1.Name)))
+	}
+
+	for _, s2 := range target.Sections {
+		if seen[s2.Name] {
+			continue
+		}
+		res.Sections = append(res.Sections, &SectionComparison{
+			Name:   s2.Name,
+			Status: SectionMissingInSource,
+			Keys:   make([]*EnvKey, 0),
+		})
+	}
+
+	return res, nil
+}
+
+func compareSection(s1, s2 EnvSection, ignored []string) *SectionComparison {
+	diff := CompareSections(s1, s2, ignored)
+
+	res := &SectionComparison{
+		Name:        s1.Name,
+		Status:      SectionOK,
+		Ignored:     len(diff.Ignored),
+		Keys:        make([]*EnvKey, 0, len(diff.Keys)),
+		IgnoredKeys: diff.Ignored,
+	}
+
+	for _, k := range diff.Keys {
+		switch k.Comment {
+		case "missing in source":
+			res.MissingInSource++
+		case "extra in source":
+			res.MissingInTarget++
+		case "different hash value":
+			res.Different++
+		}
+		res.Keys = append(res.Keys, k)
+	}
+
+	if !diff.IsEmpty() {
+		res.Status = SectionDifferent
+	}
+	return res
+}
@@ -0,0 +1,178 @@
+package envset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	//OutputBash prints export K='v' lines
+	OutputBash = "bash"
+	//OutputZsh prints export K='v' lines
+	OutputZsh = "zsh"
+	//OutputFish prints set -gx K 'v' lines
+	OutputFish = "fish"
+	//OutputDotenv prints a .env file
+	OutputDotenv = "dotenv"
+	//OutputJSON prints a JSON object
+	OutputJSON = "json"
+	//OutputDocker prints a file for docker run --env-file
+	OutputDocker = "docker"
+	//OutputSystemd prints a systemd EnvironmentFile
+	OutputSystemd = "systemd"
+	//OutputGitHub prints $GITHUB_ENV heredoc entries
+	OutputGitHub = "github"
+)
+
+// OutputFormats are the formats supported by WriteEnv
+var OutputFormats = []string{
+	OutputBash,
+	OutputZsh,
+	OutputFish,
+	OutputDotenv,
+	OutputJSON,
+	OutputDocker,
+	OutputSystemd,
+	OutputGitHub,
+}
+
+// WriteEnv writes the environment to w in the given format with
+// keys sorted. If format is empty we write KEY=value lines quoted
+// so they can be used by a POSIX shell.
+func WriteEnv(w io.Writer, env EnvMap, format string) error {
+	if format == OutputJSON {
+		b, err := json.MarshalIndent(env, "", "  ")
+		if err != nil {
+			return fmt.Errorf("json marshal: %w", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	}
+
+	for _, key := range sortedEnvKeys(env) {
+		line, err := formatEnvLine(key, env[key], format)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return fmt.Errorf("write %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func formatEnvLine(key, value, format string) (string, error) {
+	switch format {
+	case "":
+		if err := checkShellName(key, "shell"); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s=%s", key, quotePOSIX(value)), nil
+	case OutputBash, OutputZsh:
+		if err := checkShellName(key, format); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("export %s=%s", key, singleQuotePOSIX(value)), nil
+	case OutputFish:
+		if err := checkShellName(key, format); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("set -gx %s %s", key, quoteFish(value)), nil
+	case OutputDotenv:
+		return fmt.Sprintf("%s=%s", key, quoteDotenv(value)), nil
+	case OutputDocker:
+		//docker reads values literally and has no multi-line support
+		if strings.ContainsAny(value, "\r\n") {
+			return "", fmt.Errorf("docker env files can't have multi-line values: %s", key)
+		}
+		return fmt.Sprintf("%s=%s", key, value), nil
+	case OutputSystemd:
+		if err := checkShellName(key, format); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s=%s", key, quoteSystemd(value)), nil
+	case OutputGitHub:
+		delimiter := githubDelimiter(value)
+		return fmt.Sprintf("%s<<%s\n%s\n%s", key, delimiter, value, delimiter), nil
+	default:
+		return "", fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(OutputFormats, ", "))
+	}
+}
+
+func checkShellName(key, format string) error {
+	if !isVarName(key) {
+		return fmt.Errorf("invalid variable name %q for %s output", key, format)
+	}
+	return nil
+}
+
+// quotePOSIX only quotes values with characters that
+// have a special meaning in a POSIX shell.
+func quotePOSIX(value string) string {
+	if value != "" && !strings.ContainsFunc(value, isShellSpecial) {
+		return value
+	}
+	return singleQuotePOSIX(value)
+}
+
+func isShellSpecial(ch rune) bool {
+	return !(ch == '_' || ch == '-' || ch == '.' || ch == '/' || ch == ':' || ch == '@' || ch == ',' || ch == '+' || ch == '=' ||
+		(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9'))
+}
+
+// singleQuotePOSIX quotes the value in single quotes, nothing is
+// expanded inside of them so we only need to escape single quotes.
+func singleQuotePOSIX(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// quoteFish quotes the value in single quotes, fish handles
+// \\ and \' escapes inside of them.
+func quoteFish(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(value) + "'"
+}
+
+// quoteDotenv uses single quotes for literal values and double
+// quotes with escapes for values with new lines or single quotes.
+func quoteDotenv(value string) string {
+	if value != "" && !strings.ContainsFunc(value, isShellSpecial) {
+		return value
+	}
+
+	if !strings.ContainsAny(value, "'\r\n") {
+		return "'" + value + "'"
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// quoteSystemd uses double quotes, systemd keeps new lines in
+// quoted values and handles shell like backslash escapes.
+func quoteSystemd(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// githubDelimiter returns a heredoc delimiter that is not
+// a line of the value.
+func githubDelimiter(value string) string {
+	delimiter := "ENVSET_EOF"
+	lines := strings.Split(value, "\n")
+	for containsLine(lines, delimiter) {
+		delimiter += "_"
+	}
+	return delimiter
+}
+
+func containsLine(lines []string, line string) bool {
+	for _, l := range lines {
+		if strings.TrimRight(l, "\r") == line {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package envset
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+var outputFixture = EnvMap{
+	"PLAIN":  "value",
+	"QUOTED": `it's "quoted" $HOME ` + "`whoami`",
+	"MULTI":  "line 1\nline 2",
+}
+
+func Test_WriteEnv(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "",
+			want:   "MULTI='line 1\nline 2'\nPLAIN=value\nQUOTED='it'\\''s \"quoted\" $HOME `whoami`'\n",
+		},
+		{
+			format: OutputBash,
+			want:   "export MULTI='line 1\nline 2'\nexport PLAIN='value'\nexport QUOTED='it'\\''s \"quoted\" $HOME `whoami`'\n",
+		},
+		{
+			format: OutputFish,
+			want:   "set -gx MULTI 'line 1\nline 2'\nset -gx PLAIN 'value'\nset -gx QUOTED 'it\\'s \"quoted\" $HOME `whoami`'\n",
+		},
+		{
+			format: OutputDotenv,
+			want:   "MULTI=\"line 1\\nline 2\"\nPLAIN=value\nQUOTED=\"it's \\\"quoted\\\" $HOME `whoami`\"\n",
+		},
+		{
+			format: OutputSystemd,
+			want:   "MULTI=\"line 1\nline 2\"\nPLAIN=\"value\"\nQUOTED=\"it's \\\"quoted\\\" \\$HOME \\`whoami\\`\"\n",
+		},
+		{
+			format: OutputGitHub,
+			want:   "MULTI<<ENVSET_EOF\nline 1\nline 2\nENVSET_EOF\nPLAIN<<ENVSET_EOF\nvalue\nENVSET_EOF\nQUOTED<<ENVSET_EOF\nit's \"quoted\" $HOME `whoami`\nENVSET_EOF\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := WriteEnv(&out, outputFixture, tt.format); err != nil {
+				t.Fatalf("write env: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Fatalf("output:\n%s\nwant:\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_WriteEnv_JSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteEnv(&out, outputFixture, OutputJSON); err != nil {
+		t.Fatalf("write env: %v", err)
+	}
+
+	got := EnvMap{}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("json unmarshal: %v", err)
+	}
+	for k, v := range outputFixture {
+		if got[k] != v {
+			t.Fatalf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+
+	if strings.Index(out.String(), "MULTI") > strings.Index(out.String(), "PLAIN") {
+		t.Fatalf("expected sorted keys: %s", out.String())
+	}
+}
+
+func Test_WriteEnv_Errors(t *testing.T) {
+	if err := WriteEnv(&bytes.Buffer{}, EnvMap{"MULTI": "a\nb"}, OutputDocker); err == nil {
+		t.Fatal("expected docker error for multi-line value")
+	}
+
+	if err := WriteEnv(&bytes.Buffer{}, EnvMap{"app.name": "envset"}, OutputBash); err == nil {
+		t.Fatal("expected error for invalid variable name")
+	}
+
+	if err := WriteEnv(&bytes.Buffer{}, EnvMap{"A": "1"}, "yaml"); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
+
+func Test_WriteEnv_ShellRoundTrip(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteEnv(&out, outputFixture, OutputBash); err != nil {
+		t.Fatalf("write env: %v", err)
+	}
+
+	for key, want := range outputFixture {
+		script := out.String() + "\nprintf '%s' \"$" + key + "\""
+		got, err := exec.Command("sh", "-c", script).Output()
+		if err != nil {
+			t.Fatalf("sh: %v", err)
+		}
+		if string(got) != want {
+			t.Fatalf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func Test_WriteEnv_DotenvRoundTrip(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteEnv(&out, outputFixture, OutputDotenv); err != nil {
+		t.Fatalf("write env: %v", err)
+	}
+
+	got, err := LoadDotenv(out.Bytes())
+	if err != nil {
+		t.Fatalf("load dotenv: %v", err)
+	}
+	for k, v := range outputFixture {
+		if got[k] != v {
+			t.Fatalf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
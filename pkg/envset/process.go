@@ -0,0 +1,138 @@
+package envset
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// exitCodeSignalBase is added to the signal number to build the
+// exit code of a command terminated by a signal, same as a shell.
+const exitCodeSignalBase = 128
+
+// DefaultStopTimeout is how long we wait for the command to
+// exit before we kill it when we need to restart it.
+const DefaultStopTimeout = 10 * time.Second
+
+// errEnvironmentChanged is returned when the command was stopped
+// because the environment changed and it should be started again.
+var errEnvironmentChanged = errors.New("environment changed")
+
+// errCommandInterrupted is returned when the command exits cleanly
+// after envset forwarded a termination signal to it.
+var errCommandInterrupted = errors.New("command interrupted")
+
+// runCommand starts the command and waits for it to exit while
+// forwarding any signal envset receives. If the options ProcessGroup
+// is true the command runs in its own process group and signals are
+// sent to the whole group. If changed is notified the command is
+// stopped and errEnvironmentChanged returned. If options.Stop is
+// closed the command is stopped as if envset got a SIGTERM.
+func runCommand(command *exec.Cmd, options RunOptions, changed <-chan struct{}) error {
+	if options.ProcessGroup {
+		setProcessGroup(command)
+	}
+
+	signals := make(chan os.Signal, len(forwardedSignals))
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+
+	if err := command.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- command.Wait()
+	}()
+
+	interrupted := false
+	stopping := false
+	stop := options.Stop
+	var kill <-chan time.Time
+
+	for {
+		select {
+		case sig := <-signals:
+			if isTerminationSignal(sig) {
+				interrupted = true
+			}
+			forwardSignal(command.Process, sig, options.ProcessGroup)
+		case <-stop:
+			stop = nil
+			interrupted = true
+			forwardSignal(command.Process, syscall.SIGTERM, options.ProcessGroup)
+			kill = time.After(stopTimeout(options))
+		case <-changed:
+			changed = nil
+			stopping = true
+			forwardSignal(command.Process, syscall.SIGTERM, options.ProcessGroup)
+			kill = time.After(stopTimeout(options))
+		case <-kill:
+			forwardSignal(command.Process, syscall.SIGKILL, options.ProcessGroup)
+		case err := <-done:
+			if stopping && !interrupted {
+				return errEnvironmentChanged
+			}
+			if err == nil && interrupted {
+				return errCommandInterrupted
+			}
+			return commandExitError(err, interrupted)
+		}
+	}
+}
+
+// commandExited returns true if err is the result of a command
+// that ran and exited on its own, not because it was interrupted.
+func commandExited(err error) bool {
+	if err == nil {
+		return true
+	}
+	var exitErr ErrorCommandExit
+	return errors.As(err, &exitErr) && !exitErr.interrupted
+}
+
+func stopTimeout(options RunOptions) time.Duration {
+	if options.StopTimeout > 0 {
+		return options.StopTimeout
+	}
+	return DefaultStopTimeout
+}
+
+func forwardSignal(process *os.Process, sig os.Signal, processGroup bool) {
+	if err := signalCommand(process, sig, processGroup); err != nil {
+		fmt.Fprintf(os.Stderr, "envset: forward signal %s: %s\n", sig, err)
+	}
+}
+
+func isTerminationSignal(sig os.Signal) bool {
+	return sig == syscall.SIGINT || sig == syscall.SIGTERM || sig == syscall.SIGQUIT
+}
+
+func commandExitError(err error, interrupted bool) error {
+	if err == nil {
+		return nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+
+	e := ErrorCommandExit{
+		Code:        exitErr.ExitCode(),
+		interrupted: interrupted,
+		err:         err,
+	}
+
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		e.Signal = status.Signal()
+		e.Code = exitCodeSignalBase + int(status.Signal())
+	}
+
+	return e
+}
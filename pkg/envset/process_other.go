@@ -0,0 +1,36 @@
+//go:build !unix
+
+package envset
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// forwardedSignals are relayed from envset to the running command.
+// The command gets console interrupts directly, we only handle
+// termination.
+var forwardedSignals = []os.Signal{
+	syscall.SIGTERM,
+}
+
+// setProcessGroup is a no-op, process groups are not supported
+func setProcessGroup(_ *exec.Cmd) {}
+
+// signalCommand kills the command for termination signals, other
+// platforms can't deliver signals to a process.
+func signalCommand(process *os.Process, sig os.Signal, _ bool) error {
+	var err error
+	if sig == syscall.SIGTERM || sig == syscall.SIGKILL {
+		err = process.Kill()
+	} else {
+		err = process.Signal(sig)
+	}
+
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package envset
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// forwardedSignals are relayed from envset to the running command.
+var forwardedSignals = []os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
+// setProcessGroup runs the command in its own process group
+func setProcessGroup(command *exec.Cmd) {
+	command.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalCommand sends sig to the command, or to its whole
+// process group if processGroup is true.
+func signalCommand(process *os.Process, sig os.Signal, processGroup bool) error {
+	var err error
+	if s, ok := sig.(syscall.Signal); ok && processGroup {
+		err = syscall.Kill(-process.Pid, s)
+	} else {
+		err = process.Signal(sig)
+	}
+
+	if errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+	return err
+}
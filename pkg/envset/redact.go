@@ -0,0 +1,206 @@
+package envset
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+const (
+	// SecretAnnotation in the comment of a key marks it as secret, e.g.
+	//	# @secret
+	//	API_KEY=abc
+	SecretAnnotation = "@secret"
+	// RedactedValue replaces secret values in the output
+	RedactedValue = "****"
+	// minMaskLength is the shortest value we mask in the command output,
+	// masking shorter values would mangle unrelated output.
+	minMaskLength = 4
+)
+
+// IsSecretKey returns true if the key name matches any of the
+// patterns, e.g. *_TOKEN. Names are matched case insensitive.
+func IsSecretKey(name string, patterns []string) bool {
+	name = strings.ToUpper(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToUpper(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSecretAnnotation checks if a key comment has the @secret annotation
+func hasSecretAnnotation(comment string) bool {
+	for _, field := range strings.Fields(comment) {
+		if strings.TrimLeft(field, "#;") == SecretAnnotation {
+			return true
+		}
+	}
+	return false
+}
+
+// markSecret adds the @secret annotation to a key comment
+func markSecret(comment string) string {
+	if hasSecretAnnotation(comment) {
+		return comment
+	}
+	if comment == "" {
+		return "# " + SecretAnnotation
+	}
+	return comment + "\n# " + SecretAnnotation
+}
+
+// secretKeys returns the keys of the context that should be redacted:
+// keys matching the secret patterns, keys annotated with @secret and
+// keys with encrypted values or secret references in the env file.
+func secretKeys(sec *ini.Section, context EnvMap, patterns []string) map[string]bool {
+	secrets := make(map[string]bool)
+	for k := range context {
+		if IsSecretKey(k, patterns) {
+			secrets[k] = true
+		}
+	}
+
+	if sec == nil {
+		return secrets
+	}
+
+	for _, key := range sec.Keys() {
+		if _, ok := context[key.Name()]; !ok {
+			continue
+		}
+
+		_, ref := ParseSecretRef(key.Value())
+		if ref || IsEncrypted(key.Value()) || hasSecretAnnotation(key.Comment) {
+			secrets[key.Name()] = true
+		}
+	}
+	return secrets
+}
+
+// RedactEnv returns a copy of env with the values of the
+// secret keys replaced by RedactedValue. Secret values found
+// in other values, e.g. a password expanded into a URL, are
+// replaced as well.
+func RedactEnv(env EnvMap, secrets map[string]bool) EnvMap {
+	values := secretValues(env, secrets)
+	pairs := make([]string, 0, len(values)*2)
+	for _, s := range values {
+		pairs = append(pairs, s, RedactedValue)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	out := make(EnvMap, len(env))
+	for k, v := range env {
+		if secrets[k] && v != "" {
+			v = RedactedValue
+		} else {
+			v = replacer.Replace(v)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// redactedKeys returns the sorted keys RedactEnv changed
+func redactedKeys(env, redacted EnvMap) []string {
+	keys := []string{}
+	for _, k := range sortedEnvKeys(env) {
+		if env[k] != redacted[k] {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// secretValues returns the values of the secret keys we
+// can mask in the output, longest first.
+func secretValues(env EnvMap, secrets map[string]bool) []string {
+	seen := make(map[string]bool)
+	values := make([]string, 0, len(secrets))
+	for k := range secrets {
+		v := env[k]
+		if len(v) < minMaskLength || seen[v] {
+			continue
+		}
+		seen[v] = true
+		values = append(values, v)
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	return values
+}
+
+// maskWriter replaces secret values written to w with RedactedValue.
+// A value can be split across writes so we hold back the end of
+// the output that could be the start of a secret until the next
+// write or Flush.
+type maskWriter struct {
+	w        io.Writer
+	secrets  [][]byte
+	replacer *strings.Replacer
+	buf      []byte
+}
+
+func newMaskWriter(w io.Writer, secrets []string) *maskWriter {
+	pairs := make([]string, 0, len(secrets)*2)
+	values := make([][]byte, 0, len(secrets))
+	for _, s := range secrets {
+		pairs = append(pairs, s, RedactedValue)
+		values = append(values, []byte(s))
+	}
+
+	return &maskWriter{
+		w:        w,
+		secrets:  values,
+		replacer: strings.NewReplacer(pairs...),
+	}
+}
+
+// Write masks secrets in p, it always consumes all of p
+func (m *maskWriter) Write(p []byte) (int, error) {
+	m.buf = append(m.buf, p...)
+	m.buf = []byte(m.replacer.Replace(string(m.buf)))
+
+	hold := m.partialSecret()
+	if _, err := m.w.Write(m.buf[:len(m.buf)-hold]); err != nil {
+		return 0, err
+	}
+	m.buf = append(m.buf[:0], m.buf[len(m.buf)-hold:]...)
+	return len(p), nil
+}
+
+// Flush writes any output we held back
+func (m *maskWriter) Flush() error {
+	if len(m.buf) == 0 {
+		return nil
+	}
+	_, err := m.w.Write(m.buf)
+	m.buf = m.buf[:0]
+	return err
+}
+
+// partialSecret returns the length of the longest suffix
+// of the buffer that is the start of a secret
+func (m *maskWriter) partialSecret() int {
+	hold := 0
+	for _, s := range m.secrets {
+		for n := min(len(s)-1, len(m.buf)); n > hold; n-- {
+			if bytes.HasPrefix(s, m.buf[len(m.buf)-n:]) {
+				hold = n
+				break
+			}
+		}
+	}
+	return hold
+}
@@ -0,0 +1,186 @@
+package envset
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+func Test_IsSecretKey(t *testing.T) {
+	patterns := []string{"*_SECRET", "*_TOKEN", "DB_PASS*"}
+	tests := map[string]bool{
+		"APP_SECRET":   true,
+		"github_token": true,
+		"DB_PASSWORD":  true,
+		"TOKEN":        false,
+		"APP_NAME":     false,
+		"SECRET_NAME":  false,
+	}
+	for name, want := range tests {
+		if got := IsSecretKey(name, patterns); got != want {
+			t.Fatalf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+func Test_SecretKeys(t *testing.T) {
+	c, _ := NewCipher([]byte("secret"))
+	token, _ := c.Encrypt("production", "CERT", "value")
+
+	file, err := ini.Load([]byte("[production]\n" +
+		"# @secret\nAPI_KEY=abc\n" +
+		"LICENSE=xyz ; license @secret\n" +
+		"DB_PASS=ref+env://DB_PASS\n" +
+		"CERT=" + token + "\n" +
+		"APP_TOKEN=t0k3n\n" +
+		"# not a @secretive comment\nAPP_NAME=envset\n"))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	sec := file.Section("production")
+	context := LoadIniSection(sec)
+	context["SHELL_TOKEN"] = "shell"
+
+	got := secretKeys(sec, context, []string{"*_TOKEN"})
+	want := map[string]bool{
+		"API_KEY":     true,
+		"LICENSE":     true,
+		"DB_PASS":     true,
+		"CERT":        true,
+		"APP_TOKEN":   true,
+		"SHELL_TOKEN": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	redacted := RedactEnv(EnvMap{"API_KEY": "abc", "APP_NAME": "envset", "APP_TOKEN": ""}, got)
+	if !reflect.DeepEqual(redacted, EnvMap{"API_KEY": RedactedValue, "APP_NAME": "envset", "APP_TOKEN": ""}) {
+		t.Fatalf("unexpected redacted env %v", redacted)
+	}
+}
+
+func Test_RedactEnv_ExpandedValues(t *testing.T) {
+	env := EnvMap{"DB_PASSWORD": "hunter2", "DB_URL": "postgres://app:hunter2@db/app", "APP_NAME": "envset"}
+	redacted := RedactEnv(env, map[string]bool{"DB_PASSWORD": true})
+
+	want := EnvMap{"DB_PASSWORD": RedactedValue, "DB_URL": "postgres://app:****@db/app", "APP_NAME": "envset"}
+	if !reflect.DeepEqual(redacted, want) {
+		t.Fatalf("got %v, want %v", redacted, want)
+	}
+	if keys := redactedKeys(env, redacted); !reflect.DeepEqual(keys, []string{"DB_PASSWORD", "DB_URL"}) {
+		t.Fatalf("unexpected redacted keys %v", keys)
+	}
+}
+
+func Test_Print_SecretsNeedReveal(t *testing.T) {
+	filename := writeSecretsFile(t, "[production]\nAPP_NAME=envset\n# @secret\nDB_PASSWORD=hunter2\n")
+
+	for _, format := range []string{"", OutputBash, OutputDotenv, OutputGitHub} {
+		o := RunOptions{Filename: filename, ExportEnvName: "APP_ENV", Isolated: true, PrintFormat: format}
+		err := Print("production", o)
+		if err == nil || !strings.Contains(err.Error(), "--reveal") || !strings.Contains(err.Error(), "DB_PASSWORD") {
+			t.Fatalf("format %q: expected reveal error, got %v", format, err)
+		}
+	}
+}
+
+func Test_MaskWriter(t *testing.T) {
+	secrets := secretValues(EnvMap{
+		"TOKEN":    "s3cr3t-t0k3n",
+		"PASSWORD": "hunter2",
+		"SHORT":    "abc",
+		"APP_NAME": "envset",
+	}, map[string]bool{"TOKEN": true, "PASSWORD": true, "SHORT": true})
+
+	if !reflect.DeepEqual(secrets, []string{"s3cr3t-t0k3n", "hunter2"}) {
+		t.Fatalf("unexpected secret values %q", secrets)
+	}
+
+	input := "token=s3cr3t-t0k3n password=hunter2 abc envset hunt s3cr3t"
+	for size := 1; size <= len(input); size++ {
+		var out bytes.Buffer
+		w := newMaskWriter(&out, secrets)
+		for i := 0; i < len(input); i += size {
+			chunk := input[i:min(i+size, len(input))]
+			if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+				t.Fatalf("write %q: %d %v", chunk, n, err)
+			}
+			if strings.Contains(out.String(), "hunter") || strings.Contains(out.String(), "t0k3n") {
+				t.Fatalf("chunk size %d: leaked secret in %q", size, out.String())
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("flush: %v", err)
+		}
+
+		want := "token=**** password=**** abc envset hunt s3cr3t"
+		if out.String() != want {
+			t.Fatalf("chunk size %d: got %q, want %q", size, out.String(), want)
+		}
+	}
+}
+
+func Test_CreateMetadataFile_RedactsValues(t *testing.T) {
+	filename := writeSecretsFile(t, "[production]\nAPP_NAME=envset\nAPI_TOKEN=t0k3n\n# @secret\nLICENSE=xyz\n")
+
+	values := func(reveal bool) map[string]string {
+		env, err := CreateMetadataFile(MetadataOptions{
+			Name:       filename,
+			Algorithm:  HashSHA256,
+			Values:     true,
+			SecretKeys: []string{"*_TOKEN"},
+			Reveal:     reveal,
+		})
+		if err != nil {
+			t.Fatalf("create metadata: %v", err)
+		}
+
+		sec, _ := env.GetSection("production")
+		out := make(map[string]string)
+		for _, k := range sec.Keys {
+			out[k.Name] = k.Value
+			if h, _ := sha256Hashvalue(map[string]string{"APP_NAME": "envset", "API_TOKEN": "t0k3n", "LICENSE": "xyz"}[k.Name]); h[:50] != k.Hash {
+				t.Fatalf("%s: hash should use the secret value", k.Name)
+			}
+		}
+		return out
+	}
+
+	want := map[string]string{"APP_NAME": "envset", "API_TOKEN": RedactedValue, "LICENSE": RedactedValue}
+	if got := values(false); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	want = map[string]string{"APP_NAME": "envset", "API_TOKEN": "t0k3n", "LICENSE": "xyz"}
+	if got := values(true); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func Test_LoadEnvironmentSecrets(t *testing.T) {
+	c, _ := NewCipher([]byte("secret"))
+	section, _ := c.Encrypt("production", EncryptedKey, "TOKEN=abc\n")
+
+	filename := writeSecretsFile(t, "[production]\n"+
+		EncryptedKey+"="+section+"\n"+
+		"APP_NAME=envset\n"+
+		"DB_PASSWORD=hunter2\n")
+
+	o := RunOptions{Filename: filename, ExportEnvName: "APP_ENV", Passphrase: "secret", SecretKeys: []string{"*_PASSWORD"}}
+	env, secrets, err := loadEnvironmentSecrets("production", o)
+	if err != nil {
+		t.Fatalf("load environment: %v", err)
+	}
+
+	if !reflect.DeepEqual(secrets, map[string]bool{"TOKEN": true, "DB_PASSWORD": true}) {
+		t.Fatalf("unexpected secrets %v", secrets)
+	}
+	if env["TOKEN"] != "abc" {
+		t.Fatalf("got %q", env["TOKEN"])
+	}
+}
@@ -0,0 +1,113 @@
+package envset
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// RestartPolicy configures how a failed command is restarted
+type RestartPolicy struct {
+	// Delay before the first restart. Each consecutive
+	// restart doubles the previous delay.
+	Delay time.Duration
+	// MaxDelay caps the restart delay, zero means no cap.
+	MaxDelay time.Duration
+	// Jitter is a factor between 0 and 1 used to randomize
+	// the delay, e.g. 0.2 will add or remove up to 20%.
+	Jitter float64
+	// ResetAfter resets the restart count and delay if the
+	// command was running for at least this long before failing.
+	ResetAfter time.Duration
+	// ExitCodes if not empty only these exit codes trigger a restart.
+	ExitCodes []int
+	// IgnoreExitCodes are exit codes that never trigger a restart.
+	IgnoreExitCodes []int
+}
+
+// Backoff returns how long to wait before the given restart attempt,
+// attempts start at 1.
+func (p RestartPolicy) Backoff(attempt int) time.Duration {
+	delay := p.Delay
+	for i := 1; i < attempt && delay < math.MaxInt64/2; i++ {
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 && delay > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		// #nosec G404 -- jitter does not need a secure random source.
+		delta := (rand.Float64()*2 - 1) * jitter * float64(delay)
+		delay += time.Duration(delta)
+	}
+
+	return delay
+}
+
+// ShouldRestart returns true if a command exiting with the given
+// exit code should be restarted.
+func (p RestartPolicy) ShouldRestart(code int) bool {
+	if slices.Contains(p.IgnoreExitCodes, code) {
+		return false
+	}
+
+	if len(p.ExitCodes) > 0 {
+		return slices.Contains(p.ExitCodes, code)
+	}
+
+	return true
+}
+
+func (p RestartPolicy) healthy(uptime time.Duration) bool {
+	return p.ResetAfter > 0 && uptime >= p.ResetAfter
+}
+
+// shouldRestart will only restart failures of the executed
+// command, errors loading the environment are returned.
+func (o RunOptions) shouldRestart(err error, restarts int) bool {
+	if !o.Restart || restarts >= o.MaxRestarts {
+		return false
+	}
+
+	var exitErr ErrorCommandExit
+	if !errors.As(err, &exitErr) || exitErr.interrupted {
+		return false
+	}
+
+	return o.RestartPolicy.ShouldRestart(exitErr.Code)
+}
+
+// waitRestart waits for the given delay and returns false
+// if envset is asked to terminate or stop is closed in the meantime.
+func waitRestart(delay time.Duration, stop <-chan struct{}) bool {
+	if delay <= 0 {
+		return true
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-signals:
+		return false
+	case <-stop:
+		return false
+	}
+}
@@ -0,0 +1,132 @@
+package envset
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_RestartPolicyBackoff(t *testing.T) {
+	policy := RestartPolicy{
+		Delay:    100 * time.Millisecond,
+		MaxDelay: time.Second,
+	}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 100 * time.Millisecond},
+		{attempt: 2, want: 200 * time.Millisecond},
+		{attempt: 4, want: 800 * time.Millisecond},
+		{attempt: 5, want: time.Second},
+		{attempt: 1000, want: time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func Test_RestartPolicyBackoffJitter(t *testing.T) {
+	policy := RestartPolicy{Delay: time.Second, Jitter: 0.5}
+
+	for range 100 {
+		got := policy.Backoff(1)
+		if got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("Backoff(1) = %s, want between 500ms and 1.5s", got)
+		}
+	}
+}
+
+func Test_RestartPolicyShouldRestart(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RestartPolicy
+		code   int
+		want   bool
+	}{
+		{name: "any code", policy: RestartPolicy{}, code: 1, want: true},
+		{name: "listed code", policy: RestartPolicy{ExitCodes: []int{1, 2}}, code: 2, want: true},
+		{name: "not listed code", policy: RestartPolicy{ExitCodes: []int{1, 2}}, code: 3, want: false},
+		{name: "ignored code", policy: RestartPolicy{IgnoreExitCodes: []int{3}}, code: 3, want: false},
+		{name: "ignored wins", policy: RestartPolicy{ExitCodes: []int{3}, IgnoreExitCodes: []int{3}}, code: 3, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.ShouldRestart(tt.code); got != tt.want {
+				t.Fatalf("ShouldRestart(%d) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Run_DoesNotRestartOnConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".envset")
+	if err := os.WriteFile(envFile, []byte("[development]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	countFile := filepath.Join(dir, "runs")
+	err := Run("development", RunOptions{
+		Filename:      envFile,
+		Cmd:           "sh",
+		Args:          []string{"-c", "printf x >> \"$1\"", "sh", countFile},
+		Isolated:      true,
+		ExportEnvName: "APP_ENV",
+		Required:      []string{"MISSING"},
+		Restart:       true,
+		MaxRestarts:   3,
+		RestartPolicy: RestartPolicy{Delay: time.Hour},
+	})
+	if err == nil {
+		t.Fatal("expected missing required key error")
+	}
+	if _, err := os.Stat(countFile); !os.IsNotExist(err) {
+		t.Fatalf("command should not run, stat err = %v", err)
+	}
+}
+
+func Test_Run_RestartPolicyExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".envset")
+	if err := os.WriteFile(envFile, []byte("[development]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	countFile := filepath.Join(dir, "runs")
+	options := RunOptions{
+		Filename:      envFile,
+		Cmd:           "sh",
+		Args:          []string{"-c", "printf x >> \"$1\"; exit 2", "sh", countFile},
+		Isolated:      true,
+		ExportEnvName: "APP_ENV",
+		Restart:       true,
+		MaxRestarts:   2,
+		RestartPolicy: RestartPolicy{
+			Delay:           time.Millisecond,
+			IgnoreExitCodes: []int{2},
+		},
+	}
+
+	if err := Run("development", options); err == nil {
+		t.Fatal("expected command failure")
+	}
+	assertFileSize(t, countFile, 1)
+
+	if err := os.Remove(countFile); err != nil {
+		t.Fatalf("remove count file: %v", err)
+	}
+
+	options.RestartPolicy.IgnoreExitCodes = nil
+	options.RestartPolicy.ExitCodes = []int{2}
+	if err := Run("development", options); err == nil {
+		t.Fatal("expected command failure")
+	}
+	assertFileSize(t, countFile, 3)
+}
@@ -0,0 +1,381 @@
+package envset
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// Schema types supported by the @type annotation
+const (
+	TypeString   = "string"
+	TypeInt      = "int"
+	TypeBool     = "bool"
+	TypeURL      = "url"
+	TypeDuration = "duration"
+	TypePort     = "port"
+	TypeEmail    = "email"
+	TypeEnum     = "enum"
+	TypeRegex    = "regex"
+)
+
+// SchemaTypes are the types a schema key can have
+var SchemaTypes = []string{TypeString, TypeInt, TypeBool, TypeURL, TypeDuration, TypePort, TypeEmail, TypeEnum, TypeRegex}
+
+// SchemaKey describes a variable of an environment. Keys are
+// declared in a schema file with annotations in their comments:
+//
+//	# Port the server listens on
+//	# @type=port @default=3000 @required
+//	PORT={{PORT}}
+type SchemaKey struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Default     string
+	HasDefault  bool
+	// Values are the allowed values of enum keys
+	Values []string
+	// Pattern values of regex keys have to match
+	Pattern *regexp.Regexp
+	// required has the environments where the key is required,
+	// * for all of them
+	required []string
+}
+
+// Schema has the keys declared for each environment. Keys in
+// the DEFAULT section apply to all environments.
+type Schema struct {
+	Filename string
+	sections map[string]map[string]*SchemaKey
+}
+
+// LoadSchema loads a schema file. Sections are environments
+// and can extend other sections like in env files.
+func LoadSchema(filename string) (*Schema, error) {
+	file, err := LoadFile(filename, "", ini.LoadOptions{SkipUnrecognizableLines: true})
+	if err != nil {
+		return nil, fmt.Errorf("load schema %s: %w", filename, err)
+	}
+
+	file, err = InheritSections(file, true)
+	if err != nil {
+		return nil, fmt.Errorf("schema inherit sections %s: %w", filename, err)
+	}
+
+	s := &Schema{
+		Filename: filename,
+		sections: make(map[string]map[string]*SchemaKey),
+	}
+
+	for _, sec := range file.Sections() {
+		keys := make(map[string]*SchemaKey)
+		for _, key := range sec.Keys() {
+			if isSpecialKey(key.Name()) {
+				continue
+			}
+			k := &SchemaKey{Name: key.Name()}
+			if err := k.annotate(sec.Name(), key.Comment); err != nil {
+				return nil, fmt.Errorf("schema %s [%s] %s: %w", filename, sec.Name(), key.Name(), err)
+			}
+			keys[key.Name()] = k
+		}
+		s.sections[sec.Name()] = keys
+	}
+	return s, nil
+}
+
+// annotate parses the annotations and description of a key comment
+func (k *SchemaKey) annotate(section, comment string) error {
+	description := []string{}
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#;"))
+
+		words := []string{}
+		for _, field := range strings.Fields(line) {
+			if !strings.HasPrefix(field, "@") {
+				words = append(words, field)
+				continue
+			}
+			name, value, _ := strings.Cut(strings.TrimPrefix(field, "@"), "=")
+			if err := k.set(section, name, value); err != nil {
+				return err
+			}
+		}
+		if len(words) > 0 {
+			description = append(description, strings.Join(words, " "))
+		}
+	}
+
+	if k.Description == "" {
+		k.Description = strings.Join(description, " ")
+	}
+	return k.check()
+}
+
+func (k *SchemaKey) set(section, name, value string) error {
+	switch name {
+	case "type":
+		k.Type = value
+	case "required":
+		switch value {
+		case "", "true":
+			if section == DefaultSection {
+				section = "*"
+			}
+			k.required = []string{section}
+		case "false":
+			k.required = []string{}
+		default:
+			k.required = strings.Split(value, ",")
+		}
+	case "default":
+		k.Default = value
+		k.HasDefault = true
+	case "values":
+		k.Values = strings.Split(value, ",")
+		if k.Type == "" {
+			k.Type = TypeEnum
+		}
+	case "pattern":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return fmt.Errorf("pattern: %w", err)
+		}
+		k.Pattern = re
+		if k.Type == "" {
+			k.Type = TypeRegex
+		}
+	case "description":
+		k.Description = value
+	}
+	//other annotations, e.g. @secret, are not part of the schema
+	return nil
+}
+
+func (k *SchemaKey) check() error {
+	if k.Type != "" && !slices.Contains(SchemaTypes, k.Type) {
+		return fmt.Errorf("unknown type %q, expected one of %s", k.Type, strings.Join(SchemaTypes, ", "))
+	}
+	if k.Type == TypeEnum && len(k.Values) == 0 {
+		return fmt.Errorf("enum without @values")
+	}
+	if k.Type == TypeRegex && k.Pattern == nil {
+		return fmt.Errorf("regex without @pattern")
+	}
+	return nil
+}
+
+// merge sets the annotations of o in k
+func (k *SchemaKey) merge(o *SchemaKey) {
+	if o.Type != "" {
+		k.Type = o.Type
+	}
+	if o.Description != "" {
+		k.Description = o.Description
+	}
+	if o.HasDefault {
+		k.Default = o.Default
+		k.HasDefault = true
+	}
+	if o.Values != nil {
+		k.Values = o.Values
+	}
+	if o.Pattern != nil {
+		k.Pattern = o.Pattern
+	}
+	if o.required != nil {
+		k.required = o.required
+	}
+}
+
+// Keys returns the keys of an environment sorted by name. Annotations
+// in the environment section override the ones in DEFAULT.
+func (s *Schema) Keys(environment string) []*SchemaKey {
+	if s == nil {
+		return nil
+	}
+
+	merged := make(map[string]*SchemaKey)
+	for _, name := range []string{DefaultSection, environment} {
+		for _, key := range s.sections[name] {
+			k, ok := merged[key.Name]
+			if !ok {
+				k = &SchemaKey{Name: key.Name}
+				merged[key.Name] = k
+			}
+			k.merge(key)
+		}
+	}
+
+	keys := make([]*SchemaKey, 0, len(merged))
+	for _, k := range merged {
+		if k.Type == "" {
+			k.Type = TypeString
+		}
+		k.Required = slices.Contains(k.required, "*") || slices.Contains(k.required, environment)
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys
+}
+
+// Environments returns the environments declared in the schema
+func (s *Schema) Environments() []string {
+	if s == nil {
+		return nil
+	}
+
+	names := []string{}
+	for name := range s.sections {
+		if name != DefaultSection {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyDefaults sets the default value of keys that are not
+// set or empty in env.
+func (s *Schema) ApplyDefaults(environment string, env EnvMap) {
+	for _, k := range s.Keys(environment) {
+		if k.HasDefault && env[k.Name] == "" {
+			env[k.Name] = k.Default
+		}
+	}
+}
+
+// KeyValidation is the result of validating a key
+type KeyValidation struct {
+	Key *SchemaKey
+	// Error is empty if the value is valid
+	Error string
+}
+
+// ValidationReport has the results of validating an environment
+type ValidationReport struct {
+	Environment string
+	Results     []KeyValidation
+}
+
+// Valid returns true if all keys are valid
+func (r ValidationReport) Valid() bool {
+	return len(r.Errors()) == 0
+}
+
+// Errors returns the results of invalid keys
+func (r ValidationReport) Errors() []KeyValidation {
+	errs := []KeyValidation{}
+	for _, res := range r.Results {
+		if res.Error != "" {
+			errs = append(errs, res)
+		}
+	}
+	return errs
+}
+
+// Err returns an *ErrorValidation if the environment is not valid
+func (r ValidationReport) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	return &ErrorValidation{Environment: r.Environment, Errors: r.Errors()}
+}
+
+// Validate checks the values of env with the keys of the
+// environment. Keys that are not in the schema are not checked.
+func (s *Schema) Validate(environment string, env EnvMap) ValidationReport {
+	report := ValidationReport{Environment: environment}
+	for _, k := range s.Keys(environment) {
+		report.Results = append(report.Results, KeyValidation{Key: k, Error: k.validate(env)})
+	}
+	return report
+}
+
+func (k *SchemaKey) validate(env EnvMap) string {
+	value, ok := env[k.Name]
+	if !ok || value == "" {
+		if k.Required {
+			return "required"
+		}
+		return ""
+	}
+
+	switch k.Type {
+	case TypeInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "expected an integer"
+		}
+	case TypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "expected true or false"
+		}
+	case TypeURL:
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+			return "expected a url with scheme and host"
+		}
+	case TypeDuration:
+		if _, err := time.ParseDuration(value); err != nil {
+			return "expected a duration, e.g. 30s"
+		}
+	case TypePort:
+		if p, err := strconv.Atoi(value); err != nil || p < 1 || p > 65535 {
+			return "expected a port between 1 and 65535"
+		}
+	case TypeEmail:
+		if a, err := mail.ParseAddress(value); err != nil || a.Address != value {
+			return "expected an email address"
+		}
+	case TypeEnum:
+		if !slices.Contains(k.Values, value) {
+			return "expected one of " + strings.Join(k.Values, ", ")
+		}
+	}
+
+	if k.Pattern != nil && !k.Pattern.MatchString(value) {
+		return "expected to match " + k.Pattern.String()
+	}
+	return ""
+}
+
+// loadRunSchema loads the schema of the run options, if any
+func loadRunSchema(options RunOptions) (*Schema, error) {
+	if options.Schema == "" {
+		return nil, nil
+	}
+	return LoadSchema(options.Schema)
+}
+
+// ValidateEnvironment loads an environment, applying the schema
+// defaults, and validates it with the schema.
+func ValidateEnvironment(environment string, schema *Schema, options RunOptions) (ValidationReport, error) {
+	env, err := getEnvFile(environment, options)
+	if err != nil {
+		return ValidationReport{}, err
+	}
+
+	context, err := getContext(environment, env, options)
+	if err != nil {
+		return ValidationReport{}, err
+	}
+	schema.ApplyDefaults(environment, context)
+
+	if err := context.Expand(options.Expand); err != nil {
+		return ValidationReport{}, expandError(err, environment)
+	}
+
+	return schema.Validate(environment, context), nil
+}
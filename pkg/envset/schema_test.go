@@ -0,0 +1,190 @@
+package envset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const schemaTestFile = `# Port the server listens on
+# @type=port @default=8080
+PORT={{PORT}}
+# @values=debug,info,warn @required
+LOG_LEVEL={{LOG_LEVEL}}
+# @type=url
+DATABASE_URL={{DATABASE_URL}}
+# @type=duration @default=30s
+TIMEOUT={{TIMEOUT}}
+# @pattern=^v[0-9]+$
+API_VERSION={{API_VERSION}}
+# @type=int
+WORKERS={{WORKERS}}
+# @type=bool
+DEBUG={{DEBUG}}
+# @type=email @required=production
+ADMIN={{ADMIN}}
+
+[production]
+# Production database @required @secret
+DATABASE_URL={{DATABASE_URL}}
+`
+
+func writeSchemaFile(t *testing.T, contents string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), ".envset.schema")
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatalf("write schema: %v", err)
+	}
+	return filename
+}
+
+func Test_LoadSchema(t *testing.T) {
+	schema, err := LoadSchema(writeSchemaFile(t, schemaTestFile))
+	if err != nil {
+		t.Fatalf("load schema: %v", err)
+	}
+
+	keys := map[string]*SchemaKey{}
+	for _, k := range schema.Keys("production") {
+		keys[k.Name] = k
+	}
+
+	port := keys["PORT"]
+	if port.Type != TypePort || port.Default != "8080" || port.Required || port.Description != "Port the server listens on" {
+		t.Fatalf("unexpected PORT key %+v", port)
+	}
+
+	db := keys["DATABASE_URL"]
+	if db.Type != TypeURL || !db.Required || db.Description != "Production database" {
+		t.Fatalf("unexpected DATABASE_URL key %+v", db)
+	}
+
+	if k := keys["LOG_LEVEL"]; k.Type != TypeEnum || !reflect.DeepEqual(k.Values, []string{"debug", "info", "warn"}) || !k.Required {
+		t.Fatalf("unexpected LOG_LEVEL key %+v", k)
+	}
+	if k := keys["API_VERSION"]; k.Type != TypeRegex || k.Pattern.String() != "^v[0-9]+$" {
+		t.Fatalf("unexpected API_VERSION key %+v", k)
+	}
+	if !keys["ADMIN"].Required {
+		t.Fatal("expected ADMIN to be required in production")
+	}
+
+	for _, k := range schema.Keys("development") {
+		if k.Required != (k.Name == "LOG_LEVEL") {
+			t.Fatalf("%s: unexpected required %v in development", k.Name, k.Required)
+		}
+	}
+
+	if envs := schema.Environments(); !reflect.DeepEqual(envs, []string{"production"}) {
+		t.Fatalf("unexpected environments %v", envs)
+	}
+}
+
+func Test_LoadSchemaErrors(t *testing.T) {
+	tests := []struct {
+		contents string
+		want     string
+	}{
+		{"# @type=uuid\nID=\n", `unknown type "uuid"`},
+		{"# @type=enum\nLEVEL=\n", "enum without @values"},
+		{"# @type=regex\nVERSION=\n", "regex without @pattern"},
+		{"# @pattern=[a-\nVERSION=\n", "pattern"},
+	}
+
+	for _, tt := range tests {
+		_, err := LoadSchema(writeSchemaFile(t, tt.contents))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Fatalf("%q: expected error %q, got %v", tt.contents, tt.want, err)
+		}
+	}
+}
+
+func Test_SchemaValidate(t *testing.T) {
+	schema, err := LoadSchema(writeSchemaFile(t, schemaTestFile))
+	if err != nil {
+		t.Fatalf("load schema: %v", err)
+	}
+
+	env := EnvMap{
+		"PORT":        "0",
+		"LOG_LEVEL":   "verbose",
+		"TIMEOUT":     "10",
+		"API_VERSION": "2",
+		"WORKERS":     "four",
+		"DEBUG":       "yes",
+		"ADMIN":       "admin",
+		"OTHER":       "not in schema",
+	}
+
+	report := schema.Validate("production", env)
+	got := map[string]string{}
+	for _, res := range report.Errors() {
+		got[res.Key.Name] = res.Error
+	}
+
+	want := map[string]string{
+		"PORT":         "expected a port between 1 and 65535",
+		"LOG_LEVEL":    "expected one of debug, info, warn",
+		"DATABASE_URL": "required",
+		"TIMEOUT":      "expected a duration, e.g. 30s",
+		"API_VERSION":  "expected to match ^v[0-9]+$",
+		"WORKERS":      "expected an integer",
+		"DEBUG":        "expected true or false",
+		"ADMIN":        "expected an email address",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	var verr *ErrorValidation
+	if err := report.Err(); !errors.As(err, &verr) || !strings.Contains(err.Error(), "[production] invalid environment: ADMIN: expected an email address") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	env = EnvMap{
+		"LOG_LEVEL":    "info",
+		"DATABASE_URL": "postgres://db:5432/app",
+		"API_VERSION":  "v2",
+		"WORKERS":      "4",
+		"DEBUG":        "true",
+		"ADMIN":        "admin@example.com",
+	}
+	schema.ApplyDefaults("production", env)
+	if env["PORT"] != "8080" || env["TIMEOUT"] != "30s" {
+		t.Fatalf("expected defaults, got %v", env)
+	}
+	if report := schema.Validate("production", env); !report.Valid() || report.Err() != nil {
+		t.Fatalf("expected valid environment, got %v", report.Err())
+	}
+}
+
+func Test_LoadEnvironmentSchema(t *testing.T) {
+	filename := writeSecretsFile(t, "[development]\nLOG_LEVEL=debug\nURL=http://localhost:${PORT}\n\n[production]\nLOG_LEVEL=info\n")
+	o := RunOptions{Filename: filename, ExportEnvName: "APP_ENV", Expand: true, Schema: writeSchemaFile(t, schemaTestFile)}
+
+	env, err := loadEnvironment("development", o)
+	if err != nil {
+		t.Fatalf("load environment: %v", err)
+	}
+	if env["URL"] != "http://localhost:8080" {
+		t.Fatalf("expected default to be expanded, got %q", env["URL"])
+	}
+
+	_, err = loadEnvironment("production", o)
+	var verr *ErrorValidation
+	if !errors.As(err, &verr) || len(verr.Errors) != 2 {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	if err := Print("production", o); !errors.As(err, &verr) {
+		t.Fatalf("expected print validation error, got %v", err)
+	}
+
+	names, err := EnvironmentNames(RunOptions{Filename: filename})
+	if err != nil || !reflect.DeepEqual(names, []string{"development", "production"}) {
+		t.Fatalf("unexpected environment names %v %v", names, err)
+	}
+}
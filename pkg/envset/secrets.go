@@ -0,0 +1,239 @@
+package envset
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SecretRefPrefix marks values that reference a secret, e.g.
+// ref+file:///run/secrets/db or ref+env://CI_TOKEN
+const SecretRefPrefix = "ref+"
+
+// SecretRef is a reference to a secret value
+type SecretRef struct {
+	// Scheme selects the resolver, e.g. file
+	Scheme string
+	// Path is everything after scheme://, e.g. /run/secrets/db
+	Path string
+	// Raw is the full reference
+	Raw string
+}
+
+func (r SecretRef) String() string {
+	return r.Raw
+}
+
+// ParseSecretRef parses a ref+<scheme>://<path> value, returns
+// false if value is not a secret reference.
+func ParseSecretRef(value string) (SecretRef, bool) {
+	rest, ok := strings.CutPrefix(value, SecretRefPrefix)
+	if !ok {
+		return SecretRef{}, false
+	}
+
+	scheme, path, ok := strings.Cut(rest, "://")
+	if !ok || !isSchemeName(scheme) {
+		return SecretRef{}, false
+	}
+
+	return SecretRef{Scheme: scheme, Path: path, Raw: value}, true
+}
+
+func isSchemeName(str string) bool {
+	if str == "" {
+		return false
+	}
+	for _, ch := range str {
+		if !(ch >= 'a' && ch <= 'z') && !(ch >= '0' && ch <= '9') && ch != '-' && ch != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// SecretResolver returns the value of a secret reference.
+// Resolvers are registered for a scheme with RegisterSecretResolver.
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref SecretRef) (string, error)
+}
+
+// SecretResolverFunc lets you use a function as a SecretResolver
+type SecretResolverFunc func(ctx context.Context, ref SecretRef) (string, error)
+
+// Resolve calls f(ctx, ref)
+func (f SecretResolverFunc) Resolve(ctx context.Context, ref SecretRef) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{
+		"file":  fileSecretResolver{},
+		"env":   envSecretResolver{},
+		"exec":  execSecretResolver{},
+		"http":  defaultHTTPSecretResolver,
+		"https": defaultHTTPSecretResolver,
+	}
+)
+
+// RegisterSecretResolver makes a resolver available for
+// ref+<scheme>:// values. Registering a scheme again replaces
+// its resolver, a nil resolver removes it.
+func RegisterSecretResolver(scheme string, r SecretResolver) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+
+	if r == nil {
+		delete(secretResolvers, scheme)
+		return
+	}
+	secretResolvers[scheme] = r
+}
+
+// SecretResolverSchemes returns the registered schemes sorted
+func SecretResolverSchemes() []string {
+	secretResolversMu.RLock()
+	defer secretResolversMu.RUnlock()
+
+	schemes := make([]string, 0, len(secretResolvers))
+	for scheme := range secretResolvers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// ResolveSecretRef resolves a reference with the resolver
+// registered for its scheme.
+func ResolveSecretRef(ctx context.Context, ref SecretRef) (string, error) {
+	secretResolversMu.RLock()
+	r, ok := secretResolvers[ref.Scheme]
+	secretResolversMu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("unknown secret resolver %q, expected one of %s", ref.Scheme, strings.Join(SecretResolverSchemes(), ", "))
+	}
+
+	val, err := r.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", ref, err)
+	}
+	return val, nil
+}
+
+// fileSecretResolver reads ref+file:///path/to/secret, a trailing
+// new line is removed, e.g. for docker or k8s secrets.
+type fileSecretResolver struct{}
+
+func (fileSecretResolver) Resolve(_ context.Context, ref SecretRef) (string, error) {
+	if ref.Path == "" {
+		return "", fmt.Errorf("missing file path")
+	}
+
+	b, err := os.ReadFile(ref.Path) // #nosec G304 -- envset intentionally reads user-provided secret files.
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	return trimNewline(string(b)), nil
+}
+
+// envSecretResolver reads ref+env://NAME from the OS environment
+type envSecretResolver struct{}
+
+func (envSecretResolver) Resolve(_ context.Context, ref SecretRef) (string, error) {
+	val, ok := os.LookupEnv(ref.Path)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not set", ref.Path)
+	}
+	return val, nil
+}
+
+// execSecretResolver runs ref+exec://command args and uses its
+// output. The command is executed directly, not through a shell,
+// arguments can be quoted.
+type execSecretResolver struct{}
+
+func (execSecretResolver) Resolve(ctx context.Context, ref SecretRef) (string, error) {
+	args, err := splitArgs(ref.Path)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", fmt.Errorf("missing command")
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) // #nosec G204 -- envset intentionally runs commands referenced in env files.
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("exec %s: %w: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("exec %s: %w", args[0], err)
+	}
+	return trimNewline(string(out)), nil
+}
+
+func trimNewline(str string) string {
+	str = strings.TrimSuffix(str, "\n")
+	return strings.TrimSuffix(str, "\r")
+}
+
+// splitArgs splits a command line in arguments, handling single
+// and double quotes and backslash escapes like a shell would.
+func splitArgs(str string) ([]string, error) {
+	args := []string{}
+	var arg strings.Builder
+	inArg := false
+	quote := byte(0)
+
+	for i := 0; i < len(str); i++ {
+		ch := str[i]
+		switch {
+		case quote == '\'':
+			if ch == '\'' {
+				quote = 0
+				continue
+			}
+			arg.WriteByte(ch)
+		case ch == '\\' && i+1 < len(str) && (quote == 0 || strings.IndexByte(`"\$`+"`", str[i+1]) != -1):
+			i++
+			arg.WriteByte(str[i])
+			inArg = true
+		case quote == '"':
+			if ch == '"' {
+				quote = 0
+				continue
+			}
+			arg.WriteByte(ch)
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inArg = true
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(ch)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", str)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
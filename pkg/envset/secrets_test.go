@@ -0,0 +1,146 @@
+package envset
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_ParseSecretRef(t *testing.T) {
+	tests := []struct {
+		value string
+		want  SecretRef
+		ok    bool
+	}{
+		{"ref+file:///run/secrets/db", SecretRef{Scheme: "file", Path: "/run/secrets/db"}, true},
+		{"ref+env://CI_TOKEN", SecretRef{Scheme: "env", Path: "CI_TOKEN"}, true},
+		{"ref+exec://pass show db", SecretRef{Scheme: "exec", Path: "pass show db"}, true},
+		{"ref+vault-kv://secret/db#password", SecretRef{Scheme: "vault-kv", Path: "secret/db#password"}, true},
+		{"ref+file:/run/secrets/db", SecretRef{}, false},
+		{"ref+FILE:///run/secrets/db", SecretRef{}, false},
+		{"file:///run/secrets/db", SecretRef{}, false},
+		{"prefix ref+env://CI_TOKEN", SecretRef{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ParseSecretRef(tt.value)
+		if ok != tt.ok {
+			t.Fatalf("%s: got ok %v", tt.value, ok)
+		}
+		if ok {
+			tt.want.Raw = tt.value
+		}
+		if got != tt.want {
+			t.Fatalf("%s: got %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func Test_SplitArgs(t *testing.T) {
+	tests := []struct {
+		str  string
+		want []string
+	}{
+		{"pass show db", []string{"pass", "show", "db"}},
+		{`  printf  '%s %s'  "a b" c\ d `, []string{"printf", "%s %s", "a b", "c d"}},
+		{`echo "say \"hi\"" 'it''s' ""`, []string{"echo", `say "hi"`, "its", ""}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		got, err := splitArgs(tt.str)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.str, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("%s: got %q, want %q", tt.str, got, tt.want)
+		}
+	}
+
+	if _, err := splitArgs(`echo "open`); err == nil {
+		t.Fatal("expected unterminated quote error")
+	}
+}
+
+func Test_SecretRefExpand(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db"), []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatalf("write secret: %v", err)
+	}
+	t.Setenv("ENVSET_TEST_CI_TOKEN", "t0k3n")
+
+	env := EnvMap{
+		"SECRETS":  dir,
+		"DB_PASS":  "ref+file://${SECRETS}/db",
+		"TOKEN":    "ref+env://ENVSET_TEST_CI_TOKEN",
+		"GREETING": "ref+exec://printf '%s $(not a command)' hello",
+		"DB_URL":   "postgres://app:${DB_PASS}@db",
+	}
+
+	if err := env.Expand(true); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+
+	want := EnvMap{
+		"SECRETS":  dir,
+		"DB_PASS":  "s3cr3t",
+		"TOKEN":    "t0k3n",
+		"GREETING": "hello $(not a command)",
+		"DB_URL":   "postgres://app:s3cr3t@db",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Fatalf("got %v, want %v", env, want)
+	}
+}
+
+func Test_SecretRefErrors(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"ref+unknown://x", `unknown secret resolver "unknown"`},
+		{"ref+env://ENVSET_TEST_NOT_SET", "ENVSET_TEST_NOT_SET not set"},
+		{"ref+file:///does/not/exist", "read file"},
+		{"ref+exec://", "missing command"},
+		{"ref+exec://sh -c 'echo failed >&2; exit 3'", "failed"},
+	}
+
+	for _, tt := range tests {
+		env := EnvMap{"SECRET": tt.value}
+		err := env.Expand(false)
+		if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), "SECRET") {
+			t.Fatalf("%s: expected error with %q, got %v", tt.value, tt.want, err)
+		}
+	}
+}
+
+func Test_RegisterSecretResolver(t *testing.T) {
+	RegisterSecretResolver("vault", SecretResolverFunc(func(_ context.Context, ref SecretRef) (string, error) {
+		if ref.Path == "missing" {
+			return "", errors.New("not found")
+		}
+		return "vault:" + ref.Path, nil
+	}))
+	defer RegisterSecretResolver("vault", nil)
+
+	if schemes := SecretResolverSchemes(); !reflect.DeepEqual(schemes, []string{"env", "exec", "file", "http", "https", "vault"}) {
+		t.Fatalf("unexpected schemes %v", schemes)
+	}
+
+	env := EnvMap{"DB_PASS": "ref+vault://secret/db"}
+	if err := env.Expand(false); err != nil {
+		t.Fatalf("expand: %v", err)
+	}
+	if env["DB_PASS"] != "vault:secret/db" {
+		t.Fatalf("got %q", env["DB_PASS"])
+	}
+
+	env = EnvMap{"DB_PASS": "ref+vault://missing"}
+	if err := env.Expand(false); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected resolver error, got %v", err)
+	}
+}
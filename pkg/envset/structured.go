@@ -0,0 +1,376 @@
+package envset
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+// GlobalsSection is the block that holds global variables in
+// yaml, toml and json env files, same as the ini default section.
+const GlobalsSection = "globals"
+
+// structuredSection is an environment loaded from a
+// yaml, toml or json env file before we build the ini file.
+type structuredSection struct {
+	name    string
+	comment string
+	raw     string
+	isRaw   bool
+	keys    []structuredKey
+}
+
+type structuredKey struct {
+	name    string
+	value   string
+	comment string
+}
+
+// structuredFile keeps sections in the order they are defined
+type structuredFile struct {
+	sections []*structuredSection
+	comments []string
+}
+
+func (f *structuredFile) section(name string) *structuredSection {
+	if name == GlobalsSection {
+		name = DefaultSection
+	}
+
+	for _, sec := range f.sections {
+		if sec.name == name {
+			return sec
+		}
+	}
+
+	sec := &structuredSection{name: name}
+	f.sections = append(f.sections, sec)
+	return sec
+}
+
+// add a top level value, these are global variables
+// unless the name is a comment section.
+func (f *structuredFile) add(name, value, comment string) {
+	if slices.Contains(f.comments, name) {
+		sec := f.section(name)
+		sec.raw = value
+		sec.isRaw = true
+		sec.comment = comment
+		return
+	}
+	f.section(DefaultSection).set(name, value, comment)
+}
+
+func (s *structuredSection) set(name, value, comment string) {
+	for i := range s.keys {
+		if s.keys[i].name == name {
+			s.keys[i].value = value
+			return
+		}
+	}
+	s.keys = append(s.keys, structuredKey{name: name, value: value, comment: comment})
+}
+
+func (f *structuredFile) toIni() (*ini.File, error) {
+	file := ini.Empty()
+	for _, s := range f.sections {
+		if s.isRaw {
+			sec, err := file.NewRawSection(s.name, s.raw)
+			if err != nil {
+				return nil, fmt.Errorf("new raw section %s: %w", s.name, err)
+			}
+			sec.Comment = s.comment
+			continue
+		}
+
+		sec, err := file.NewSection(s.name)
+		if err != nil {
+			return nil, fmt.Errorf("new section %s: %w", s.name, err)
+		}
+		sec.Comment = s.comment
+
+		for _, k := range s.keys {
+			key, err := sec.NewKey(k.name, k.value)
+			if err != nil {
+				return nil, fmt.Errorf("new key %s.%s: %w", s.name, k.name, err)
+			}
+			key.Comment = k.comment
+		}
+	}
+	return file, nil
+}
+
+// parseYAML loads a yaml env file where top level keys are
+// environments. Comments above keys are kept as key comments.
+func parseYAML(b []byte, commentSections []string) (*ini.File, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("yaml unmarshal: %w", err)
+	}
+
+	file := &structuredFile{comments: commentSections}
+	if len(doc.Content) == 0 {
+		return file.toIni()
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("yaml line %d: expected a map of environments", root.Line)
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		name, value := root.Content[i], resolveYAMLAlias(root.Content[i+1])
+		switch value.Kind {
+		case yaml.MappingNode:
+			sec := file.section(name.Value)
+			sec.comment = yamlComment(name)
+			if err := addYAMLKeys(sec, value); err != nil {
+				return nil, err
+			}
+		case yaml.ScalarNode:
+			file.add(name.Value, yamlScalar(value), yamlComment(name))
+		default:
+			return nil, fmt.Errorf("yaml line %d: %s must be a map or a value", value.Line, name.Value)
+		}
+	}
+
+	return file.toIni()
+}
+
+// addYAMLKeys adds the keys of a yaml map to the section. Merge keys,
+// e.g. <<: *production, are applied first so explicit keys win no
+// matter where they are defined, and earlier maps in a merge list win
+// over later ones.
+func addYAMLKeys(sec *structuredSection, node *yaml.Node) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveYAMLAlias(node.Content[i+1])
+		if key.Value != "<<" {
+			continue
+		}
+
+		merged := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			merged = value.Content
+		}
+		for j := len(merged) - 1; j >= 0; j-- {
+			m := resolveYAMLAlias(merged[j])
+			if m.Kind != yaml.MappingNode {
+				return fmt.Errorf("yaml line %d: %s merge must be a map", m.Line, sec.name)
+			}
+			if err := addYAMLKeys(sec, m); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveYAMLAlias(node.Content[i+1])
+		if key.Value == "<<" {
+			continue
+		}
+		if value.Kind != yaml.ScalarNode {
+			return fmt.Errorf("yaml line %d: %s.%s must be a value", value.Line, sec.name, key.Value)
+		}
+		sec.set(key.Value, yamlScalar(value), yamlComment(key))
+	}
+	return nil
+}
+
+func resolveYAMLAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func yamlScalar(node *yaml.Node) string {
+	if node.Tag == "!!null" {
+		return ""
+	}
+	return node.Value
+}
+
+func yamlComment(node *yaml.Node) string {
+	return strings.TrimSpace(node.HeadComment)
+}
+
+// parseTOML loads a toml env file where tables are environments
+// and top level keys are global variables.
+func parseTOML(b []byte, commentSections []string) (*ini.File, error) {
+	data := make(map[string]any)
+	md, err := toml.Decode(string(b), &data)
+	if err != nil {
+		return nil, fmt.Errorf("toml decode: %w", err)
+	}
+
+	file := &structuredFile{comments: commentSections}
+	for _, key := range md.Keys() {
+		switch len(key) {
+		case 1:
+			name := key[0]
+			if _, ok := data[name].(map[string]any); ok {
+				file.section(name)
+				continue
+			}
+			value, err := tomlScalar(data[name])
+			if err != nil {
+				return nil, fmt.Errorf("toml %s: %w", name, err)
+			}
+			file.add(name, value, "")
+		case 2:
+			table, _ := data[key[0]].(map[string]any)
+			value, err := tomlScalar(table[key[1]])
+			if err != nil {
+				return nil, fmt.Errorf("toml %s: %w", key.String(), err)
+			}
+			file.section(key[0]).set(key[1], value, "")
+		default:
+			return nil, fmt.Errorf("toml %s: nested values are not supported", key.String())
+		}
+	}
+
+	return file.toIni()
+}
+
+func tomlScalar(v any) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case time.Time:
+		return val.Format(time.RFC3339Nano), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// parseJSON loads a json env file where top level keys are
+// environments. Key order is preserved.
+func parseJSON(b []byte, commentSections []string) (*ini.File, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	file := &structuredFile{comments: commentSections}
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	for dec.More() {
+		name, err := jsonKey(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("json decode %s: %w", name, err)
+		}
+
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '{' {
+			if err := addJSONKeys(file.section(name), raw); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		value, err := jsonScalar(raw)
+		if err != nil {
+			return nil, fmt.Errorf("json %s: %w", name, err)
+		}
+		file.add(name, value, "")
+	}
+
+	if err := expectJSONDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	return file.toIni()
+}
+
+func addJSONKeys(sec *structuredSection, raw json.RawMessage) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	if err := expectJSONDelim(dec, '{'); err != nil {
+		return err
+	}
+
+	for dec.More() {
+		name, err := jsonKey(dec)
+		if err != nil {
+			return err
+		}
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("json decode %s.%s: %w", sec.name, name, err)
+		}
+
+		str, err := jsonScalar(value)
+		if err != nil {
+			return fmt.Errorf("json %s.%s: %w", sec.name, name, err)
+		}
+		sec.set(name, str, "")
+	}
+
+	return expectJSONDelim(dec, '}')
+}
+
+func jsonKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", fmt.Errorf("json token: %w", err)
+	}
+	name, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("json: expected key, got %v", tok)
+	}
+	return name, nil
+}
+
+func expectJSONDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("json token: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("json: expected %s at offset %d", delim, dec.InputOffset())
+	}
+	return nil
+}
+
+func jsonScalar(raw json.RawMessage) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", fmt.Errorf("json decode: %w", err)
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return val, nil
+	case json.Number:
+		return val.String(), nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	default:
+		return "", fmt.Errorf("unsupported value type %T", v)
+	}
+}
@@ -0,0 +1,159 @@
+package envset
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/ini.v1"
+)
+
+var structuredFixtures = map[string]string{
+	".envset.yaml": `
+# global version
+VERSION: v1
+COMMENTS: this is not a variable
+globals:
+  REGION: us-east-1
+production: &production
+  # base url
+  APP_URL: https://envset.sh
+  PORT: 80
+  DEBUG: false
+development:
+  <<: *production
+  APP_URL: http://localhost
+  EMPTY:
+`,
+	".envset.toml": `
+VERSION = "v1"
+COMMENTS = "this is not a variable"
+
+[globals]
+REGION = "us-east-1"
+
+[production]
+APP_URL = "https://envset.sh"
+PORT = 80
+DEBUG = false
+
+[development]
+APP_URL = "http://localhost"
+PORT = 80
+DEBUG = false
+EMPTY = ""
+`,
+	".envset.json": `{
+  "VERSION": "v1",
+  "COMMENTS": "this is not a variable",
+  "globals": {"REGION": "us-east-1"},
+  "production": {"APP_URL": "https://envset.sh", "PORT": 80, "DEBUG": false},
+  "development": {"APP_URL": "http://localhost", "PORT": 80, "DEBUG": false, "EMPTY": null}
+}`,
+}
+
+func Test_LoadFile_StructuredFormats(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, contents := range structuredFixtures {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(dir, name)
+			if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+				t.Fatalf("write env file: %v", err)
+			}
+
+			file, err := LoadFile(filename, "", ini.LoadOptions{UnparseableSections: []string{"COMMENTS"}})
+			if err != nil {
+				t.Fatalf("load file: %v", err)
+			}
+
+			wantSections := []string{DefaultSection, "COMMENTS", "production", "development"}
+			if got := file.SectionStrings(); !reflect.DeepEqual(got, wantSections) {
+				t.Fatalf("sections = %v, want %v", got, wantSections)
+			}
+
+			assertSection(t, file, DefaultSection, EnvMap{"VERSION": "v1", "REGION": "us-east-1"})
+			assertSection(t, file, "production", EnvMap{"APP_URL": "https://envset.sh", "PORT": "80", "DEBUG": "false"})
+			assertSection(t, file, "development", EnvMap{"APP_URL": "http://localhost", "PORT": "80", "DEBUG": "false", "EMPTY": ""})
+
+			if got := file.Section("COMMENTS").Body(); got != "this is not a variable" {
+				t.Fatalf("comments body = %q", got)
+			}
+		})
+	}
+}
+
+func Test_LoadFile_YAMLComments(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".envset.yml")
+	if err := os.WriteFile(filename, []byte(structuredFixtures[".envset.yaml"]), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	file, err := LoadFile(filename, "", ini.LoadOptions{})
+	if err != nil {
+		t.Fatalf("load file: %v", err)
+	}
+
+	if got := file.Section("production").Key("APP_URL").Comment; got != "# base url" {
+		t.Fatalf("comment = %q, want %q", got, "# base url")
+	}
+}
+
+func Test_LoadFile_YAMLMergeKeys(t *testing.T) {
+	contents := `base: &base
+  APP_URL: https://envset.sh
+  PORT: 80
+extra: &extra
+  PORT: 8080
+  DEBUG: true
+development:
+  APP_URL: http://localhost
+  <<: [*base, *extra]
+`
+	filename := filepath.Join(t.TempDir(), ".envset.yaml")
+	if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	file, err := LoadFile(filename, "", ini.LoadOptions{})
+	if err != nil {
+		t.Fatalf("load file: %v", err)
+	}
+
+	assertSection(t, file, "development", EnvMap{"APP_URL": "http://localhost", "PORT": "80", "DEBUG": "true"})
+}
+
+func Test_LoadFile_StructuredNestedValuesError(t *testing.T) {
+	fixtures := map[string]string{
+		"nested.yaml": "development:\n  DB:\n    HOST: localhost\n",
+		"nested.toml": "[development.DB]\nHOST = \"localhost\"\n",
+		"nested.json": `{"development": {"DB": {"HOST": "localhost"}}}`,
+		"list.yaml":   "development:\n  HOSTS: [a, b]\n",
+	}
+
+	dir := t.TempDir()
+	for name, contents := range fixtures {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+			t.Fatalf("write env file: %v", err)
+		}
+
+		if _, err := LoadFile(filename, "", ini.LoadOptions{}); err == nil {
+			t.Errorf("%s: expected nested value error", name)
+		}
+	}
+}
+
+func assertSection(t *testing.T, file *ini.File, name string, want EnvMap) {
+	t.Helper()
+
+	sec, err := file.GetSection(name)
+	if err != nil {
+		t.Fatalf("get section %s: %v", name, err)
+	}
+
+	if got := LoadIniSection(sec); !reflect.DeepEqual(got, want) {
+		t.Fatalf("section %s = %v, want %v", name, got, want)
+	}
+}
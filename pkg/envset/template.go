@@ -2,29 +2,63 @@ package envset
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
 
 	"gopkg.in/ini.v1"
 )
 
+// TemplateOptions are the options to create a template file
+type TemplateOptions struct {
+	Name      string
+	Format    string
+	Template  string
+	Overwrite bool
+	Print     bool
+	//Raw skips section inheritance, sections only have their own keys
+	Raw bool
+}
+
 // DocumentTemplate will create or update a document template
 // e.g. envset.tpl that we use to document and to check in our repo
 // so we can keep track of the variables and sections.
 func DocumentTemplate(name, template string, overwrite, printOutput bool) error {
-	filename, err := FileFinder(name)
+	return CreateTemplateFile(TemplateOptions{
+		Name:      name,
+		Template:  template,
+		Overwrite: overwrite,
+		Print:     printOutput,
+	})
+}
+
+// CreateTemplateFile is like DocumentTemplate but takes options to
+// set the env file format and whether to use the merged sections.
+func CreateTemplateFile(o TemplateOptions) error {
+	filename, err := FileFinder(o.Name)
 	if err != nil {
-		return fmt.Errorf("file finder %s: %w", name, err)
+		return fmt.Errorf("file finder %s: %w", o.Name, err)
 	}
 
 	ini.PrettyEqual = false
 	ini.PrettyFormat = false
 
-	cgf, err := ini.Load(filename)
+	cgf, err := LoadFile(filename, o.Format, ini.LoadOptions{})
 	if err != nil {
 		return fmt.Errorf("ini load %s: %w", filename, err)
 	}
 
-	tpl, err := loadTemplateFile(template, overwrite)
+	cgf, err = InheritSections(cgf, !o.Raw)
+	if err != nil {
+		return fmt.Errorf("inherit sections %s: %w", filename, err)
+	}
+
+	tpl, err := loadTemplateFile(o.Template, o.Overwrite)
 	if err != nil {
 		return err
 	}
@@ -35,7 +69,7 @@ func DocumentTemplate(name, template string, overwrite, printOutput bool) error
 		}
 	}
 
-	return writeTemplateFile(tpl, template, printOutput)
+	return writeTemplateFile(tpl, o.Template, o.Print)
 }
 
 func loadTemplateFile(template string, overwrite bool) (*ini.File, error) {
@@ -117,3 +151,87 @@ func writeTemplateFile(tpl *ini.File, template string, printOutput bool) error {
 	}
 	return nil
 }
+
+// RenderOptions are the options to render a template file
+type RenderOptions struct {
+	Template string
+	//AllowMissing keeps placeholders for keys that are not defined
+	AllowMissing bool
+}
+
+// placeholderRe matches {{KEY}} placeholders like the ones in
+// templates generated by DocumentTemplate, and {{.KEY}} fields.
+var placeholderRe = regexp.MustCompile(`({{-?\s*)(\.?)([A-Za-z_][A-Za-z0-9_]*)(\s*-?}})`)
+
+// templateKeywords are text/template actions that look like placeholders
+var templateKeywords = []string{"break", "continue", "else", "end", "false", "nil", "true"}
+
+// RenderTemplate renders a template file with the resolved values of
+// the environment. Templates can use {{KEY}} placeholders as well as
+// text/template syntax, e.g. {{.KEY}} or {{if .DEBUG}}...{{end}}.
+func RenderTemplate(w io.Writer, environment string, o RenderOptions, options RunOptions) error {
+	context, err := loadEnvironment(environment, options)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(o.Template) // #nosec G304 -- envset intentionally reads user-provided templates.
+	if err != nil {
+		return fmt.Errorf("read template %s: %w", o.Template, err)
+	}
+
+	out, err := renderTemplate(filepath.Base(o.Template), string(b), context, o.AllowMissing)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, out); err != nil {
+		return fmt.Errorf("write template: %w", err)
+	}
+	return nil
+}
+
+func renderTemplate(name, text string, context EnvMap, allowMissing bool) (string, error) {
+	missing := []string{}
+	text = placeholderRe.ReplaceAllStringFunc(text, func(match string) string {
+		parts := placeholderRe.FindStringSubmatch(match)
+		open, dot, key, closing := parts[1], parts[2], parts[3], parts[4]
+		if dot == "" && slices.Contains(templateKeywords, key) {
+			return match
+		}
+
+		if _, ok := context[key]; !ok {
+			if !slices.Contains(missing, key) {
+				missing = append(missing, key)
+			}
+			if allowMissing {
+				//keep the placeholder as is in the output
+				return fmt.Sprintf("%s%q%s", open, "{{"+dot+key+"}}", closing)
+			}
+		}
+		return fmt.Sprintf("%senv %q%s", open, key, closing)
+	})
+
+	if len(missing) > 0 && !allowMissing {
+		sort.Strings(missing)
+		return "", fmt.Errorf("unresolved template placeholders: %s", strings.Join(missing, ", "))
+	}
+
+	missingKey := "missingkey=error"
+	if allowMissing {
+		missingKey = "missingkey=zero"
+	}
+
+	tpl, err := template.New(name).Option(missingKey).Funcs(template.FuncMap{
+		"env": func(key string) string { return context[key] },
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parse template %s: %w", name, err)
+	}
+
+	var out strings.Builder
+	if err := tpl.Execute(&out, map[string]string(context)); err != nil {
+		return "", fmt.Errorf("render template %s: %w", name, err)
+	}
+	return out.String(), nil
+}
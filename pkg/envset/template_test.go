@@ -0,0 +1,85 @@
+package envset
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_RenderTemplate(t *testing.T) {
+	context := EnvMap{"HOST": "envset.sh", "PORT": "80", "DEBUG": ""}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"placeholders", "server {{HOST}}:{{ PORT }};", "server envset.sh:80;"},
+		{"text/template", "{{.HOST}}{{if .DEBUG}} debug{{end}}", "envset.sh"},
+		{"env func", `{{env "PORT" | printf "%s/tcp"}}`, "80/tcp"},
+		{"trim", "a\n{{- HOST -}}\nb", "aenvset.shb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderTemplate("test", tt.text, context, false)
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_RenderTemplate_Missing(t *testing.T) {
+	context := EnvMap{"HOST": "envset.sh"}
+
+	_, err := renderTemplate("test", "{{HOST}} {{USER}} {{PASSWORD}} {{USER}}", context, false)
+	if err == nil || !strings.Contains(err.Error(), "unresolved template placeholders: PASSWORD, USER") {
+		t.Fatalf("expected unresolved placeholders error, got %v", err)
+	}
+
+	_, err = renderTemplate("test", "{{.USER}}", context, false)
+	if err == nil || !strings.Contains(err.Error(), "unresolved template placeholders: USER") {
+		t.Fatal("expected error for missing {{.USER}}")
+	}
+
+	got, err := renderTemplate("test", "{{HOST}} {{USER}} {{.USER}}", context, true)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if got != "envset.sh {{USER}} {{.USER}}" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func Test_RenderTemplateFile(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".envset")
+	tplFile := filepath.Join(dir, "nginx.conf.tpl")
+
+	if err := os.WriteFile(envFile, []byte("[production]\nHOST=envset.sh\nURL=https://${HOST}\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if err := os.WriteFile(tplFile, []byte("server_name {{HOST}}; # {{APP_ENV}} {{URL}}\n"), 0644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+
+	var out bytes.Buffer
+	err := RenderTemplate(&out, "production", RenderOptions{Template: tplFile}, RunOptions{
+		Filename:      envFile,
+		Expand:        true,
+		ExportEnvName: "APP_ENV",
+	})
+	if err != nil {
+		t.Fatalf("render template: %v", err)
+	}
+
+	if got := out.String(); got != "server_name envset.sh; # production https://envset.sh\n" {
+		t.Fatalf("got %q", got)
+	}
+}
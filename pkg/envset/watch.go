@@ -0,0 +1,170 @@
+package envset
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultWatchInterval is how often we check watched files
+const DefaultWatchInterval = 500 * time.Millisecond
+
+// fileStamp identifies a version of a watched file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size(), exists: true}
+}
+
+// envWatcher polls the env files and any extra watch files, when
+// one changes it resolves the environment again and notifies if
+// the effective values are different. If one of the extra watch
+// files changes the options are built again, see RunOptions.Reload.
+type envWatcher struct {
+	environment string
+	options     RunOptions
+	context     EnvMap
+	stamps      map[string]fileStamp
+	reload      map[string]bool
+	done        chan struct{}
+	once        sync.Once
+}
+
+func newEnvWatcher(environment string, options RunOptions, context EnvMap) *envWatcher {
+	w := &envWatcher{
+		environment: environment,
+		options:     options,
+		context:     context,
+		stamps:      make(map[string]fileStamp),
+		reload:      make(map[string]bool),
+		done:        make(chan struct{}),
+	}
+
+	//local overlays are watched even if they don't exist yet
+	if layers, err := envLayers(environment, options); err == nil {
+		for _, layer := range layers {
+			w.stamps[layer.path] = statFile(layer.path)
+		}
+	}
+
+	for _, name := range options.WatchFiles {
+		path, err := FileFinder(name)
+		if err != nil {
+			continue
+		}
+		w.stamps[path] = statFile(path)
+		w.reload[path] = true
+	}
+
+	return w
+}
+
+// start polls watched files and returns a channel that is
+// closed once the environment has changed.
+func (w *envWatcher) start() <-chan struct{} {
+	changed := make(chan struct{})
+
+	interval := w.options.WatchInterval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+				if w.poll() {
+					close(changed)
+					return
+				}
+			}
+		}
+	}()
+
+	return changed
+}
+
+// waitEnvironmentChange waits until the environment is different
+// from context, it returns false if envset is asked to terminate
+// or options.Stop is closed in the meantime.
+func waitEnvironmentChange(environment string, options RunOptions, context EnvMap) bool {
+	w := newEnvWatcher(environment, options, context)
+	defer w.stop()
+
+	//the files might have changed before we started watching
+	if current, err := loadEnvironment(environment, options); err == nil && !maps.Equal(current, context) {
+		return true
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+
+	select {
+	case <-w.start():
+		return true
+	case <-signals:
+		return false
+	case <-options.Stop:
+		return false
+	}
+}
+
+func (w *envWatcher) stop() {
+	w.once.Do(func() {
+		close(w.done)
+	})
+}
+
+// poll returns true if a watched file changed and the
+// resolved environment is different from the current one.
+func (w *envWatcher) poll() bool {
+	modified, reload := false, false
+	for path, stamp := range w.stamps {
+		current := statFile(path)
+		if current != stamp {
+			w.stamps[path] = current
+			modified = true
+			reload = reload || w.reload[path]
+		}
+	}
+
+	if !modified {
+		return false
+	}
+
+	if reload {
+		options, err := w.options.reload()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "envset: reload options: %s\n", err)
+			return false
+		}
+		w.options = options
+	}
+
+	context, err := loadEnvironment(w.environment, w.options)
+	if err != nil {
+		//Keep the command running, the file might be mid edit
+		fmt.Fprintf(os.Stderr, "envset: reload environment: %s\n", err)
+		return false
+	}
+
+	return !maps.Equal(context, w.context)
+}
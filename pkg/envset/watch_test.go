@@ -0,0 +1,140 @@
+package envset
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Run_WatchRestartsOnEnvironmentChange(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".envset")
+	if err := os.WriteFile(envFile, []byte("[development]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	outFile := filepath.Join(dir, "out")
+	script := `printf "%s\n" "$A" >> "$1"; if [ "$A" != 1 ]; then exit 0; fi; while :; do sleep 0.05; done`
+
+	runWatch(t, "development", RunOptions{
+		Filename:      envFile,
+		Cmd:           "sh",
+		Args:          []string{"-c", script, "sh", outFile},
+		Isolated:      true,
+		ExportEnvName: "APP_ENV",
+		Watch:         true,
+		WatchInterval: 10 * time.Millisecond,
+		StopTimeout:   time.Second,
+	})
+
+	waitForContent(t, outFile, "1\n")
+
+	//Same effective values should not restart the command
+	if err := os.WriteFile(envFile, []byte("[development]\n# comment\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	waitForContent(t, outFile, "1\n")
+
+	if err := os.WriteFile(envFile, []byte("[development]\nA=2\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	waitForContent(t, outFile, "1\n2\n")
+
+	//The command exited, we keep watching and start it on the next change
+	if err := os.WriteFile(envFile, []byte("[development]\nA=3\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	waitForContent(t, outFile, "1\n2\n3\n")
+}
+
+func Test_Run_WatchReloadsOptions(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, ".envset")
+	if err := os.WriteFile(envFile, []byte("[development]\nA=1\n"), 0644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	//the rc file holds the name of the exported environment variable
+	rcFile := filepath.Join(dir, ".envsetrc")
+	if err := os.WriteFile(rcFile, []byte("APP_ENV"), 0644); err != nil {
+		t.Fatalf("write rc file: %v", err)
+	}
+
+	outFile := filepath.Join(dir, "out")
+	script := `trap 'exit 0' TERM; printf "%s\n" "$APP_ENV$ENVSET_ENV" >> "$1"; while :; do sleep 0.05; done`
+
+	options := RunOptions{
+		Filename:      envFile,
+		Cmd:           "sh",
+		Args:          []string{"-c", script, "sh", outFile},
+		Isolated:      true,
+		Watch:         true,
+		WatchFiles:    []string{rcFile},
+		WatchInterval: 10 * time.Millisecond,
+		StopTimeout:   time.Second,
+	}
+	reload := func() (RunOptions, error) {
+		b, err := os.ReadFile(rcFile)
+		o := options
+		o.ExportEnvName = string(b)
+		return o, err
+	}
+	options.Reload = reload
+	options.ExportEnvName = "APP_ENV"
+
+	runWatch(t, "development", options)
+
+	waitForContent(t, outFile, "development\n")
+
+	if err := os.WriteFile(rcFile, []byte("ENVSET_ENV"), 0644); err != nil {
+		t.Fatalf("write rc file: %v", err)
+	}
+	waitForContent(t, outFile, "development\ndevelopment\n")
+}
+
+// runWatch runs the command in the background until the test ends
+func runWatch(t *testing.T, environment string, options RunOptions) {
+	t.Helper()
+
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	options.Stop = stop
+
+	go func() {
+		done <- Run(environment, options)
+	}()
+
+	t.Cleanup(func() {
+		close(stop)
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("run: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("run did not stop")
+		}
+	})
+}
+
+func waitForContent(t *testing.T, name, want string) {
+	t.Helper()
+
+	var got string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b, err := os.ReadFile(name)
+		got = string(b)
+		if err == nil && got == want {
+			return
+		}
+		if !strings.HasPrefix(want, got) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("content of %s = %q, want %q", name, got, want)
+}